@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// sessionAccess collects the credentials a request offers for :id. The
+// link key may also come as ?key= so plain links work.
+func sessionAccess(c *fiber.Ctx) models.SessionAccess {
+	key := c.Get("X-Access-Key")
+	if key == "" {
+		key = c.Query("key")
+	}
+	return models.SessionAccess{
+		Key:         key,
+		AccessToken: c.Get("X-Access-Token"),
+		OwnerToken:  c.Get("X-Owner-Token"),
+		InviteToken: c.Get("X-Invite-Token"),
+		EditToken:   c.Get("X-Edit-Token"),
+	}
+}
+
+// RequireSessionAccess is route middleware that keeps private sessions in
+// :id closed to requests without a valid credential.
+func RequireSessionAccess(c *fiber.Ctx) error {
+	err := services.CheckSessionAccess(c.UserContext(), c.Params("id"), sessionAccess(c))
+	if err == nil {
+		return c.Next()
+	}
+	switch err.Error() {
+	case "session not found":
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Session not found",
+		})
+	case "access_required":
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case "invalid_access":
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// UnlockSessionHandler exchanges a session access password for a token to
+// send as X-Access-Token.
+func UnlockSessionHandler(c *fiber.Ctx) error {
+	var req models.AccessRequest
+	if err := c.BodyParser(&req); err != nil || req.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Password is required",
+		})
+	}
+
+	req.ClientIP = c.IP()
+	resp, err := services.UnlockSession(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		var lockout *services.LockoutError
+		if errors.As(err, &lockout) {
+			return tooManyAttempts(c, lockout)
+		}
+		switch err.Error() {
+		case "session not found":
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Session not found",
+			})
+		case "invalid_password":
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case "password_not_enabled":
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"time"
+
+	"biameet.ir/config"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireAdmin guards the admin API. It accepts "Authorization: Bearer
+// <admin.token>" or HTTP basic auth against admin.user/admin.password. With
+// neither configured, or features.admin_api off, the admin API is disabled.
+func RequireAdmin(c *fiber.Ctx) error {
+	cfg := config.Get()
+	token := cfg.Admin.Token
+	user, password := cfg.Admin.User, cfg.Admin.Password
+	if !cfg.Features.AdminAPI || token == "" && (user == "" || password == "") {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "admin_disabled",
+		})
+	}
+
+	auth := c.Get(fiber.HeaderAuthorization)
+	if token != "" {
+		if bearer, ok := strings.CutPrefix(auth, "Bearer "); ok && secureEqual(bearer, token) {
+			return c.Next()
+		}
+	}
+	if user != "" && password != "" {
+		if encoded, ok := strings.CutPrefix(auth, "Basic "); ok {
+			if raw, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+				u, p, _ := strings.Cut(string(raw), ":")
+				if secureEqual(u, user) && secureEqual(p, password) {
+					return c.Next()
+				}
+			}
+		}
+	}
+
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error": "admin_auth_required",
+	})
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func ListAdminSessionsHandler(c *fiber.Ctx) error {
+	q := models.AdminSessionQuery{
+		Search:  c.Query("q"),
+		Page:    c.QueryInt("page", 1),
+		PerPage: c.QueryInt("per_page", 20),
+	}
+	if archived := c.Query("archived"); archived != "" {
+		value := c.QueryBool("archived")
+		q.Archived = &value
+	}
+
+	list, err := services.ListSessions(c.UserContext(), q)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(list)
+}
+
+func GetAdminSessionHandler(c *fiber.Ctx) error {
+	detail, err := services.GetAdminSession(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(detail)
+}
+
+func ArchiveSessionHandler(c *fiber.Ctx) error {
+	if err := services.SetSessionArchived(c.UserContext(), c.Params("id"), true); err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+func UnarchiveSessionHandler(c *fiber.Ctx) error {
+	if err := services.SetSessionArchived(c.UserContext(), c.Params("id"), false); err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+func DeleteSessionHandler(c *fiber.Ctx) error {
+	if err := services.DeleteSession(c.UserContext(), c.Params("id")); err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+func DeleteVoteHandler(c *fiber.Ctx) error {
+	if err := services.DeleteVote(c.UserContext(), c.Params("id"), c.Params("vote_id")); err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+func DeleteParticipantHandler(c *fiber.Ctx) error {
+	name, err := url.PathUnescape(c.Params("name"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid participant name",
+		})
+	}
+	if err := services.DeleteParticipant(c.UserContext(), c.Params("id"), name); err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// RetentionReportHandler previews the configured retention policy: which
+// sessions the next run would delete. It never deletes anything.
+func RetentionReportHandler(c *fiber.Ctx) error {
+	report, err := services.ApplyRetention(c.UserContext(), config.Get().Retention, time.Now(), true)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(report)
+}
+
+// adminError maps the "... not found" service errors to 404.
+func adminError(c *fiber.Ctx, err error) error {
+	switch err.Error() {
+	case "session not found", "vote not found", "participant not found":
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+func AddCommentHandler(c *fiber.Ctx) error {
+	var req models.CommentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.ClientIP = c.IP()
+	comment, err := services.AddComment(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		return commentError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(comment)
+}
+
+func UpdateCommentHandler(c *fiber.Ctx) error {
+	var req models.CommentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.ClientIP = c.IP()
+	comment, err := services.UpdateComment(c.UserContext(), c.Params("id"), c.Params("comment_id"), req)
+	if err != nil {
+		return commentError(c, err)
+	}
+	return c.JSON(comment)
+}
+
+// DeleteCommentHandler takes the author's password or edit token in the
+// body, or the owner token in X-Owner-Token.
+func DeleteCommentHandler(c *fiber.Ctx) error {
+	var req models.CommentRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	req.OwnerToken = c.Get("X-Owner-Token")
+
+	req.ClientIP = c.IP()
+	if err := services.DeleteComment(c.UserContext(), c.Params("id"), c.Params("comment_id"), req); err != nil {
+		return commentError(c, err)
+	}
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+func commentError(c *fiber.Ctx, err error) error {
+	var lockout *services.LockoutError
+	if errors.As(err, &lockout) {
+		return tooManyAttempts(c, lockout)
+	}
+	switch err.Error() {
+	case "session not found", "comment not found":
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case "comment_empty", "comment_too_long", "author_required":
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case "password_required", "invalid_password", "invalid_edit_token", "edit_token_expired", "invalid_owner_token", "invalid_invite_token":
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case "not_comment_author", "comment_not_protected", "name_taken_no_password", "edit_links_disabled":
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case "session_archived", "name_reserved_for_invitee":
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
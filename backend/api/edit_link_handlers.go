@@ -0,0 +1,32 @@
+package api
+
+import (
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RevokeEditLinksHandler invalidates a participant's edit links and returns
+// a fresh one. Credentials travel in the body like for the vote history;
+// an X-Owner-Token is passed on so the service can refuse it.
+func RevokeEditLinksHandler(c *fiber.Ctx) error {
+	var req models.ParticipantCredentials
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.OwnerToken = c.Get("X-Owner-Token")
+	if req.VoterName == "" && req.EditToken == "" && req.InviteToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Voter name is required",
+		})
+	}
+
+	req.ClientIP = c.IP()
+	resp, err := services.RevokeEditLinks(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		return voteVersionError(c, err)
+	}
+	return c.JSON(resp)
+}
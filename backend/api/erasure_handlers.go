@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// EraseParticipantHandler lets participants delete their own data from a
+// session, authenticated by password or edit token.
+func EraseParticipantHandler(c *fiber.Ctx) error {
+	var req models.EraseParticipantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.VoterName == "" && req.EditToken == "" && req.InviteToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Voter name is required",
+		})
+	}
+
+	req.ClientIP = c.IP()
+	resp, err := services.EraseParticipant(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		var lockout *services.LockoutError
+		if errors.As(err, &lockout) {
+			return tooManyAttempts(c, lockout)
+		}
+		switch err.Error() {
+		case "session not found", "participant not found":
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case "password_required", "invalid_password", "invalid_edit_token", "edit_token_expired", "invalid_invite_token":
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case "name_taken_no_password", "edit_links_disabled":
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(resp)
+}
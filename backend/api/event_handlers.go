@@ -0,0 +1,31 @@
+package api
+
+import (
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// SessionHistoryHandler returns the audit trail of the session in :id. It
+// names voters and their address hashes, so it is mounted behind
+// RequireOwner or RequireAdmin.
+func SessionHistoryHandler(c *fiber.Ctx) error {
+	q := models.SessionHistoryQuery{
+		Type:    c.Query("type"),
+		Page:    c.QueryInt("page", 1),
+		PerPage: c.QueryInt("per_page", 50),
+	}
+
+	history, err := services.GetSessionHistory(c.UserContext(), c.Params("id"), q)
+	if err != nil {
+		if err.Error() == "session not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Session not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(history)
+}
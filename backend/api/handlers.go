@@ -1,8 +1,14 @@
 package api
 
 import (
+	"errors"
+	"html"
+	"net/mail"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"biameet.ir/models"
 	"biameet.ir/services"
@@ -24,6 +30,36 @@ func CreateSessionHandler(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := services.NormalizeSessionDetails(&req.SessionDetails); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if req.ResultsVisibility != "" && !models.ValidVisibility(req.ResultsVisibility) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid results visibility",
+		})
+	}
+
+	if req.Slug != "" {
+		if err := services.ValidateSlug(req.Slug); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	if req.TimeslotPattern != nil {
+		preview, err := services.GenerateTimeslots(c.UserContext(), *req.TimeslotPattern)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		req.Timeslots = append(req.Timeslots, preview.Timeslots...)
+	}
+
 	// Validation for fixed type
 	if req.Type == "fixed" && len(req.Timeslots) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -52,10 +88,45 @@ func CreateSessionHandler(c *fiber.Ctx) error {
 				"error": "AllowedDays is required for weekly sessions",
 			})
 		}
+		if err := services.ValidateSlotRules(req.DynamicConfig); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	if services.PowDifficulty() > 0 {
+		if err := services.VerifyProofOfWork(c.UserContext(), req.PowChallenge, req.PowNonce); err != nil {
+			switch err.Error() {
+			case "pow_required", "invalid_pow", "pow_expired", "pow_reused":
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 	}
 
-	resp, err := services.CreateSession(req)
+	req.ClientIP = c.IP()
+	resp, err := services.CreateSession(c.UserContext(), req)
 	if err != nil {
+		if err.Error() == "after_vote_requires_edit_links" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err.Error() == "slug_taken" {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err.Error() == "pow_reused" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -64,6 +135,34 @@ func CreateSessionHandler(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(resp)
 }
 
+func GetChallengeHandler(c *fiber.Ctx) error {
+	challenge, err := services.IssuePowChallenge(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(challenge)
+}
+
+// PreviewTimeslotsHandler shows the timeslots a pattern would create.
+func PreviewTimeslotsHandler(c *fiber.Ctx) error {
+	var pattern models.TimeslotPattern
+	if err := c.BodyParser(&pattern); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	preview, err := services.GenerateTimeslots(c.UserContext(), pattern)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(preview)
+}
+
 func AddTimeslotHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -85,11 +184,39 @@ func AddTimeslotHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	// TODO: Validate if timeslot is within dynamic session limits (can be done here or service)
-	// For now, we trust the frontend or add simple validation later.
+	// AddTimeslot enforces the session's slot rules and time window.
 
-	ts, err := services.AddTimeslot(id, req)
+	req.ClientIP = c.IP()
+	ts, err := services.AddTimeslot(c.UserContext(), id, req)
 	if err != nil {
+		var lockout *services.LockoutError
+		if errors.As(err, &lockout) {
+			return tooManyAttempts(c, lockout)
+		}
+		if err.Error() == "session not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Session not found",
+			})
+		}
+		if err.Error() == "session_archived" {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "session_archived",
+			})
+		}
+		switch err.Error() {
+		case "invalid_timeslot", "slot_outside_window", "slot_duration_not_allowed", "slot_start_not_aligned":
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case "password_required", "invalid_password":
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case "timeslot_exists", "slot_too_close", "name_reserved_for_invitee":
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -107,19 +234,32 @@ func DeleteTimeslotHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	var req struct {
-		Password string `json:"password"`
-	}
+	var req models.DeleteTimeslotRequest
 	// Attempt to parse body, ignore error if body is empty or invalid JSON (treat as no password)
 	c.BodyParser(&req)
+	req.ClientIP = c.IP()
 
-	err := services.DeleteTimeslot(id, tsID, req.Password)
+	err := services.DeleteTimeslot(c.UserContext(), id, tsID, req)
 	if err != nil {
+		var lockout *services.LockoutError
+		if errors.As(err, &lockout) {
+			return tooManyAttempts(c, lockout)
+		}
 		if err.Error() == "timeslot not found" {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Timeslot not found",
 			})
 		}
+		if err.Error() == "session not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Session not found",
+			})
+		}
+		if err.Error() == "session_archived" {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "session_archived",
+			})
+		}
 		if err.Error() == "cannot delete timeslot with existing votes" {
 			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 				"error": "Cannot delete timeslot with existing votes",
@@ -143,6 +283,53 @@ func DeleteTimeslotHandler(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"status": "ok"})
 }
 
+// UpdateTimeslotHandler takes the timeslot password in the body, or the
+// owner token in X-Owner-Token.
+func UpdateTimeslotHandler(c *fiber.Ctx) error {
+	var req models.UpdateTimeslotRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.OwnerToken = c.Get("X-Owner-Token")
+	req.ClientIP = c.IP()
+
+	resp, err := services.UpdateTimeslot(c.UserContext(), c.Params("id"), c.Params("ts_id"), req)
+	if err != nil {
+		var lockout *services.LockoutError
+		if errors.As(err, &lockout) {
+			return tooManyAttempts(c, lockout)
+		}
+		switch err.Error() {
+		case "session not found", "timeslot not found":
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case "invalid_timeslot", "slot_outside_window", "slot_duration_not_allowed", "slot_start_not_aligned":
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case "password_required", "invalid_password", "invalid_owner_token":
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case "timeslot_not_protected":
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case "timeslot_exists", "slot_too_close", "session_archived":
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(resp)
+}
+
 func GetSessionHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -151,7 +338,12 @@ func GetSessionHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	session, err := services.GetSession(id)
+	// Hidden results are revealed to the owner and, depending on the mode,
+	// to participants who prove who they are
+	viewer := services.ResolveViewer(c.UserContext(), id,
+		c.Get("X-Owner-Token"), c.Get("X-Edit-Token"), c.Get("X-Invite-Token"))
+
+	session, err := services.GetSessionForViewer(c.UserContext(), id, viewer)
 	if err != nil {
 		if err.Error() == "session not found" {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -181,7 +373,7 @@ func VoteHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	if req.VoterName == "" {
+	if req.VoterName == "" && req.InviteToken == "" && req.EditToken == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Voter name is required",
 		})
@@ -194,13 +386,56 @@ func VoteHandler(c *fiber.Ctx) error {
 	// 	})
 	// }
 
-	err := services.SubmitVote(id, req)
+	if req.Email != "" {
+		if _, err := mail.ParseAddress(req.Email); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid email address",
+			})
+		}
+	}
+
+	req.ClientIP = c.IP()
+	resp, err := services.SubmitVote(c.UserContext(), id, req)
 	if err != nil {
+		var lockout *services.LockoutError
+		if errors.As(err, &lockout) {
+			return tooManyAttempts(c, lockout)
+		}
 		if err.Error() == "session not found" {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Session not found",
 			})
 		}
+		if err.Error() == "session_archived" {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "session_archived",
+			})
+		}
+		if err.Error() == "invalid_invite_token" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "invalid_invite_token",
+			})
+		}
+		if err.Error() == "invalid_edit_token" || err.Error() == "edit_token_expired" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err.Error() == "edit_links_disabled" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "edit_links_disabled",
+			})
+		}
+		if err.Error() == "voter_name_required" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Voter name is required",
+			})
+		}
+		if err.Error() == "name_reserved_for_invitee" {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "name_reserved_for_invitee",
+			})
+		}
 		// Check for double vote error message pattern if needed, or just 409
 		// Simple check
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -208,16 +443,72 @@ func VoteHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(models.VoteResponse{Status: "ok"})
+	return c.JSON(resp)
 }
 
+// tooManyAttempts answers a password lockout with 429 and a Retry-After hint.
+func tooManyAttempts(c *fiber.Ctx, lockout *services.LockoutError) error {
+	retryAfter := int(lockout.RetryAfter.Seconds())
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       "too_many_attempts",
+		"retry_after": retryAfter,
+	})
+}
+
+// GetAdminStatsHandler returns the overall totals and, when any of bucket
+// (day/week/month), from or to (YYYY-MM-DD) is given, a time series. The
+// range defaults to the 30 days ending today.
 func GetAdminStatsHandler(c *fiber.Ctx) error {
-	stats, err := services.GetAdminStats()
+	stats, err := services.GetAdminStats(c.UserContext())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
+
+	if c.Query("bucket") == "" && c.Query("from") == "" && c.Query("to") == "" {
+		return c.JSON(stats)
+	}
+
+	q := models.AdminStatsQuery{Bucket: c.Query("bucket", "day")}
+	if q.Bucket != "day" && q.Bucket != "week" && q.Bucket != "month" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "bucket must be day, week or month",
+		})
+	}
+	now := time.Now().UTC()
+	q.To = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if to := c.Query("to"); to != "" {
+		if q.To, err = time.Parse("2006-01-02", to); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "to must be a YYYY-MM-DD date",
+			})
+		}
+	}
+	q.From = q.To.AddDate(0, 0, -29)
+	if from := c.Query("from"); from != "" {
+		if q.From, err = time.Parse("2006-01-02", from); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "from must be a YYYY-MM-DD date",
+			})
+		}
+	}
+	if q.From.After(q.To) || q.To.Sub(q.From) > 5*366*24*time.Hour {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "from must be before to and the range at most 5 years",
+		})
+	}
+
+	stats.Series, err = services.GetAdminStatsSeries(c.UserContext(), q)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	stats.Bucket = q.Bucket
+	stats.FromUTC = q.From.Format("2006-01-02")
+	stats.ToUTC = q.To.Format("2006-01-02")
 	return c.JSON(stats)
 }
 
@@ -239,22 +530,48 @@ func ServeSessionPage(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString("Error loading application")
 	}
 
-	html := string(content)
+	page := string(content)
 
-	session, err := services.GetSession(id)
-	if err == nil {
-		// Session found, inject tags
-		title := session.Title + " | بیا میت"
-		description := "دعوت به جلسه توسط " + session.CreatorName
+	session, err := services.GetSession(c.UserContext(), id)
+	if err == nil && session.Private {
+		// Link previews and search engines must not see private sessions
+		c.Set("Referrer-Policy", "no-referrer")
+		c.Set("X-Robots-Tag", "noindex")
+		access := models.SessionAccess{Key: c.Query("key"), InviteToken: c.Query("invite"), EditToken: c.Query("edit")}
+		if services.CheckSessionAccess(c.UserContext(), id, access) != nil {
+			session = nil
+		}
+	}
+	if err == nil && session != nil {
+		// Session found, inject tags. They are user input inside HTML.
+		title := html.EscapeString(session.Title + " | بیا میت")
+		description := html.EscapeString(metaDescription(session))
 
 		// Replace Title
-		html = strings.Replace(html, "BiaMeet | بیا میت", title, -1)
+		page = strings.Replace(page, "BiaMeet | بیا میت", title, -1)
 
 		// Replace Description (OG and Twitter)
 		// We target the specific string we put in index.html for OG/Twitter description
-		html = strings.Replace(html, "زمان‌بندی ساده جلسات. بدون نیاز به ثبت‌نام.", description, -1)
+		page = strings.Replace(page, "زمان‌بندی ساده جلسات. بدون نیاز به ثبت‌نام.", description, -1)
 	}
 
 	c.Set("Content-Type", "text/html")
-	return c.SendString(html)
+	return c.SendString(page)
+}
+
+// metaDescription is the link preview text for a session: who invites,
+// where, and the start of the description without Markdown markers.
+func metaDescription(session *models.Session) string {
+	text := "دعوت به جلسه توسط " + session.CreatorName
+	if session.Location != "" {
+		text += " · " + session.Location
+	}
+	if session.Description != "" {
+		plain := strings.Join(strings.Fields(strings.NewReplacer("**", "", "`", "", "*", "").Replace(session.Description)), " ")
+		if utf8.RuneCountInString(plain) > 150 {
+			plain = string([]rune(plain)[:150]) + "…"
+		}
+		text += " — " + plain
+	}
+	return text
 }
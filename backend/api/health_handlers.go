@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"biameet.ir/db"
+	"github.com/gofiber/fiber/v2"
+)
+
+var shuttingDown atomic.Bool
+
+// MarkShuttingDown makes /readyz fail so load balancers stop sending
+// traffic while in-flight requests drain.
+func MarkShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// LivenessHandler reports that the process is up. It deliberately checks
+// nothing else, so a slow database never gets the process restarted.
+func LivenessHandler(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": "ok",
+	})
+}
+
+// ReadinessHandler reports whether the server can take traffic: the
+// database answers and every migration has been applied.
+func ReadinessHandler(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), 2*time.Second)
+	defer cancel()
+
+	checks := fiber.Map{}
+	ready := true
+
+	if shuttingDown.Load() {
+		checks["shutdown"] = "in progress"
+		ready = false
+	}
+
+	if err := db.DB.PingContext(ctx); err != nil {
+		checks["database"] = err.Error()
+		ready = false
+	} else {
+		checks["database"] = "ok"
+	}
+
+	if pending, err := db.UnappliedMigrations(ctx); err != nil {
+		checks["migrations"] = err.Error()
+		ready = false
+	} else if pending > 0 {
+		checks["migrations"] = fiber.Map{"pending": pending}
+		ready = false
+	} else {
+		checks["migrations"] = "ok"
+	}
+
+	status, code := "ok", fiber.StatusOK
+	if !ready {
+		status, code = "unavailable", fiber.StatusServiceUnavailable
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status": status,
+		"checks": checks,
+	})
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireOwner is route middleware that checks the X-Owner-Token header
+// against the session in :id before passing on to the owner-only handler.
+func RequireOwner(c *fiber.Ctx) error {
+	err := services.VerifyOwnerToken(c.UserContext(), c.Params("id"), c.Get("X-Owner-Token"))
+	if err == nil {
+		return c.Next()
+	}
+	switch err.Error() {
+	case "session not found":
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Session not found",
+		})
+	case "owner_token_required", "invalid_owner_token":
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+func CreateInviteesHandler(c *fiber.Ctx) error {
+	var req models.CreateInviteesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if len(req.Names) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one name is required",
+		})
+	}
+
+	invitees, err := services.CreateInvitees(c.UserContext(), c.Params("id"), req.Names)
+	if err != nil {
+		if err.Error() == "invitee_name_taken" {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "invitee_name_taken",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(invitees)
+}
+
+func ListInviteesHandler(c *fiber.Ctx) error {
+	invitees, err := services.ListInvitees(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(invitees)
+}
+
+func DeleteInviteeHandler(c *fiber.Ctx) error {
+	err := services.DeleteInvitee(c.UserContext(), c.Params("id"), c.Params("invitee_id"))
+	if err != nil {
+		if err.Error() == "invitee not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Invitee not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{"status": "ok"})
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"biameet.ir/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// IPRateLimiter limits requests per client IP. Fiber's limiter reports the
+// budget in X-RateLimit-Limit/Remaining/Reset and sets Retry-After once hit.
+func IPRateLimiter(limit config.RateLimit) fiber.Handler {
+	return newLimiter(limit, func(c *fiber.Ctx) string {
+		return c.IP()
+	})
+}
+
+// SessionRateLimiter limits requests per session in :id, whoever sends them.
+func SessionRateLimiter(limit config.RateLimit) fiber.Handler {
+	return newLimiter(limit, func(c *fiber.Ctx) string {
+		return c.Params("id")
+	})
+}
+
+func newLimiter(limit config.RateLimit, key func(*fiber.Ctx) string) fiber.Handler {
+	if limit.Max == 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	return limiter.New(limiter.Config{
+		Max:          limit.Max,
+		Expiration:   limit.Window,
+		KeyGenerator: key,
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "rate_limited",
+			})
+		},
+	})
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// VoteHistoryHandler lists a participant's earlier ballots. It is a POST
+// because the password travels in the body.
+func VoteHistoryHandler(c *fiber.Ctx) error {
+	var req models.ParticipantCredentials
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.OwnerToken = c.Get("X-Owner-Token")
+	if req.VoterName == "" && req.EditToken == "" && req.InviteToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Voter name is required",
+		})
+	}
+
+	req.ClientIP = c.IP()
+	history, err := services.GetVoteHistory(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		return voteVersionError(c, err)
+	}
+	return c.JSON(history)
+}
+
+// RevertVotesHandler restores an earlier version of a participant's ballot.
+func RevertVotesHandler(c *fiber.Ctx) error {
+	var req models.RevertVotesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.OwnerToken = c.Get("X-Owner-Token")
+	if req.VoterName == "" && req.EditToken == "" && req.InviteToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Voter name is required",
+		})
+	}
+	if req.Version < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Version is required",
+		})
+	}
+
+	req.ClientIP = c.IP()
+	resp, err := services.RevertVotes(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		return voteVersionError(c, err)
+	}
+	return c.JSON(resp)
+}
+
+func voteVersionError(c *fiber.Ctx, err error) error {
+	var lockout *services.LockoutError
+	if errors.As(err, &lockout) {
+		return tooManyAttempts(c, lockout)
+	}
+	switch err.Error() {
+	case "session not found", "participant not found", "version not found":
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case "password_required", "invalid_password", "invalid_edit_token", "edit_token_expired", "invalid_owner_token", "invalid_invite_token":
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case "name_taken_no_password", "edit_links_disabled", "owner_token_not_allowed":
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case "session_archived":
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/logging"
+	"biameet.ir/models"
+	"biameet.ir/services"
+)
+
+const usage = `Usage: biameet [--config file] [--db path] <command> [args]
+
+Commands:
+  serve                               run the HTTP server (default)
+  migrate up|down [-steps N]|status   manage the database schema
+  session list [-search q] [-archived yes|no] [-limit N]
+  session show <id>
+  session delete <id>
+  session archive [-undo] <id>
+  stats                               print totals
+  purge -older-than 90d [-dry-run]    delete sessions created before the cutoff
+  retention [-dry-run]                apply the configured retention policy once
+  export [-o file] [id...]            write sessions as JSON (stdout by default)
+  import [-replace] <file>            load sessions written by export
+  backup [file]                       snapshot the database, into backup.dir by default
+  restore [-yes] <file>               replace the database with a snapshot (server stopped)
+`
+
+// errUsage makes runCommand print the usage text.
+var errUsage = errors.New("invalid usage")
+
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"migrate":   migrateCommand,
+	"session":   sessionCommand,
+	"stats":     statsCommand,
+	"purge":     purgeCommand,
+	"retention": retentionCommand,
+	"export":    exportCommand,
+	"import":    importCommand,
+	"backup":    backupCommand,
+	"restore":   restoreCommand,
+}
+
+// runCommand runs an operator command and returns the process exit code.
+func runCommand(args []string) int {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	// CLI output goes to stdout, so logs stay on stderr
+	cfg := config.Get()
+	if err := logging.Setup(os.Stderr, cfg.Logging.Level, "text"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// restore works on files and must not hold the database open
+	if args[0] == "restore" {
+		if err := restoreCommand(ctx, args[1:]); err != nil {
+			return exitCode(err)
+		}
+		return 0
+	}
+
+	open := db.InitDB
+	if args[0] == "migrate" {
+		open = db.Open
+	}
+	if err := open(cfg.Database.Path); err != nil {
+		fmt.Fprintln(os.Stderr, "biameet: open database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	if err := cmd(ctx, args[1:]); err != nil {
+		return exitCode(err)
+	}
+	return 0
+}
+
+func exitCode(err error) int {
+	if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	fmt.Fprintln(os.Stderr, "biameet:", err)
+	return 1
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func migrateCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "up":
+		n, err := db.Migrate(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+		return nil
+	case "down":
+		fs := newFlagSet("migrate down")
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		reverted, err := db.MigrateDown(ctx, *steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %s_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		states, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range states {
+			applied := "pending"
+			if s.Applied {
+				applied = s.AppliedAtUTC
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+	}
+	return errUsage
+}
+
+func sessionCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "list":
+		fs := newFlagSet("session list")
+		search := fs.String("search", "", "match id, title or creator")
+		archived := fs.String("archived", "", "yes or no; both when empty")
+		limit := fs.Int("limit", 50, "maximum sessions to print")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *limit < 1 {
+			return errUsage
+		}
+		q := models.AdminSessionQuery{Search: *search, PerPage: min(*limit, models.MaxSessionsPerPage)}
+		switch *archived {
+		case "":
+		case "yes", "no":
+			b := *archived == "yes"
+			q.Archived = &b
+		default:
+			return errUsage
+		}
+		// ListSessions serves at most MaxSessionsPerPage at a time
+		var list *models.AdminSessionList
+		var sessions []models.AdminSessionSummary
+		for q.Page = 1; len(sessions) < *limit; q.Page++ {
+			var err error
+			if list, err = services.ListSessions(ctx, q); err != nil {
+				return err
+			}
+			sessions = append(sessions, list.Sessions...)
+			if len(list.Sessions) < q.PerPage {
+				break
+			}
+		}
+		if len(sessions) > *limit {
+			sessions = sessions[:*limit]
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCREATED\tARCHIVED\tSLOTS\tVOTES\tPEOPLE\tTITLE")
+		for _, s := range sessions {
+			archivedAt := "-"
+			if s.ArchivedAtUTC != "" {
+				archivedAt = s.ArchivedAtUTC
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", s.ID, s.CreatedAtUTC, archivedAt,
+				s.TimeslotCount, s.VoteCount, s.ParticipantCount, s.Title)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if list.Total > len(sessions) {
+			fmt.Printf("showing %d of %d sessions\n", len(sessions), list.Total)
+		}
+		return nil
+	case "show":
+		if len(args) != 2 {
+			return errUsage
+		}
+		detail, err := services.GetAdminSession(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		return printJSON(detail)
+	case "delete":
+		if len(args) != 2 {
+			return errUsage
+		}
+		if err := services.DeleteSession(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Println("deleted", args[1])
+		return nil
+	case "archive":
+		fs := newFlagSet("session archive")
+		undo := fs.Bool("undo", false, "reopen an archived session")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errUsage
+		}
+		id := fs.Arg(0)
+		if err := services.SetSessionArchived(ctx, id, !*undo); err != nil {
+			return err
+		}
+		if *undo {
+			fmt.Println("unarchived", id)
+		} else {
+			fmt.Println("archived", id)
+		}
+		return nil
+	}
+	return errUsage
+}
+
+func statsCommand(ctx context.Context, args []string) error {
+	stats, err := services.GetAdminStats(ctx)
+	if err != nil {
+		return err
+	}
+	return printJSON(stats)
+}
+
+func purgeCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("purge")
+	olderThan := fs.String("older-than", "", "age such as 90d or 720h")
+	dryRun := fs.Bool("dry-run", false, "only list what would be deleted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *olderThan == "" {
+		return errUsage
+	}
+	age, err := parseAge(*olderThan)
+	if err != nil {
+		return err
+	}
+
+	ids, err := services.PurgeSessions(ctx, time.Now().Add(-age), *dryRun)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		fmt.Println(id)
+	}
+	if *dryRun {
+		fmt.Printf("would delete %d session(s)\n", len(ids))
+	} else {
+		fmt.Printf("deleted %d session(s)\n", len(ids))
+	}
+	return nil
+}
+
+func retentionCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("retention")
+	dryRun := fs.Bool("dry-run", false, "only report what would be deleted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	policy := config.Get().Retention
+	if !policy.Enabled() {
+		return fmt.Errorf("no retention rule is configured (retention.inactive_days, retention.after_last_slot_days)")
+	}
+
+	report, err := services.ApplyRetention(ctx, policy, time.Now(), *dryRun)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tREASON\tLAST ACTIVITY\tLAST SLOT END\tTITLE")
+	for _, s := range report.Sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.ID, s.Reason, s.LastActivityUTC, s.LastSlotEndUTC, s.Title)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if report.DryRun {
+		fmt.Printf("would delete %d session(s)\n", len(report.Sessions))
+	} else {
+		fmt.Printf("deleted %d session(s)\n", len(report.Sessions))
+	}
+	return nil
+}
+
+// parseAge accepts Go durations plus a "d" suffix for days.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+func exportCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("export")
+	out := fs.String("o", "", "output file; stdout when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	export, err := services.ExportSessions(ctx, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return err
+	}
+	if *out != "" {
+		fmt.Printf("exported %d session(s) to %s\n", len(export.Sessions), *out)
+	}
+	return nil
+}
+
+func importCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("import")
+	replace := fs.Bool("replace", false, "overwrite sessions that already exist")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var export models.SessionExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("parse %s: %w", fs.Arg(0), err)
+	}
+
+	result, err := services.ImportSessions(ctx, &export, *replace)
+	if err != nil {
+		return err
+	}
+	for _, id := range result.Skipped {
+		fmt.Println("skipped existing", id)
+	}
+	fmt.Printf("imported %d session(s)\n", len(result.Imported))
+	return nil
+}
+
+func backupCommand(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	if len(args) == 1 {
+		if err := db.Backup(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Println("backup written to", args[0])
+		return nil
+	}
+
+	cfg := config.Get().Backup
+	if cfg.Dir == "" {
+		return fmt.Errorf("no backup file given and backup.dir is not configured")
+	}
+	path, err := db.BackupToDir(ctx, cfg.Dir, cfg.Keep)
+	if err != nil {
+		return err
+	}
+	fmt.Println("backup written to", path)
+	return nil
+}
+
+func restoreCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("restore")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	src, dbPath := fs.Arg(0), config.Get().Database.Path
+
+	version, err := db.CheckBackup(ctx, src)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s has schema version %s\n", src, version)
+	if !*yes {
+		fmt.Printf("Replace %s with it? The server must be stopped. [y/N] ", dbPath)
+		var answer string
+		fmt.Scanln(&answer)
+		if answer != "y" && answer != "yes" {
+			return fmt.Errorf("restore cancelled")
+		}
+	}
+
+	previous, err := db.Restore(ctx, src, dbPath)
+	if err != nil {
+		return err
+	}
+	if previous != "" {
+		fmt.Println("previous database kept at", previous)
+	}
+	fmt.Println("restored", dbPath)
+	return nil
+}
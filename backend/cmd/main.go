@@ -1,54 +1,198 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"biameet.ir/api"
+	"biameet.ir/config"
 	"biameet.ir/db"
+	"biameet.ir/logging"
+	"biameet.ir/metrics"
+	"biameet.ir/services"
+	"biameet.ir/tracing"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 func main() {
-	app := fiber.New()
+	// Defaults < config file < environment < flags, see the config package
+	cfg, opts, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
+	if opts.PrintConfig {
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			fatal("failed to print configuration", err)
+		}
+		return
+	}
+	config.Set(cfg)
+
+	// Anything but "serve" is an operator command, see cli.go
+	if len(opts.Args) > 0 && opts.Args[0] != "serve" {
+		os.Exit(runCommand(opts.Args))
+	}
+
+	// Cancelled on SIGINT/SIGTERM; background jobs stop with it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := logging.Setup(os.Stdout, cfg.Logging.Level, cfg.Logging.Format); err != nil {
+		fatal("invalid logging configuration", err)
+	}
+
+	// Behind the nginx frontend the client address arrives in a header,
+	// e.g. X-Real-IP.
+	app := fiber.New(fiber.Config{
+		ProxyHeader: cfg.Server.TrustedProxyHeader,
+	})
+
+	// Tracing, see tracing.Init for the OTEL_* settings
+	shutdownTracing, err := tracing.Init(ctx)
+	if err != nil {
+		fatal("failed to initialize tracing", err)
+	}
 
 	// Middleware
-	app.Use(logger.New())
-	app.Use(cors.New())
+	app.Use(requestid.New())
+	app.Use(tracing.Middleware())
+	app.Use(logging.Middleware())
+	app.Use(metrics.Middleware())
+	app.Use(cors.New(cors.Config{
+		AllowOrigins: strings.Join(cfg.Server.CORSOrigins, ","),
+	}))
 
 	// Initialize Database
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "biameet.db"
+	if err := db.InitDB(cfg.Database.Path); err != nil {
+		fatal("failed to initialize database", err)
 	}
-	if err := db.InitDB(dbPath); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	metrics.RegisterDB(db.DB)
+
+	// Probes: /livez only says the process runs, /readyz also checks the
+	// database and migrations. /health is kept for existing monitors.
+	app.Get("/health", api.LivenessHandler)
+	app.Get("/livez", api.LivenessHandler)
+	app.Get("/readyz", api.ReadinessHandler)
+
+	// Prometheus scrape endpoint
+	if cfg.Features.Metrics {
+		app.Get("/metrics", metrics.Handler())
 	}
 
-	// Routes
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status": "ok",
-		})
-	})
+	// Rate limits for write endpoints, already checked by config.Validate
+	createLimit := api.IPRateLimiter(config.MustParseRateLimit(cfg.RateLimits.Create))
+	ipLimit := api.IPRateLimiter(config.MustParseRateLimit(cfg.RateLimits.IP))
+	sessionLimit := api.SessionRateLimiter(config.MustParseRateLimit(cfg.RateLimits.Session))
 
 	v1 := app.Group("/api/v1")
-	v1.Post("/sessions", api.CreateSessionHandler)
-	v1.Get("/sessions/:id", api.GetSessionHandler)
-	v1.Post("/sessions/:id/vote", api.VoteHandler)
-	v1.Post("/sessions/:id/timeslots", api.AddTimeslotHandler)
-	v1.Delete("/sessions/:id/timeslots/:ts_id", api.DeleteTimeslotHandler)
-	v1.Get("/admin/stats", api.GetAdminStatsHandler)
+	v1.Get("/challenges", api.GetChallengeHandler)
+	v1.Post("/sessions", createLimit, api.CreateSessionHandler)
+	v1.Post("/timeslots/preview", ipLimit, api.PreviewTimeslotsHandler)
+	v1.Post("/sessions/:id/access", ipLimit, sessionLimit, api.UnlockSessionHandler)
+
+	// Private sessions need a link key, access token or personal token
+	access := api.RequireSessionAccess
+	v1.Get("/sessions/:id", access, api.GetSessionHandler)
+	v1.Post("/sessions/:id/vote", ipLimit, sessionLimit, access, api.VoteHandler)
+	v1.Post("/sessions/:id/timeslots", ipLimit, sessionLimit, access, api.AddTimeslotHandler)
+	v1.Put("/sessions/:id/timeslots/:ts_id", ipLimit, sessionLimit, access, api.UpdateTimeslotHandler)
+	v1.Delete("/sessions/:id/timeslots/:ts_id", ipLimit, sessionLimit, access, api.DeleteTimeslotHandler)
+	v1.Post("/sessions/:id/participants/erase", ipLimit, sessionLimit, access, api.EraseParticipantHandler)
+	v1.Post("/sessions/:id/votes/history", ipLimit, sessionLimit, access, api.VoteHistoryHandler)
+	v1.Post("/sessions/:id/votes/revert", ipLimit, sessionLimit, access, api.RevertVotesHandler)
+	v1.Post("/sessions/:id/edit-links/revoke", ipLimit, sessionLimit, access, api.RevokeEditLinksHandler)
+	v1.Post("/sessions/:id/comments", ipLimit, sessionLimit, access, api.AddCommentHandler)
+	v1.Put("/sessions/:id/comments/:comment_id", ipLimit, sessionLimit, access, api.UpdateCommentHandler)
+	v1.Delete("/sessions/:id/comments/:comment_id", ipLimit, sessionLimit, access, api.DeleteCommentHandler)
+	// Owner routes are limited too, which also slows down token guessing
+	v1.Get("/sessions/:id/history", ipLimit, api.RequireOwner, api.SessionHistoryHandler)
+	if cfg.Features.Invitees {
+		v1.Post("/sessions/:id/invitees", ipLimit, sessionLimit, api.RequireOwner, api.CreateInviteesHandler)
+		v1.Get("/sessions/:id/invitees", ipLimit, api.RequireOwner, api.ListInviteesHandler)
+		v1.Delete("/sessions/:id/invitees/:invitee_id", ipLimit, sessionLimit, api.RequireOwner, api.DeleteInviteeHandler)
+	}
+
+	// Admin API, guarded by admin.token or admin.user/admin.password
+	admin := v1.Group("/admin", api.RequireAdmin)
+	admin.Get("/stats", api.GetAdminStatsHandler)
+	admin.Get("/sessions", api.ListAdminSessionsHandler)
+	admin.Get("/sessions/:id", api.GetAdminSessionHandler)
+	admin.Get("/sessions/:id/history", api.SessionHistoryHandler)
+	admin.Post("/sessions/:id/archive", api.ArchiveSessionHandler)
+	admin.Post("/sessions/:id/unarchive", api.UnarchiveSessionHandler)
+	admin.Delete("/sessions/:id", api.DeleteSessionHandler)
+	admin.Delete("/sessions/:id/votes/:vote_id", api.DeleteVoteHandler)
+	admin.Delete("/sessions/:id/participants/:name", api.DeleteParticipantHandler)
+	admin.Get("/retention", api.RetentionReportHandler)
 
 	// Serve Session Page with Dynamic Meta Tags
 	app.Get("/:id", api.ServeSessionPage)
 
-	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Background jobs run until ctx is cancelled by the shutdown signal
+	var jobs sync.WaitGroup
+	if cfg.Backup.Dir != "" {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			db.RunBackupSchedule(ctx, cfg.Backup.Dir, cfg.Backup.Interval, cfg.Backup.Keep)
+		}()
+	}
+	if cfg.Retention.Enabled() {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			services.RunRetention(ctx, cfg.Retention)
+		}()
+	}
+
+	// Start server; SIGINT/SIGTERM trigger a graceful shutdown
+	listenErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting server", "addr", cfg.Server.ListenAddr)
+		listenErr <- app.Listen(cfg.Server.ListenAddr)
+	}()
+
+	select {
+	case err := <-listenErr:
+		fatal("server stopped", err)
+	case <-ctx.Done():
 	}
-	log.Fatal(app.Listen(":" + port))
+	stop()
+	slog.Info("shutting down", "timeout", cfg.Server.ShutdownTimeout.String())
+	// Fail /readyz for a while first so load balancers stop routing to us
+	// before the listener closes
+	api.MarkShuttingDown()
+	time.Sleep(cfg.Server.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		slog.Error("failed to drain requests", "error", err)
+	}
+	jobs.Wait()
+	if err := services.WaitBackground(shutdownCtx); err != nil {
+		slog.Error("background work did not finish", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+	if err := db.Close(); err != nil {
+		slog.Error("failed to close database", "error", err)
+	}
+	slog.Info("server stopped")
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
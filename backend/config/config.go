@@ -0,0 +1,259 @@
+// Package config holds the typed server configuration. Values are layered:
+// built-in defaults, then an optional YAML or TOML file, then environment
+// variables, then command line flags.
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Server     ServerConfig     `yaml:"server" toml:"server"`
+	Database   DatabaseConfig   `yaml:"database" toml:"database"`
+	Backup     BackupConfig     `yaml:"backup" toml:"backup"`
+	Retention  RetentionConfig  `yaml:"retention" toml:"retention"`
+	Security   SecurityConfig   `yaml:"security" toml:"security"`
+	Admin      AdminConfig      `yaml:"admin" toml:"admin"`
+	RateLimits RateLimitsConfig `yaml:"rate_limits" toml:"rate_limits"`
+	Mail       MailConfig       `yaml:"mail" toml:"mail"`
+	Logging    LoggingConfig    `yaml:"logging" toml:"logging"`
+	Features   FeaturesConfig   `yaml:"features" toml:"features"`
+}
+
+type ServerConfig struct {
+	ListenAddr         string        `yaml:"listen_addr" toml:"listen_addr"`
+	BaseURL            string        `yaml:"base_url" toml:"base_url"`                         // Public origin for absolute links
+	TrustedProxyHeader string        `yaml:"trusted_proxy_header" toml:"trusted_proxy_header"` // e.g. X-Real-IP behind nginx
+	CORSOrigins        []string      `yaml:"cors_origins" toml:"cors_origins"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"` // Drain time on SIGTERM
+	DrainDelay         time.Duration `yaml:"drain_delay" toml:"drain_delay"`           // Unready time before draining
+}
+
+type DatabaseConfig struct {
+	Path         string        `yaml:"path" toml:"path"`
+	MaxOpenConns int           `yaml:"max_open_conns" toml:"max_open_conns"` // 0 = unlimited
+	BusyTimeout  time.Duration `yaml:"busy_timeout" toml:"busy_timeout"`
+}
+
+// BackupConfig schedules snapshots of the database into Dir.
+type BackupConfig struct {
+	Dir      string        `yaml:"dir" toml:"dir"` // Empty disables scheduled backups
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+	Keep     int           `yaml:"keep" toml:"keep"` // Newest snapshots to keep, 0 = all
+}
+
+// RetentionConfig deletes old sessions with everything attached to them.
+// Each rule is off at 0 days; a session goes when any enabled rule matches.
+type RetentionConfig struct {
+	InactiveDays      int           `yaml:"inactive_days" toml:"inactive_days"`               // Days since the last vote, timeslot or participant
+	AfterLastSlotDays int           `yaml:"after_last_slot_days" toml:"after_last_slot_days"` // Days since the last timeslot ended
+	Interval          time.Duration `yaml:"interval" toml:"interval"`
+	DryRun            bool          `yaml:"dry_run" toml:"dry_run"` // Only log what would be deleted
+}
+
+type SecurityConfig struct {
+	BcryptCost      int    `yaml:"bcrypt_cost" toml:"bcrypt_cost"`
+	SessionIDLength int    `yaml:"session_id_length" toml:"session_id_length"`
+	Secret          string `yaml:"secret" toml:"secret"`                 // Signing key; generated and stored in the DB when empty
+	PowDifficulty   int    `yaml:"pow_difficulty" toml:"pow_difficulty"` // Leading zero bits for session creation, 0 = off
+}
+
+type AdminConfig struct {
+	Token    string `yaml:"token" toml:"token"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+// RateLimitsConfig values look like "30/1m"; "off" disables a limit.
+type RateLimitsConfig struct {
+	Create  string `yaml:"create" toml:"create"`   // Session creation per IP
+	IP      string `yaml:"ip" toml:"ip"`           // Writes per IP
+	Session string `yaml:"session" toml:"session"` // Writes per session
+}
+
+type MailConfig struct {
+	SMTPHost     string `yaml:"smtp_host" toml:"smtp_host"` // Empty disables email
+	SMTPPort     int    `yaml:"smtp_port" toml:"smtp_port"`
+	SMTPUser     string `yaml:"smtp_user" toml:"smtp_user"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password"`
+	From         string `yaml:"from" toml:"from"`
+}
+
+type LoggingConfig struct {
+	Level  string `yaml:"level" toml:"level"`   // debug, info, warn, error
+	Format string `yaml:"format" toml:"format"` // json, text
+}
+
+type FeaturesConfig struct {
+	Invitees  bool `yaml:"invitees" toml:"invitees"`
+	EditLinks bool `yaml:"edit_links" toml:"edit_links"`
+	AdminAPI  bool `yaml:"admin_api" toml:"admin_api"`
+	Metrics   bool `yaml:"metrics" toml:"metrics"`
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			ListenAddr:      ":8080",
+			BaseURL:         "https://biameet.ir",
+			CORSOrigins:     []string{"*"},
+			ShutdownTimeout: 15 * time.Second,
+			DrainDelay:      5 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Path:        "biameet.db",
+			BusyTimeout: 5 * time.Second,
+		},
+		Backup: BackupConfig{
+			Interval: 24 * time.Hour,
+			Keep:     7,
+		},
+		Retention: RetentionConfig{
+			Interval: time.Hour,
+		},
+		Security: SecurityConfig{
+			BcryptCost:      10, // bcrypt.DefaultCost
+			SessionIDLength: 5,
+		},
+		RateLimits: RateLimitsConfig{
+			Create:  "10/1h",
+			IP:      "60/1m",
+			Session: "300/1m",
+		},
+		Mail: MailConfig{
+			SMTPPort: 587,
+			From:     "no-reply@biameet.ir",
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Features: FeaturesConfig{
+			Invitees:  true,
+			EditLinks: true,
+			AdminAPI:  true,
+			Metrics:   true,
+		},
+	}
+}
+
+var (
+	mu      sync.RWMutex
+	current = Default()
+)
+
+// Get returns the active configuration. It must be treated as read-only.
+func Get() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Set replaces the active configuration.
+func Set(c *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = c
+}
+
+// Validate reports the first invalid setting.
+func (c *Config) Validate() error {
+	if c.Server.ListenAddr == "" {
+		return fmt.Errorf("server.listen_addr is required")
+	}
+	u, err := url.Parse(c.Server.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("server.base_url must be an absolute http(s) URL, got %q", c.Server.BaseURL)
+	}
+	if len(c.Server.CORSOrigins) == 0 {
+		return fmt.Errorf("server.cors_origins must not be empty; use * to allow any origin")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		return fmt.Errorf("server.shutdown_timeout must be positive")
+	}
+	if c.Server.DrainDelay < 0 {
+		return fmt.Errorf("server.drain_delay must not be negative")
+	}
+	if c.Database.Path == "" {
+		return fmt.Errorf("database.path is required")
+	}
+	if c.Database.MaxOpenConns < 0 {
+		return fmt.Errorf("database.max_open_conns must not be negative")
+	}
+	if c.Database.BusyTimeout < 0 {
+		return fmt.Errorf("database.busy_timeout must not be negative")
+	}
+	if c.Backup.Dir != "" && c.Backup.Interval < time.Minute {
+		return fmt.Errorf("backup.interval must be at least 1m")
+	}
+	if c.Backup.Keep < 0 {
+		return fmt.Errorf("backup.keep must not be negative")
+	}
+	if c.Retention.InactiveDays < 0 || c.Retention.AfterLastSlotDays < 0 {
+		return fmt.Errorf("retention days must not be negative")
+	}
+	if c.Retention.Enabled() && c.Retention.Interval < time.Minute {
+		return fmt.Errorf("retention.interval must be at least 1m")
+	}
+	if c.Security.BcryptCost < 4 || c.Security.BcryptCost > 31 {
+		return fmt.Errorf("security.bcrypt_cost must be between 4 and 31")
+	}
+	if c.Security.SessionIDLength < 4 || c.Security.SessionIDLength > 32 {
+		return fmt.Errorf("security.session_id_length must be between 4 and 32")
+	}
+	if c.Security.PowDifficulty < 0 || c.Security.PowDifficulty > 32 {
+		return fmt.Errorf("security.pow_difficulty must be between 0 and 32")
+	}
+	if (c.Admin.User == "") != (c.Admin.Password == "") {
+		return fmt.Errorf("admin.user and admin.password must be set together")
+	}
+	for name, value := range map[string]string{
+		"rate_limits.create":  c.RateLimits.Create,
+		"rate_limits.ip":      c.RateLimits.IP,
+		"rate_limits.session": c.RateLimits.Session,
+	} {
+		if _, err := ParseRateLimit(value); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	if c.Mail.SMTPHost != "" && (c.Mail.SMTPPort <= 0 || c.Mail.SMTPPort > 65535) {
+		return fmt.Errorf("mail.smtp_port must be a valid port")
+	}
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("logging.level must be debug, info, warn or error")
+	}
+	switch strings.ToLower(c.Logging.Format) {
+	case "json", "text":
+	default:
+		return fmt.Errorf("logging.format must be json or text")
+	}
+	return nil
+}
+
+// Enabled reports whether any retention rule is on.
+func (r RetentionConfig) Enabled() bool {
+	return r.InactiveDays > 0 || r.AfterLastSlotDays > 0
+}
+
+// Redacted returns a copy with secrets masked, for printing.
+func (c *Config) Redacted() *Config {
+	out := *c
+	out.Server.CORSOrigins = append([]string(nil), c.Server.CORSOrigins...)
+	mask := func(s *string) {
+		if *s != "" {
+			*s = "********"
+		}
+	}
+	mask(&out.Security.Secret)
+	mask(&out.Admin.Token)
+	mask(&out.Admin.Password)
+	mask(&out.Mail.SMTPPassword)
+	return &out
+}
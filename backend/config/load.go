@@ -0,0 +1,224 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Options are the command line switches that are not configuration values.
+type Options struct {
+	ConfigPath  string
+	PrintConfig bool
+	Args        []string // Arguments after the flags, e.g. a CLI subcommand
+}
+
+// Load builds the configuration from defaults, the config file, the
+// environment and args (usually os.Args[1:]), in increasing precedence.
+// The config file comes from --config or BIAMEET_CONFIG.
+func Load(args []string) (*Config, Options, error) {
+	var opts Options
+	cfg := Default()
+
+	fs := flag.NewFlagSet("biameet", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.ConfigPath, "config", os.Getenv("BIAMEET_CONFIG"), "path to a YAML or TOML config file")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration and exit")
+	listen := fs.String("listen", "", "listen address, e.g. :8080")
+	dbPath := fs.String("db", "", "SQLite database path")
+	baseURL := fs.String("base-url", "", "public base URL")
+	cors := fs.String("cors-origins", "", "comma separated allowed CORS origins")
+	bcryptCost := fs.Int("bcrypt-cost", 0, "bcrypt cost for passwords")
+	idLength := fs.Int("id-length", 0, "length of generated session IDs")
+	logLevel := fs.String("log-level", "", "debug, info, warn or error")
+	logFormat := fs.String("log-format", "", "json or text")
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, err
+	}
+	opts.Args = fs.Args()
+
+	if opts.ConfigPath != "" {
+		if err := loadFile(cfg, opts.ConfigPath); err != nil {
+			return nil, opts, err
+		}
+	}
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, opts, err
+	}
+
+	// Only flags given explicitly override the layers below
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			cfg.Server.ListenAddr = *listen
+		case "db":
+			cfg.Database.Path = *dbPath
+		case "base-url":
+			cfg.Server.BaseURL = *baseURL
+		case "cors-origins":
+			cfg.Server.CORSOrigins = splitList(*cors)
+		case "bcrypt-cost":
+			cfg.Security.BcryptCost = *bcryptCost
+		case "id-length":
+			cfg.Security.SessionIDLength = *idLength
+		case "log-level":
+			cfg.Logging.Level = *logLevel
+		case "log-format":
+			cfg.Logging.Format = *logFormat
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, opts, err
+	}
+	return cfg, opts, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && err != io.EOF {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("parse %s: unknown key %q", path, undecoded[0].String())
+		}
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// applyEnv keeps the variable names the server read before the config file
+// existed, so existing deployments behave the same.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	str := map[string]*string{
+		"LISTEN_ADDR":          &cfg.Server.ListenAddr,
+		"BASE_URL":             &cfg.Server.BaseURL,
+		"TRUSTED_PROXY_HEADER": &cfg.Server.TrustedProxyHeader,
+		"DB_PATH":              &cfg.Database.Path,
+		"BACKUP_DIR":           &cfg.Backup.Dir,
+		"BIAMEET_SECRET":       &cfg.Security.Secret,
+		"ADMIN_TOKEN":          &cfg.Admin.Token,
+		"ADMIN_USER":           &cfg.Admin.User,
+		"ADMIN_PASSWORD":       &cfg.Admin.Password,
+		"RATE_LIMIT_CREATE":    &cfg.RateLimits.Create,
+		"RATE_LIMIT_IP":        &cfg.RateLimits.IP,
+		"RATE_LIMIT_SESSION":   &cfg.RateLimits.Session,
+		"SMTP_HOST":            &cfg.Mail.SMTPHost,
+		"SMTP_USER":            &cfg.Mail.SMTPUser,
+		"SMTP_PASSWORD":        &cfg.Mail.SMTPPassword,
+		"SMTP_FROM":            &cfg.Mail.From,
+		"LOG_LEVEL":            &cfg.Logging.Level,
+		"LOG_FORMAT":           &cfg.Logging.Format,
+	}
+	for key, dst := range str {
+		if v, ok := lookup(key); ok && v != "" {
+			*dst = v
+		}
+	}
+
+	ints := map[string]*int{
+		"DB_MAX_OPEN_CONNS":              &cfg.Database.MaxOpenConns,
+		"BACKUP_KEEP":                    &cfg.Backup.Keep,
+		"RETENTION_INACTIVE_DAYS":        &cfg.Retention.InactiveDays,
+		"RETENTION_AFTER_LAST_SLOT_DAYS": &cfg.Retention.AfterLastSlotDays,
+		"BCRYPT_COST":                    &cfg.Security.BcryptCost,
+		"SESSION_ID_LENGTH":              &cfg.Security.SessionIDLength,
+		"POW_DIFFICULTY":                 &cfg.Security.PowDifficulty,
+		"SMTP_PORT":                      &cfg.Mail.SMTPPort,
+	}
+	for key, dst := range ints {
+		if v, ok := lookup(key); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid number %q", key, v)
+			}
+			*dst = n
+		}
+	}
+
+	bools := map[string]*bool{
+		"FEATURE_INVITEES":   &cfg.Features.Invitees,
+		"FEATURE_EDIT_LINKS": &cfg.Features.EditLinks,
+		"FEATURE_ADMIN_API":  &cfg.Features.AdminAPI,
+		"FEATURE_METRICS":    &cfg.Features.Metrics,
+		"RETENTION_DRY_RUN":  &cfg.Retention.DryRun,
+	}
+	for key, dst := range bools {
+		if v, ok := lookup(key); ok && v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid boolean %q", key, v)
+			}
+			*dst = b
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"SHUTDOWN_TIMEOUT":   &cfg.Server.ShutdownTimeout,
+		"DRAIN_DELAY":        &cfg.Server.DrainDelay,
+		"DB_BUSY_TIMEOUT":    &cfg.Database.BusyTimeout,
+		"BACKUP_INTERVAL":    &cfg.Backup.Interval,
+		"RETENTION_INTERVAL": &cfg.Retention.Interval,
+	}
+	for key, dst := range durations {
+		if v, ok := lookup(key); ok && v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid duration %q", key, v)
+			}
+			*dst = d
+		}
+	}
+	if v, ok := lookup("CORS_ORIGINS"); ok && v != "" {
+		cfg.Server.CORSOrigins = splitList(v)
+	}
+	// PORT predates LISTEN_ADDR and is what container platforms set
+	if v, ok := lookup("PORT"); ok && v != "" {
+		if _, set := lookup("LISTEN_ADDR"); !set {
+			cfg.Server.ListenAddr = ":" + v
+		}
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// Print writes the configuration as YAML with secrets redacted.
+func Print(w io.Writer, cfg *Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit allows Max requests per Window. A zero Max disables the limit.
+type RateLimit struct {
+	Max    int
+	Window time.Duration
+}
+
+// ParseRateLimit reads limits written as "<max>/<window>", e.g. "30/1m".
+// "0" or "off" disables limiting.
+func ParseRateLimit(s string) (RateLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" || s == "off" {
+		return RateLimit{}, nil
+	}
+	maxStr, windowStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("rate limit %q must look like 30/1m", s)
+	}
+	max, err := strconv.Atoi(maxStr)
+	if err != nil || max < 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit count %q", maxStr)
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit window %q", windowStr)
+	}
+	return RateLimit{Max: max, Window: window}, nil
+}
+
+// MustParseRateLimit is for values that already passed Validate.
+func MustParseRateLimit(s string) RateLimit {
+	limit, err := ParseRateLimit(s)
+	if err != nil {
+		panic(err)
+	}
+	return limit
+}
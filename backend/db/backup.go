@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const backupPrefix = "biameet-"
+
+// Backup writes a consistent copy of the live database to dest using
+// VACUUM INTO, which does not block readers or writers for long.
+func Backup(ctx context.Context, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup target %s already exists", dest)
+	}
+	_, err := DB.ExecContext(ctx, "VACUUM INTO ?", dest)
+	return err
+}
+
+// BackupToDir writes a timestamped snapshot into dir and then deletes all
+// but the newest keep snapshots. keep <= 0 keeps everything.
+func BackupToDir(ctx context.Context, dir string, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	dest := filepath.Join(dir, backupPrefix+time.Now().UTC().Format("20060102T150405.000Z")+".db")
+	if err := Backup(ctx, dest); err != nil {
+		return "", err
+	}
+	if _, err := PruneBackups(dir, keep); err != nil {
+		return dest, err
+	}
+	return dest, nil
+}
+
+// ListBackups returns the snapshots in dir, oldest first. The timestamp in
+// the file name sorts chronologically.
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), backupPrefix) && filepath.Ext(e.Name()) == ".db" {
+			backups = append(backups, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// PruneBackups deletes the oldest snapshots beyond keep.
+func PruneBackups(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	backups, err := ListBackups(dir)
+	if err != nil || len(backups) <= keep {
+		return nil, err
+	}
+	removed := backups[:len(backups)-keep]
+	for _, path := range removed {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// RunBackupSchedule snapshots into dir every interval until ctx is done.
+func RunBackupSchedule(ctx context.Context, dir string, interval time.Duration, keep int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			path, err := BackupToDir(ctx, dir, keep)
+			if err != nil {
+				slog.Error("scheduled backup failed", "error", err)
+				continue
+			}
+			slog.Info("backup written", "path", path)
+		}
+	}
+}
+
+// CheckBackup opens a snapshot read-only and returns its schema version. It
+// fails for corrupt files, files that are not biameet databases and
+// snapshots from a newer build than this one.
+func CheckBackup(ctx context.Context, path string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	src, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	var integrity string
+	if err := src.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return "", fmt.Errorf("%s is not a readable SQLite database: %w", path, err)
+	}
+	if integrity != "ok" {
+		return "", fmt.Errorf("%s failed the integrity check: %s", path, integrity)
+	}
+
+	var tracked bool
+	err = src.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')").Scan(&tracked)
+	if err != nil {
+		return "", err
+	}
+	if !tracked {
+		return "", fmt.Errorf("%s has no schema_migrations table; is it a biameet database?", path)
+	}
+	var version sql.NullString
+	if err := src.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return "", err
+	}
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return "", err
+	}
+	if len(migrations) > 0 && version.String > migrations[len(migrations)-1].Version {
+		return "", fmt.Errorf("backup schema version %s is newer than this build (%s)",
+			version.String, migrations[len(migrations)-1].Version)
+	}
+	return version.String, nil
+}
+
+// Restore replaces the database at dbPath with the snapshot at src. The
+// server must be stopped. The current file is kept next to it with a
+// .pre-restore suffix, and older snapshots are migrated on the next start.
+func Restore(ctx context.Context, src, dbPath string) (string, error) {
+	if _, err := CheckBackup(ctx, src); err != nil {
+		return "", err
+	}
+
+	// Copy first so a failure never leaves dbPath half written
+	tmp := dbPath + ".restore-tmp"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	previous := ""
+	if _, err := os.Stat(dbPath); err == nil {
+		previous = dbPath + ".pre-restore-" + time.Now().UTC().Format("20060102T150405Z")
+		if err := os.Rename(dbPath, previous); err != nil {
+			os.Remove(tmp)
+			return "", err
+		}
+	}
+	// A stale WAL would be replayed on top of the restored file
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return previous, err
+		}
+	}
+	return previous, os.Rename(tmp, dbPath)
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
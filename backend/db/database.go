@@ -1,64 +1,58 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
+	"strings"
 
+	"biameet.ir/config"
+	"github.com/XSAM/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	_ "modernc.org/sqlite"
 )
 
 var DB *sql.DB
 
+// InitDB opens the database and applies pending migrations.
 func InitDB(dbPath string) error {
-	var err error
-	DB, err = sql.Open("sqlite", dbPath)
-	if err != nil {
-		return err
-	}
-
-	if err = DB.Ping(); err != nil {
+	if err := Open(dbPath); err != nil {
 		return err
 	}
-
-	return runMigrations()
+	_, err := Migrate(context.Background())
+	return err
 }
 
-func runMigrations() error {
-	// Read the migration directory
-	migrationDir := "db/migrations"
-	if _, err := os.Stat(migrationDir); os.IsNotExist(err) {
-		// Try looking one level up if we are in cmd/ (local dev)
-		migrationDir = "../db/migrations"
+// Open opens the database without touching the schema, for tools that
+// manage migrations themselves.
+func Open(dbPath string) error {
+	var err error
+	cfg := config.Get().Database
+
+	// Waiting for the write lock beats failing with SQLITE_BUSY
+	dsn := dbPath
+	if cfg.BusyTimeout > 0 {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += fmt.Sprintf("%s_pragma=busy_timeout(%d)", sep, cfg.BusyTimeout.Milliseconds())
 	}
 
-	files, err := os.ReadDir(migrationDir)
+	// Wrapped so every statement becomes an OpenTelemetry span, parented to
+	// the request span when the *Context methods are used.
+	DB, err = otelsql.Open("sqlite", dsn,
+		otelsql.WithAttributes(semconv.DBSystemSqlite),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
 	if err != nil {
-		return fmt.Errorf("could not read migration directory: %v", err)
-	}
-
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		// Simple check for .sql extension
-		if len(file.Name()) < 4 || file.Name()[len(file.Name())-4:] != ".sql" {
-			continue
-		}
-
-		migrationPath := fmt.Sprintf("%s/%s", migrationDir, file.Name())
-		content, err := os.ReadFile(migrationPath)
-		if err != nil {
-			return fmt.Errorf("could not read migration file from %s: %v", migrationPath, err)
-		}
-
-		_, err = DB.Exec(string(content))
-		if err != nil {
-			// Ignore errors for now as we might be re-running migrations
-			// In a real app, we would track applied migrations
-			fmt.Printf("Migration %s warning: %v\n", file.Name(), err)
-		}
+		return err
 	}
+	DB.SetMaxOpenConns(cfg.MaxOpenConns)
 
-	return nil
+	return DB.Ping()
 }
@@ -0,0 +1,368 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lastLegacyVersion is the newest migration that databases from before
+// schema_migrations can already have had applied.
+const lastLegacyVersion = "004"
+
+// knownVersions caches the migration files the process started with, so
+// readiness probes do not re-read the directory.
+var (
+	knownMu       sync.Mutex
+	knownVersions []string
+)
+
+// Migration is one db/migrations/NNN_name.sql file. Files may be split into
+// "-- Up" and "-- Down" sections; without markers the whole file is Up.
+type Migration struct {
+	Version string
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState pairs a migration file with its row in schema_migrations.
+type MigrationState struct {
+	Migration
+	Applied      bool
+	AppliedAtUTC string
+}
+
+func migrationDir() string {
+	dir := "db/migrations"
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		// Try looking one level up if we are in cmd/ (local dev)
+		dir = "../db/migrations"
+	}
+	return dir
+}
+
+// LoadMigrations reads the migration files in version order.
+func LoadMigrations() ([]Migration, error) {
+	dir := migrationDir()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read migration directory: %v", err)
+	}
+
+	var migrations []Migration
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".sql" {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration file from %s: %v", path, err)
+		}
+
+		base := strings.TrimSuffix(file.Name(), ".sql")
+		version, name, _ := strings.Cut(base, "_")
+		up, down := splitMigration(string(content))
+		migrations = append(migrations, Migration{Version: version, Name: name, Up: up, Down: down})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func splitMigration(content string) (up, down string) {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.EqualFold(strings.TrimSpace(line), "-- Down") {
+			return strings.Join(lines[:i], "\n"), strings.Join(lines[i+1:], "\n")
+		}
+	}
+	return content, ""
+}
+
+// splitStatements splits on semicolons outside quotes. It is only meant for
+// the plain DDL in db/migrations, not for triggers.
+func splitStatements(sqlText string) []string {
+	var stmts []string
+	var current strings.Builder
+	var quote rune
+	for _, line := range strings.Split(sqlText, "\n") {
+		if quote == 0 && strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		for _, r := range line {
+			switch {
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+			case r == '\'' || r == '"':
+				quote = r
+			case r == ';':
+				if stmt := strings.TrimSpace(current.String()); stmt != "" {
+					stmts = append(stmts, stmt)
+				}
+				current.Reset()
+				continue
+			}
+			current.WriteRune(r)
+		}
+		current.WriteRune('\n')
+	}
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func ensureMigrationTable(ctx context.Context) error {
+	_, err := DB.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at_utc TEXT NOT NULL
+		)
+	`)
+	return err
+}
+
+func appliedMigrations(ctx context.Context) (map[string]string, error) {
+	rows, err := DB.QueryContext(ctx, "SELECT version, applied_at_utc FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]string{}
+	for rows.Next() {
+		var version, at string
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// Migrate applies pending migrations in order and returns how many ran.
+func Migrate(ctx context.Context) (int, error) {
+	if err := ensureMigrationTable(ctx); err != nil {
+		return 0, err
+	}
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	rememberVersions(migrations)
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Databases from before schema_migrations existed had every file up to
+	// lastLegacyVersion re-run on each start with errors ignored. Adopt those
+	// the same way once; anything newer runs strictly.
+	legacy := false
+	if len(applied) == 0 {
+		var exists bool
+		err := DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'sessions')").Scan(&exists)
+		if err != nil {
+			return 0, err
+		}
+		legacy = exists
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := applyMigration(ctx, m, legacy && m.Version <= lastLegacyVersion); err != nil {
+			return count, fmt.Errorf("migration %s_%s: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func applyMigration(ctx context.Context, m Migration, tolerant bool) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if !tolerant {
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			return err
+		}
+	} else {
+		// One statement at a time, so an ALTER that already happened does
+		// not skip the rest of the file
+		for _, stmt := range splitStatements(m.Up) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				slog.Warn("migration warning", "version", m.Version, "name", m.Name, "error", err)
+			}
+		}
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at_utc) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and
+// returns the ones it reverted.
+func MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	states, err := MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := states[i]
+		if !m.Applied {
+			continue
+		}
+		if strings.TrimSpace(stripComments(m.Down)) == "" {
+			return reverted, fmt.Errorf("migration %s_%s has no down section", m.Version, m.Name)
+		}
+		if err := revertMigration(ctx, m.Migration); err != nil {
+			return reverted, fmt.Errorf("migration %s_%s: %w", m.Version, m.Name, err)
+		}
+		reverted = append(reverted, m.Migration)
+	}
+	return reverted, nil
+}
+
+func revertMigration(ctx context.Context, m Migration) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func stripComments(sqlText string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(sqlText, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// MigrationStatus lists every migration file and whether it has been applied.
+func MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	if err := ensureMigrationTable(ctx); err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		at, ok := applied[m.Version]
+		states[i] = MigrationState{Migration: m, Applied: ok, AppliedAtUTC: at}
+	}
+	return states, nil
+}
+
+// PendingMigrations counts migration files that have not been applied.
+func PendingMigrations(ctx context.Context) (int, error) {
+	states, err := MigrationStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range states {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// UnappliedMigrations counts the migrations known at startup that are not
+// recorded as applied. Unlike PendingMigrations it only reads, which suits
+// frequent probes.
+func UnappliedMigrations(ctx context.Context) (int, error) {
+	knownMu.Lock()
+	versions := knownVersions
+	knownMu.Unlock()
+	if versions == nil {
+		migrations, err := LoadMigrations()
+		if err != nil {
+			return 0, err
+		}
+		versions = rememberVersions(migrations)
+	}
+
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, v := range versions {
+		if _, ok := applied[v]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func rememberVersions(migrations []Migration) []string {
+	versions := make([]string, len(migrations))
+	for i, m := range migrations {
+		versions[i] = m.Version
+	}
+	knownMu.Lock()
+	knownVersions = versions
+	knownMu.Unlock()
+	return versions
+}
+
+// Close closes the database handle opened by InitDB.
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Close()
+}
+
+// SchemaVersion is the newest applied migration version, "" for an empty
+// database.
+func SchemaVersion(ctx context.Context) (string, error) {
+	if err := ensureMigrationTable(ctx); err != nil {
+		return "", err
+	}
+	var version sql.NullString
+	err := DB.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	return version.String, err
+}
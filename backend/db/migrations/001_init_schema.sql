@@ -26,4 +26,7 @@ CREATE TABLE IF NOT EXISTS votes (
     UNIQUE(timeslot_id, voter_name)
 );
 
-
+-- Down
+DROP TABLE IF EXISTS votes;
+DROP TABLE IF EXISTS timeslots;
+DROP TABLE IF EXISTS sessions;
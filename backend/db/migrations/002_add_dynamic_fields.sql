@@ -1,2 +1,6 @@
 ALTER TABLE sessions ADD COLUMN type TEXT DEFAULT 'fixed';
 ALTER TABLE sessions ADD COLUMN dynamic_config TEXT;
+
+-- Down
+ALTER TABLE sessions DROP COLUMN dynamic_config;
+ALTER TABLE sessions DROP COLUMN type;
@@ -0,0 +1,17 @@
+-- Up
+ALTER TABLE sessions ADD COLUMN owner_token_hash TEXT;
+
+CREATE TABLE IF NOT EXISTS invitees (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at_utc TEXT NOT NULL,
+    responded_at_utc TEXT,
+    FOREIGN KEY(session_id) REFERENCES sessions(id) ON DELETE CASCADE,
+    UNIQUE(session_id, name)
+);
+
+-- Down
+DROP TABLE IF EXISTS invitees;
+ALTER TABLE sessions DROP COLUMN owner_token_hash;
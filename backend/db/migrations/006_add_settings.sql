@@ -0,0 +1,9 @@
+-- Up
+-- Server-wide key/value settings, e.g. the generated signing secret.
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+-- Down
+DROP TABLE IF EXISTS settings;
@@ -0,0 +1,13 @@
+-- Up
+-- Failed password attempts, keyed per subject (session + name or timeslot) and per IP.
+CREATE TABLE IF NOT EXISTS auth_failures (
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    last_failure_utc TEXT NOT NULL,
+    locked_until_utc TEXT,
+    PRIMARY KEY (scope, key)
+);
+
+-- Down
+DROP TABLE IF EXISTS auth_failures;
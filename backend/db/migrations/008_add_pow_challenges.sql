@@ -0,0 +1,9 @@
+-- Up
+-- Proof-of-work challenges already redeemed, kept until they expire.
+CREATE TABLE IF NOT EXISTS used_pow_challenges (
+    challenge_hash TEXT PRIMARY KEY,
+    expires_at_utc TEXT NOT NULL
+);
+
+-- Down
+DROP TABLE IF EXISTS used_pow_challenges;
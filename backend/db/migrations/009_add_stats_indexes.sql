@@ -0,0 +1,19 @@
+-- Up
+ALTER TABLE timeslots ADD COLUMN created_at_utc TEXT;
+
+-- Timeslots created before the column existed count from their session's creation
+UPDATE timeslots SET created_at_utc = (SELECT created_at_utc FROM sessions WHERE sessions.id = timeslots.session_id)
+WHERE created_at_utc IS NULL;
+
+-- Indexes for the admin time-series aggregates
+CREATE INDEX IF NOT EXISTS idx_sessions_created_at ON sessions(created_at_utc);
+CREATE INDEX IF NOT EXISTS idx_timeslots_session ON timeslots(session_id);
+CREATE INDEX IF NOT EXISTS idx_timeslots_created_at ON timeslots(created_at_utc);
+CREATE INDEX IF NOT EXISTS idx_votes_created_at ON votes(created_at_utc);
+
+-- Down
+DROP INDEX IF EXISTS idx_votes_created_at;
+DROP INDEX IF EXISTS idx_timeslots_created_at;
+DROP INDEX IF EXISTS idx_timeslots_session;
+DROP INDEX IF EXISTS idx_sessions_created_at;
+ALTER TABLE timeslots DROP COLUMN created_at_utc;
@@ -0,0 +1,17 @@
+-- Up
+-- Audit trail of changes to a session. details is a JSON object and must not
+-- hold data the event removed, e.g. the name of an erased participant.
+CREATE TABLE IF NOT EXISTS session_events (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    actor TEXT,
+    details TEXT,
+    created_at_utc TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_events_session ON session_events(session_id, created_at_utc);
+
+-- Down
+DROP INDEX IF EXISTS idx_session_events_session;
+DROP TABLE IF EXISTS session_events;
@@ -0,0 +1,6 @@
+-- Up
+-- Who may see votes: public, anonymous (counts only), after_vote or owner_only.
+ALTER TABLE sessions ADD COLUMN results_visibility TEXT NOT NULL DEFAULT 'public';
+
+-- Down
+ALTER TABLE sessions DROP COLUMN results_visibility;
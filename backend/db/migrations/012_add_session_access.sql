@@ -0,0 +1,9 @@
+-- Up
+-- Private sessions: a bcrypt access password and/or the SHA-256 of a secret
+-- key carried in the session link. Both NULL means the session is public.
+ALTER TABLE sessions ADD COLUMN access_password_hash TEXT;
+ALTER TABLE sessions ADD COLUMN access_key_hash TEXT;
+
+-- Down
+ALTER TABLE sessions DROP COLUMN access_key_hash;
+ALTER TABLE sessions DROP COLUMN access_password_hash;
@@ -0,0 +1,7 @@
+-- Up
+-- Keyed hash of the client address behind an event, enough to tell actors
+-- apart without storing the address itself.
+ALTER TABLE session_events ADD COLUMN ip_hash TEXT;
+
+-- Down
+ALTER TABLE session_events DROP COLUMN ip_hash;
@@ -0,0 +1,16 @@
+-- Up
+-- Every ballot a participant ends up with, numbered per participant. votes
+-- is a JSON array of {timeslot_id, note}.
+CREATE TABLE IF NOT EXISTS vote_versions (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    voter_name TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    cause TEXT NOT NULL,
+    votes TEXT NOT NULL,
+    created_at_utc TEXT NOT NULL,
+    UNIQUE(session_id, voter_name, version)
+);
+
+-- Down
+DROP TABLE IF EXISTS vote_versions;
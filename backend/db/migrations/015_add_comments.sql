@@ -0,0 +1,18 @@
+-- Up
+-- Discussion thread of a session. password_hash is set when the author
+-- protected the comment for later edits.
+CREATE TABLE IF NOT EXISTS comments (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    author_name TEXT NOT NULL,
+    body TEXT NOT NULL,
+    password_hash TEXT,
+    created_at_utc TEXT NOT NULL,
+    updated_at_utc TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_session ON comments(session_id, created_at_utc);
+
+-- Down
+DROP INDEX IF EXISTS idx_comments_session;
+DROP TABLE IF EXISTS comments;
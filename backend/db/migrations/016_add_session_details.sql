@@ -0,0 +1,12 @@
+-- Up
+-- What the meeting is about and where it happens. description is Markdown.
+ALTER TABLE sessions ADD COLUMN description TEXT;
+ALTER TABLE sessions ADD COLUMN location TEXT;
+ALTER TABLE sessions ADD COLUMN meeting_url TEXT;
+ALTER TABLE sessions ADD COLUMN duration_minutes INTEGER;
+
+-- Down
+ALTER TABLE sessions DROP COLUMN duration_minutes;
+ALTER TABLE sessions DROP COLUMN meeting_url;
+ALTER TABLE sessions DROP COLUMN location;
+ALTER TABLE sessions DROP COLUMN description;
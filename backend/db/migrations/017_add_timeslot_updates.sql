@@ -0,0 +1,9 @@
+-- Up
+-- Timeslots can be moved after voting started. Participants who voted for a
+-- moved timeslot are told so until they vote again.
+ALTER TABLE timeslots ADD COLUMN updated_at_utc TEXT;
+ALTER TABLE participants ADD COLUMN timeslot_changed_at_utc TEXT;
+
+-- Down
+ALTER TABLE participants DROP COLUMN timeslot_changed_at_utc;
+ALTER TABLE timeslots DROP COLUMN updated_at_utc;
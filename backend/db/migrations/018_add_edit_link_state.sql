@@ -0,0 +1,9 @@
+-- Up
+-- Edit links are signed with a per-participant key so rotating it revokes
+-- them, and mailing a link is limited per participant.
+ALTER TABLE participants ADD COLUMN edit_key TEXT;
+ALTER TABLE participants ADD COLUMN edit_link_mailed_at_utc TEXT;
+
+-- Down
+ALTER TABLE participants DROP COLUMN edit_link_mailed_at_utc;
+ALTER TABLE participants DROP COLUMN edit_key;
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "biameet_http_requests_total",
+		Help: "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "biameet_http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "biameet_service_operation_duration_seconds",
+		Help:    "Duration of service operations, including password hashing and other non-SQL work.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	SessionsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "biameet_sessions_created_total",
+		Help: "Sessions created, by session type.",
+	}, []string{"type"})
+
+	VotesSubmitted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "biameet_vote_submissions_total",
+		Help: "Successful vote submissions (a submission may hold many timeslots).",
+	})
+
+	VotesCast = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "biameet_votes_cast_total",
+		Help: "Individual timeslot votes written.",
+	})
+
+	TimeslotsAdded = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "biameet_timeslots_added_total",
+		Help: "Timeslots proposed on existing sessions.",
+	})
+
+	PasswordFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "biameet_password_failures_total",
+		Help: "Wrong participant or timeslot passwords.",
+	}, []string{"kind"})
+
+	Lockouts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "biameet_password_lockouts_total",
+		Help: "Requests refused because of too many failed password attempts.",
+	})
+
+	SessionsPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "biameet_sessions_purged_total",
+		Help: "Sessions deleted by the retention policy, by reason.",
+	}, []string{"reason"})
+)
+
+// Middleware records request counts and latencies per Fiber route pattern,
+// so /api/v1/sessions/:id is one series rather than one per session.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			if fe, ok := err.(*fiber.Error); ok {
+				status = fe.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+		route := c.Route().Path
+		method := c.Method()
+
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// Handler serves the Prometheus exposition format.
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
+
+// RegisterDB exports connection pool stats for the SQLite handle.
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "sqlite"))
+}
+
+// ObserveOperation times a service operation. Use as
+//
+//	defer metrics.ObserveOperation("get_session")()
+func ObserveOperation(operation string) func() {
+	start := time.Now()
+	return func() {
+		operationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
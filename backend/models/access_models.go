@@ -0,0 +1,21 @@
+package models
+
+// SessionAccess is what a request offers to open a private session. Any one
+// valid credential is enough.
+type SessionAccess struct {
+	Key         string // Secret key from the session link
+	AccessToken string // Issued by the access endpoint for the password
+	OwnerToken  string
+	InviteToken string
+	EditToken   string
+}
+
+type AccessRequest struct {
+	Password string `json:"password"`
+	ClientIP string `json:"-"`
+}
+
+type AccessResponse struct {
+	AccessToken  string `json:"access_token"`
+	ExpiresAtUTC string `json:"expires_at_utc"`
+}
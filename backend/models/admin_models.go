@@ -0,0 +1,42 @@
+package models
+
+// MaxSessionsPerPage caps AdminSessionQuery.PerPage; larger values fall back
+// to the default of 20.
+const MaxSessionsPerPage = 100
+
+type AdminSessionQuery struct {
+	Search   string // Matches id, title or creator name
+	Archived *bool  // nil = both
+	Page     int
+	PerPage  int
+}
+
+type AdminSessionSummary struct {
+	ID               string `json:"id"`
+	Title            string `json:"title"`
+	CreatorName      string `json:"creator_name"`
+	Type             string `json:"type"`
+	CreatedAtUTC     string `json:"created_at_utc"`
+	ArchivedAtUTC    string `json:"archived_at_utc,omitempty"`
+	TimeslotCount    int    `json:"timeslot_count"`
+	VoteCount        int    `json:"vote_count"`
+	ParticipantCount int    `json:"participant_count"`
+}
+
+type AdminSessionList struct {
+	Sessions []AdminSessionSummary `json:"sessions"`
+	Total    int                   `json:"total"`
+	Page     int                   `json:"page"`
+	PerPage  int                   `json:"per_page"`
+}
+
+type AdminParticipant struct {
+	Name         string `json:"name"`
+	HasPassword  bool   `json:"has_password"`
+	CreatedAtUTC string `json:"created_at_utc"`
+}
+
+type AdminSessionDetail struct {
+	Session      *Session           `json:"session"`
+	Participants []AdminParticipant `json:"participants"`
+}
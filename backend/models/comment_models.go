@@ -0,0 +1,25 @@
+package models
+
+// MaxCommentLength is the longest comment body accepted, in characters.
+const MaxCommentLength = 2000
+
+type Comment struct {
+	ID           string `json:"id"`
+	AuthorName   string `json:"author_name"`
+	Body         string `json:"body"`
+	CreatedAtUTC string `json:"created_at_utc"`
+	UpdatedAtUTC string `json:"updated_at_utc,omitempty"`
+}
+
+// CommentRequest posts, edits or deletes a comment. Authors prove who they
+// are with Password or EditToken; the owner token only allows deleting.
+type CommentRequest struct {
+	AuthorName string `json:"author_name"`
+	Body       string `json:"body,omitempty"`
+	Password   string `json:"password,omitempty"`
+	EditToken  string `json:"edit_token,omitempty"`
+	// InviteToken lets an invitee comment under their own name
+	InviteToken string `json:"invite_token,omitempty"`
+	OwnerToken  string `json:"-"` // From the X-Owner-Token header
+	ClientIP    string `json:"-"`
+}
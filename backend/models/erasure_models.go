@@ -0,0 +1,19 @@
+package models
+
+// EraseParticipantRequest authenticates like a vote: with the participant's
+// password, a magic edit token or a personal invite token.
+type EraseParticipantRequest struct {
+	VoterName   string `json:"voter_name"`
+	Password    string `json:"password,omitempty"`
+	EditToken   string `json:"edit_token,omitempty"`
+	InviteToken string `json:"invite_token,omitempty"`
+	ClientIP    string `json:"-"`
+}
+
+type EraseParticipantResponse struct {
+	Status              string `json:"status"`
+	VotesDeleted        int    `json:"votes_deleted"`
+	TimeslotsDeleted    int    `json:"timeslots_deleted"`
+	TimeslotsAnonymized int    `json:"timeslots_anonymized"` // Kept for others' votes, creator removed
+	CommentsDeleted     int    `json:"comments_deleted"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "encoding/json"
+
+// SessionEvent is one entry of a session's audit trail. Actor is the name
+// the change was made under, empty when unknown or erased.
+type SessionEvent struct {
+	ID           string          `json:"id"`
+	Type         string          `json:"type"`
+	Actor        string          `json:"actor,omitempty"`
+	IPHash       string          `json:"ip_hash,omitempty"`
+	Details      json.RawMessage `json:"details,omitempty"`
+	CreatedAtUTC string          `json:"created_at_utc"`
+}
+
+type SessionHistoryQuery struct {
+	Type    string // Only events of this type, e.g. vote_submitted
+	Page    int
+	PerPage int
+}
+
+// SessionHistory lists events newest first.
+type SessionHistory struct {
+	Events  []SessionEvent `json:"events"`
+	Total   int            `json:"total"`
+	Page    int            `json:"page"`
+	PerPage int            `json:"per_page"`
+}
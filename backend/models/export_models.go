@@ -0,0 +1,82 @@
+package models
+
+// ExportFormatVersion is bumped whenever SessionExport changes shape.
+const ExportFormatVersion = 1
+
+// SessionExport is the JSON document written by "biameet export". It keeps
+// password and token hashes so an import restores working sessions.
+type SessionExport struct {
+	FormatVersion int               `json:"format_version"`
+	SchemaVersion string            `json:"schema_version"`
+	ExportedAtUTC string            `json:"exported_at_utc"`
+	Sessions      []ExportedSession `json:"sessions"`
+}
+
+type ExportedSession struct {
+	ID             string                `json:"id"`
+	Title          string                `json:"title"`
+	CreatorName    string                `json:"creator_name"`
+	CreatedAtUTC   string                `json:"created_at_utc"`
+	ExpiresAtUTC   string                `json:"expires_at_utc,omitempty"`
+	ArchivedAtUTC  string                `json:"archived_at_utc,omitempty"`
+	Type           string                `json:"type"`
+	DynamicConfig  string                `json:"dynamic_config,omitempty"` // Raw JSON as stored
+	OwnerTokenHash string                `json:"owner_token_hash,omitempty"`
+	Visibility     string                `json:"results_visibility,omitempty"`
+	AccessPassHash string                `json:"access_password_hash,omitempty"`
+	AccessKeyHash  string                `json:"access_key_hash,omitempty"`
+	Timeslots      []ExportedTimeslot    `json:"timeslots"`
+	Votes          []ExportedVote        `json:"votes"`
+	Participants   []ExportedParticipant `json:"participants"`
+	Invitees       []ExportedInvitee     `json:"invitees"`
+	Comments       []ExportedComment     `json:"comments,omitempty"`
+	SessionDetails
+}
+
+type ExportedTimeslot struct {
+	ID           string `json:"id"`
+	StartUTC     string `json:"start_utc"`
+	EndUTC       string `json:"end_utc"`
+	CreatedBy    string `json:"created_by,omitempty"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	CreatedAtUTC string `json:"created_at_utc,omitempty"`
+	UpdatedAtUTC string `json:"updated_at_utc,omitempty"`
+}
+
+type ExportedVote struct {
+	ID           string `json:"id"`
+	TimeslotID   string `json:"timeslot_id"`
+	VoterName    string `json:"voter_name"`
+	Note         string `json:"note,omitempty"`
+	CreatedAtUTC string `json:"created_at_utc"`
+}
+
+type ExportedParticipant struct {
+	Name         string `json:"name"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	CreatedAtUTC string `json:"created_at_utc"`
+	// Set while a timeslot they voted for has moved since their last vote
+	TimeslotChangedAtUTC string `json:"timeslot_changed_at_utc,omitempty"`
+}
+
+type ExportedInvitee struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	TokenHash      string `json:"token_hash"`
+	CreatedAtUTC   string `json:"created_at_utc"`
+	RespondedAtUTC string `json:"responded_at_utc,omitempty"`
+}
+
+type ExportedComment struct {
+	ID           string `json:"id"`
+	AuthorName   string `json:"author_name"`
+	Body         string `json:"body"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	CreatedAtUTC string `json:"created_at_utc"`
+	UpdatedAtUTC string `json:"updated_at_utc,omitempty"`
+}
+
+type ImportResult struct {
+	Imported []string `json:"imported"`
+	Skipped  []string `json:"skipped"` // Already present and not replaced
+}
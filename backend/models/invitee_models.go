@@ -0,0 +1,16 @@
+package models
+
+type Invitee struct {
+	ID             string `json:"id"`
+	SessionID      string `json:"session_id"`
+	Name           string `json:"name"`
+	Token          string `json:"token,omitempty"` // Only returned once, on creation
+	Link           string `json:"link,omitempty"`
+	CreatedAtUTC   string `json:"created_at_utc"`
+	RespondedAtUTC string `json:"responded_at_utc,omitempty"`
+	Responded      bool   `json:"responded"`
+}
+
+type CreateInviteesRequest struct {
+	Names []string `json:"names"`
+}
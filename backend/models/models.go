@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Session struct {
 	ID            string         `json:"id"`
 	Title         string         `json:"title"`
@@ -10,6 +12,56 @@ type Session struct {
 	Timeslots     []Timeslot     `json:"timeslots,omitempty"`
 	Type          string         `json:"type"` // "fixed" or "dynamic"
 	DynamicConfig *DynamicConfig `json:"dynamic_config,omitempty"`
+	// ResultsVisibility is one of the Visibility* constants. ResultsHidden
+	// tells the viewer that votes were withheld from this response.
+	ResultsVisibility string `json:"results_visibility"`
+	ResultsHidden     bool   `json:"results_hidden,omitempty"`
+	Private           bool   `json:"private,omitempty"` // Needs a password or link key to open
+	// TimeslotsChangedAtUTC tells a viewer known by token that a timeslot
+	// they voted for was moved since they last voted
+	TimeslotsChangedAtUTC string `json:"timeslots_changed_at_utc,omitempty"`
+	SessionDetails
+	// DescriptionHTML is Description rendered from Markdown, safe to insert
+	DescriptionHTML string    `json:"description_html,omitempty"`
+	Comments        []Comment `json:"comments"`
+}
+
+// SessionDetails describe the meeting itself. All fields are optional.
+type SessionDetails struct {
+	Description     string `json:"description,omitempty"` // Markdown
+	Location        string `json:"location,omitempty"`
+	MeetingURL      string `json:"meeting_url,omitempty"` // http(s) link to join online
+	DurationMinutes int    `json:"duration_minutes,omitempty"`
+}
+
+// Limits for SessionDetails
+const (
+	MaxDescriptionLength = 2000
+	MaxLocationLength    = 200
+	MaxMeetingURLLength  = 500
+	MaxDurationMinutes   = 24 * 60
+)
+
+const (
+	VisibilityPublic    = "public"     // Everyone sees every vote
+	VisibilityAnonymous = "anonymous"  // Everyone sees counts, nobody sees names
+	VisibilityAfterVote = "after_vote" // Results appear once the viewer has voted; needs edit links
+	VisibilityOwnerOnly = "owner_only" // Only the owner sees results
+)
+
+// ValidVisibility reports whether v is a known results visibility mode.
+func ValidVisibility(v string) bool {
+	switch v {
+	case VisibilityPublic, VisibilityAnonymous, VisibilityAfterVote, VisibilityOwnerOnly:
+		return true
+	}
+	return false
+}
+
+// SessionViewer is who is reading a session, as far as the request proves.
+type SessionViewer struct {
+	IsOwner bool
+	Name    string // Participant name proven by an edit or invite token
 }
 
 type DynamicConfig struct {
@@ -19,15 +71,29 @@ type DynamicConfig struct {
 	AllowedDays []int  `json:"allowed_days,omitempty"` // 0-6 (Sat-Fri or Sun-Sat? Let's assume 0=Saturday as per frontend or just standard 0=Sunday)
 	// Frontend used (idx + 6) % 7 for Jalali.
 	// Let's store standard JS Day (0=Sunday, 6=Saturday) to be safe, or just what frontend sends.
+
+	// Rules for proposed timeslots, checked by AddTimeslot. Zero means no
+	// rule. A fixed SlotMinutes excludes a minimum or maximum.
+	SlotMinutes    int `json:"slot_minutes,omitempty"`
+	MinSlotMinutes int `json:"min_slot_minutes,omitempty"`
+	MaxSlotMinutes int `json:"max_slot_minutes,omitempty"`
+	// GranularityMinutes makes slots start on multiples of it, e.g. 15
+	// for :00, :15, :30 and :45, counted from midnight in Timezone
+	GranularityMinutes int `json:"granularity_minutes,omitempty"`
+	// BufferMinutes is the free time required between two slots
+	BufferMinutes int    `json:"buffer_minutes,omitempty"`
+	Timezone      string `json:"timezone,omitempty"` // IANA name, defaults to Asia/Tehran
 }
 
 type Timeslot struct {
-	ID        string `json:"id"`
-	SessionID string `json:"session_id"`
-	StartUTC  string `json:"start_utc"`
-	EndUTC    string `json:"end_utc"`
-	Votes     []Vote `json:"votes,omitempty"` // Added Votes
-	CreatedBy string `json:"created_by,omitempty"`
+	ID           string `json:"id"`
+	SessionID    string `json:"session_id"`
+	StartUTC     string `json:"start_utc"`
+	EndUTC       string `json:"end_utc"`
+	Votes        []Vote `json:"votes,omitempty"` // Added Votes
+	CreatedBy    string `json:"created_by,omitempty"`
+	VoteCount    *int   `json:"vote_count,omitempty"` // Set when counts are visible
+	UpdatedAtUTC string `json:"updated_at_utc,omitempty"`
 }
 
 type Vote struct {
@@ -44,6 +110,21 @@ type CreateSessionRequest struct {
 	Timeslots     []TimeslotRequest `json:"timeslots"`
 	Type          string            `json:"type"`
 	DynamicConfig *DynamicConfig    `json:"dynamic_config,omitempty"`
+	// TimeslotPattern adds generated timeslots to Timeslots
+	TimeslotPattern *TimeslotPattern `json:"timeslot_pattern,omitempty"`
+	// Optional vanity ID, e.g. "team-sync"; a random one is used otherwise
+	Slug string `json:"slug,omitempty"`
+	SessionDetails
+	// ResultsVisibility defaults to public
+	ResultsVisibility string `json:"results_visibility,omitempty"`
+	// A private session needs AccessPassword or, with PrivateLink, the
+	// secret key that is added to the returned link
+	AccessPassword string `json:"access_password,omitempty"`
+	PrivateLink    bool   `json:"private_link,omitempty"`
+	// Solved proof-of-work challenge, required when POW_DIFFICULTY is set
+	PowChallenge string `json:"pow_challenge,omitempty"`
+	PowNonce     string `json:"pow_nonce,omitempty"`
+	ClientIP     string `json:"-"`
 }
 
 type TimeslotRequest struct {
@@ -51,15 +132,68 @@ type TimeslotRequest struct {
 	EndUTC    string `json:"end_utc"`
 	CreatedBy string `json:"created_by,omitempty"`
 	Password  string `json:"password,omitempty"`
+	ClientIP  string `json:"-"`
+}
+
+type DeleteTimeslotRequest struct {
+	Password string `json:"password"`
+	ClientIP string `json:"-"`
+}
+
+// UpdateTimeslotRequest moves a timeslot. It takes the timeslot password,
+// or the owner token in X-Owner-Token.
+type UpdateTimeslotRequest struct {
+	StartUTC string `json:"start_utc"`
+	EndUTC   string `json:"end_utc"`
+	Password string `json:"password,omitempty"`
+	// InvalidateVotes drops the votes on the timeslot instead of keeping
+	// them. Either way its voters are told the time changed.
+	InvalidateVotes bool   `json:"invalidate_votes,omitempty"`
+	OwnerToken      string `json:"-"`
+	ClientIP        string `json:"-"`
+}
+
+type UpdateTimeslotResponse struct {
+	Timeslot
+	VotesKept        int `json:"votes_kept"`
+	VotesInvalidated int `json:"votes_invalidated"`
 }
 
 type CreateSessionResponse struct {
-	ID   string `json:"id"`
-	Link string `json:"link"`
+	ID         string `json:"id"`
+	Link       string `json:"link"`
+	OwnerToken string `json:"owner_token"`          // Shown once; authorizes owner-only actions
+	AccessKey  string `json:"access_key,omitempty"` // Shown once; only for private links
 }
 
 type AdminStats struct {
 	TotalSessions  int `json:"total_sessions"`
 	TotalTimeslots int `json:"total_timeslots"`
 	TotalVotes     int `json:"total_votes"`
+	// Time series, only when a bucket or date range is requested
+	Bucket  string             `json:"bucket,omitempty"`
+	FromUTC string             `json:"from_utc,omitempty"`
+	ToUTC   string             `json:"to_utc,omitempty"`
+	Series  []AdminStatsBucket `json:"series,omitempty"`
+}
+
+type AdminStatsQuery struct {
+	Bucket string    // "day", "week" or "month"
+	From   time.Time // Inclusive, UTC midnight
+	To     time.Time // Inclusive, UTC midnight
+}
+
+// AdminStatsBucket holds the activity of one day, week (starting Monday) or month.
+type AdminStatsBucket struct {
+	Start              string     `json:"start"` // YYYY-MM-DD
+	SessionsCreated    StatsCount `json:"sessions_created"`
+	VotesCast          StatsCount `json:"votes_cast"`
+	TimeslotsProposed  StatsCount `json:"timeslots_proposed"`
+	ActiveParticipants StatsCount `json:"active_participants"`
+}
+
+// StatsCount is a total broken down by session type (fixed/dynamic/weekly).
+type StatsCount struct {
+	Total  int            `json:"total"`
+	ByType map[string]int `json:"by_type"`
 }
@@ -0,0 +1,7 @@
+package models
+
+type PowChallenge struct {
+	Challenge    string `json:"challenge,omitempty"`
+	Difficulty   int    `json:"difficulty"` // Leading zero bits of sha256(challenge + ":" + nonce); 0 = not required
+	ExpiresAtUTC string `json:"expires_at_utc,omitempty"`
+}
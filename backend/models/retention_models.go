@@ -0,0 +1,19 @@
+package models
+
+// RetentionReport lists the sessions a retention run deleted, or would
+// delete when DryRun is set.
+type RetentionReport struct {
+	DryRun   bool              `json:"dry_run"`
+	RunAtUTC string            `json:"run_at_utc"`
+	Sessions []RetainedSession `json:"sessions"`
+	// Expired password-attempt counters removed in the same run
+	PrunedAuthFailures int64 `json:"pruned_auth_failures"`
+}
+
+type RetainedSession struct {
+	ID              string `json:"id"`
+	Title           string `json:"title"`
+	Reason          string `json:"reason"` // "inactive" or "last_slot_passed"
+	LastActivityUTC string `json:"last_activity_utc"`
+	LastSlotEndUTC  string `json:"last_slot_end_utc,omitempty"`
+}
@@ -0,0 +1,36 @@
+package models
+
+// TimeslotPattern describes repeating timeslots, e.g. 30 minute slots
+// between 09:00 and 12:00 on every Saturday to Wednesday of a month. Times
+// of day are in Timezone.
+type TimeslotPattern struct {
+	StartDate string `json:"start_date"` // YYYY-MM-DD, inclusive
+	EndDate   string `json:"end_date"`   // YYYY-MM-DD, inclusive
+	// Weekdays to use, 0=Sunday to 6=Saturday; empty means every day
+	Weekdays     []int  `json:"weekdays,omitempty"`
+	DayStart     string `json:"day_start"` // "HH:MM"
+	DayEnd       string `json:"day_end"`   // "HH:MM"
+	SlotMinutes  int    `json:"slot_minutes"`
+	GapMinutes   int    `json:"gap_minutes,omitempty"` // Break between two slots of a day
+	Timezone     string `json:"timezone,omitempty"`    // IANA name, defaults to Asia/Tehran
+	SkipHolidays bool   `json:"skip_holidays,omitempty"`
+}
+
+// Limits for TimeslotPattern
+const (
+	MaxPatternDays        = 366
+	MaxGeneratedTimeslots = 200
+	MinSlotMinutes        = 5
+)
+
+// TimeslotPreview is what a pattern produces, without saving anything.
+type TimeslotPreview struct {
+	Timeslots       []TimeslotRequest `json:"timeslots"`
+	SkippedHolidays []Holiday         `json:"skipped_holidays,omitempty"`
+}
+
+// Holiday is an Iranian public holiday.
+type Holiday struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	Name string `json:"name"`
+}
@@ -1,9 +1,19 @@
 package models
 
 type VoteRequest struct {
-	VoterName string     `json:"voter_name"`
-	Password  string     `json:"password,omitempty"`
-	Votes     []VoteItem `json:"votes"`
+	VoterName string `json:"voter_name"`
+	Password  string `json:"password,omitempty"`
+	// InviteToken authenticates a personal invitee link. When set, the voter
+	// name is taken from the invitee and VoterName/Password are ignored.
+	InviteToken string `json:"invite_token,omitempty"`
+	// EditToken is the magic-link alternative to Password for existing voters.
+	EditToken string `json:"edit_token,omitempty"`
+	// Email optionally receives the edit link, at most once per
+	// participant an hour; it is not stored.
+	Email string     `json:"email,omitempty"`
+	Votes []VoteItem `json:"votes"`
+	// ClientIP is filled in by the handler for brute-force protection
+	ClientIP string `json:"-"`
 }
 
 type VoteItem struct {
@@ -13,4 +23,17 @@ type VoteItem struct {
 
 type VoteResponse struct {
 	Status string `json:"status"`
+	// Magic edit link, shown only in this response
+	EditToken            string `json:"edit_token,omitempty"`
+	EditLink             string `json:"edit_link,omitempty"`
+	EditLinkExpiresAtUTC string `json:"edit_link_expires_at_utc,omitempty"`
+	// EditLinkMailed is set when the link is being sent to Email
+	EditLinkMailed bool `json:"edit_link_mailed,omitempty"`
+}
+
+// EditLinkResponse carries a fresh edit link after the old ones were revoked.
+type EditLinkResponse struct {
+	EditToken            string `json:"edit_token"`
+	EditLink             string `json:"edit_link"`
+	EditLinkExpiresAtUTC string `json:"edit_link_expires_at_utc"`
 }
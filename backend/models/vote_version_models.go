@@ -0,0 +1,47 @@
+package models
+
+// Causes of a vote version
+const (
+	VersionCauseBaseline        = "baseline" // Ballot from before versions were kept
+	VersionCauseVote            = "vote"
+	VersionCauseTimeslotAdded   = "timeslot_added"
+	VersionCauseRevert          = "revert"
+	VersionCauseTimeslotChanged = "timeslot_changed" // Votes dropped when a timeslot moved
+)
+
+// ParticipantCredentials proves who a request speaks for: the participant's
+// password, their edit or invite token or, for the session owner, the owner
+// token.
+type ParticipantCredentials struct {
+	VoterName   string `json:"voter_name"`
+	Password    string `json:"password,omitempty"`
+	EditToken   string `json:"edit_token,omitempty"`
+	InviteToken string `json:"invite_token,omitempty"`
+	OwnerToken  string `json:"-"` // From the X-Owner-Token header
+	ClientIP    string `json:"-"`
+}
+
+type VoteVersion struct {
+	Version      int        `json:"version"`
+	Cause        string     `json:"cause"`
+	Votes        []VoteItem `json:"votes"`
+	CreatedAtUTC string     `json:"created_at_utc"`
+}
+
+// VoteHistory lists a participant's ballots, newest first.
+type VoteHistory struct {
+	VoterName string        `json:"voter_name"`
+	Versions  []VoteVersion `json:"versions"`
+}
+
+type RevertVotesRequest struct {
+	ParticipantCredentials
+	Version int `json:"version"`
+}
+
+type RevertVotesResponse struct {
+	Status   string   `json:"status"`
+	Version  int      `json:"version"`           // The new version the revert created
+	Restored int      `json:"restored"`          // Votes cast again
+	Skipped  []string `json:"skipped,omitempty"` // Timeslots deleted since
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccessTokenTTL is how long a session access password is remembered.
+const AccessTokenTTL = 7 * 24 * time.Hour
+
+// CheckSessionAccess lets requests into private sessions. Public sessions
+// always pass. Errors are "session not found", "access_required" when no
+// credential was offered and "invalid_access" when none of them is valid.
+func CheckSessionAccess(ctx context.Context, sessionID string, access models.SessionAccess) error {
+	ctx, end := startOp(ctx, "check_session_access")
+	defer end()
+
+	var passwordHash, keyHash sql.NullString
+	err := db.DB.QueryRowContext(ctx, "SELECT access_password_hash, access_key_hash FROM sessions WHERE id = ?", sessionID).
+		Scan(&passwordHash, &keyHash)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return err
+	}
+	if !passwordHash.Valid && !keyHash.Valid {
+		return nil
+	}
+
+	if access == (models.SessionAccess{}) {
+		return fmt.Errorf("access_required")
+	}
+	if access.Key != "" && keyHash.Valid &&
+		subtle.ConstantTimeCompare([]byte(keyHash.String), []byte(utils.HashToken(access.Key))) == 1 {
+		return nil
+	}
+	if access.AccessToken != "" && passwordHash.Valid {
+		if ok, err := verifyAccessToken(ctx, sessionID, passwordHash.String, access.AccessToken); err != nil || ok {
+			return err
+		}
+	}
+	// Owners, invitees and voters already hold a secret for this session
+	if access.OwnerToken != "" && VerifyOwnerToken(ctx, sessionID, access.OwnerToken) == nil {
+		return nil
+	}
+	if access.EditToken != "" {
+		if _, err := parseEditToken(ctx, sessionID, access.EditToken); err == nil {
+			return nil
+		}
+	}
+	if access.InviteToken != "" {
+		_, _, err := inviteeByToken(ctx, sessionID, access.InviteToken)
+		if err == nil {
+			return nil
+		}
+		if err.Error() != "invalid_invite_token" {
+			return err
+		}
+	}
+	return fmt.Errorf("invalid_access")
+}
+
+// UnlockSession trades the session access password for an access token,
+// so later requests skip bcrypt. Errors are "session not found",
+// "password_not_enabled", "invalid_password" and lockouts.
+func UnlockSession(ctx context.Context, sessionID string, req models.AccessRequest) (*models.AccessResponse, error) {
+	ctx, end := startOp(ctx, "unlock_session")
+	defer end()
+
+	var passwordHash sql.NullString
+	err := db.DB.QueryRowContext(ctx, "SELECT access_password_hash FROM sessions WHERE id = ?", sessionID).Scan(&passwordHash)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !passwordHash.Valid {
+		return nil, fmt.Errorf("password_not_enabled")
+	}
+
+	attemptKeys := accessAttemptKeys(sessionID, req.ClientIP)
+	if err := checkAttempts(ctx, attemptKeys); err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash.String), []byte(req.Password)); err != nil {
+		if err := recordFailedAttempt(ctx, attemptKeys); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid_password")
+	}
+	if err := clearFailedAttempts(ctx, attemptKeys); err != nil {
+		return nil, err
+	}
+
+	key, err := serverSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().UTC().Add(AccessTokenTTL).Truncate(time.Second)
+	// Bound to the current password hash, so changing it revokes old tokens
+	payload := fmt.Sprintf("access|%s|%d|%s", sessionID, expiresAt.Unix(), utils.HashToken(passwordHash.String)[:16])
+	return &models.AccessResponse{
+		AccessToken:  utils.Sign(key, payload),
+		ExpiresAtUTC: expiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+func verifyAccessToken(ctx context.Context, sessionID, passwordHash, token string) (bool, error) {
+	key, err := serverSecret(ctx)
+	if err != nil {
+		return false, err
+	}
+	payload, ok := utils.VerifySigned(key, token)
+	if !ok {
+		return false, nil
+	}
+	parts := strings.Split(payload, "|")
+	if len(parts) != 4 || parts[0] != "access" || parts[1] != sessionID {
+		return false, nil
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false, nil
+	}
+	return parts[3] == utils.HashToken(passwordHash)[:16], nil
+}
@@ -1,30 +1,355 @@
 package services
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
 	"biameet.ir/db"
 	"biameet.ir/models"
 )
 
-func GetAdminStats() (*models.AdminStats, error) {
+func GetAdminStats(ctx context.Context) (*models.AdminStats, error) {
+	ctx, end := startOp(ctx, "admin_stats")
+	defer end()
+
 	stats := &models.AdminStats{}
 
 	// Total Sessions
-	err := db.DB.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&stats.TotalSessions)
+	err := db.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM sessions").Scan(&stats.TotalSessions)
 	if err != nil {
 		return nil, err
 	}
 
 	// Total Timeslots
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM timeslots").Scan(&stats.TotalTimeslots)
+	err = db.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM timeslots").Scan(&stats.TotalTimeslots)
 	if err != nil {
 		return nil, err
 	}
 
 	// Total Votes
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM votes").Scan(&stats.TotalVotes)
+	err = db.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM votes").Scan(&stats.TotalVotes)
 	if err != nil {
 		return nil, err
 	}
 
 	return stats, nil
 }
+
+func ListSessions(ctx context.Context, q models.AdminSessionQuery) (*models.AdminSessionList, error) {
+	ctx, end := startOp(ctx, "admin_list_sessions")
+	defer end()
+
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 || q.PerPage > models.MaxSessionsPerPage {
+		q.PerPage = 20
+	}
+
+	where := "WHERE 1 = 1"
+	var args []interface{}
+	if q.Search != "" {
+		pattern := "%" + escapeLike(q.Search) + "%"
+		where += ` AND (s.id LIKE ? ESCAPE '\' OR s.title LIKE ? ESCAPE '\' OR s.creator_name LIKE ? ESCAPE '\')`
+		args = append(args, pattern, pattern, pattern)
+	}
+	if q.Archived != nil {
+		if *q.Archived {
+			where += " AND s.archived_at_utc IS NOT NULL"
+		} else {
+			where += " AND s.archived_at_utc IS NULL"
+		}
+	}
+
+	list := &models.AdminSessionList{
+		Sessions: []models.AdminSessionSummary{},
+		Page:     q.Page,
+		PerPage:  q.PerPage,
+	}
+	err := db.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM sessions s "+where, args...).Scan(&list.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT s.id, s.title, s.creator_name, s.type, s.created_at_utc, s.archived_at_utc,
+			(SELECT COUNT(*) FROM timeslots t WHERE t.session_id = s.id),
+			(SELECT COUNT(*) FROM votes v JOIN timeslots t ON v.timeslot_id = t.id WHERE t.session_id = s.id),
+			(SELECT COUNT(*) FROM participants p WHERE p.session_id = s.id)
+		FROM sessions s `+where+`
+		ORDER BY s.created_at_utc DESC
+		LIMIT ? OFFSET ?
+	`, append(args, q.PerPage, (q.Page-1)*q.PerPage)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s models.AdminSessionSummary
+		var sessionType, archivedAt sql.NullString
+		if err := rows.Scan(&s.ID, &s.Title, &s.CreatorName, &sessionType, &s.CreatedAtUTC, &archivedAt,
+			&s.TimeslotCount, &s.VoteCount, &s.ParticipantCount); err != nil {
+			return nil, err
+		}
+		s.Type = sessionType.String
+		s.ArchivedAtUTC = archivedAt.String
+		list.Sessions = append(list.Sessions, s)
+	}
+	return list, rows.Err()
+}
+
+func GetAdminSession(ctx context.Context, id string) (*models.AdminSessionDetail, error) {
+	ctx, end := startOp(ctx, "admin_get_session")
+	defer end()
+
+	session, err := GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT name, password_hash, created_at_utc FROM participants
+		WHERE session_id = ? ORDER BY created_at_utc
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	detail := &models.AdminSessionDetail{Session: session, Participants: []models.AdminParticipant{}}
+	for rows.Next() {
+		var p models.AdminParticipant
+		var hash sql.NullString
+		if err := rows.Scan(&p.Name, &hash, &p.CreatedAtUTC); err != nil {
+			return nil, err
+		}
+		p.HasPassword = hash.Valid && hash.String != ""
+		detail.Participants = append(detail.Participants, p)
+	}
+	return detail, rows.Err()
+}
+
+// SetSessionArchived archives a session, which closes it for new votes and
+// timeslots, or reopens it.
+func SetSessionArchived(ctx context.Context, id string, archived bool) error {
+	ctx, end := startOp(ctx, "archive_session")
+	defer end()
+
+	var archivedAt sql.NullString
+	if archived {
+		archivedAt.String = time.Now().UTC().Format(time.RFC3339)
+		archivedAt.Valid = true
+	}
+	res, err := db.DB.ExecContext(ctx, "UPDATE sessions SET archived_at_utc = ? WHERE id = ?", archivedAt, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+func DeleteSession(ctx context.Context, id string) error {
+	ctx, end := startOp(ctx, "delete_session")
+	defer end()
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteSessionTx(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteVote removes a single vote, e.g. an abusive one.
+func DeleteVote(ctx context.Context, sessionID, voteID string) error {
+	ctx, end := startOp(ctx, "delete_vote")
+	defer end()
+
+	res, err := db.DB.ExecContext(ctx, `
+		DELETE FROM votes
+		WHERE id = ? AND timeslot_id IN (SELECT id FROM timeslots WHERE session_id = ?)
+	`, voteID, sessionID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("vote not found")
+	}
+	return nil
+}
+
+// DeleteParticipant removes a participant together with all of their votes
+// and their vote history.
+func DeleteParticipant(ctx context.Context, sessionID, name string) error {
+	ctx, end := startOp(ctx, "delete_participant")
+	defer end()
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM participants WHERE session_id = ? AND name = ?", sessionID, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("participant not found")
+	}
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM votes
+		WHERE voter_name = ? AND timeslot_id IN (SELECT id FROM timeslots WHERE session_id = ?)
+	`, name, sessionID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM vote_versions WHERE session_id = ? AND voter_name = ?", sessionID, name)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetAdminStatsSeries aggregates activity per day, week or month between
+// q.From and q.To, broken down by session type. Buckets without activity are
+// included with zero counts.
+func GetAdminStatsSeries(ctx context.Context, q models.AdminStatsQuery) ([]models.AdminStatsBucket, error) {
+	ctx, end := startOp(ctx, "admin_stats_series")
+	defer end()
+
+	var bucketSQL func(col string) string
+	switch q.Bucket {
+	case "day":
+		bucketSQL = func(col string) string { return "date(" + col + ")" }
+	case "week":
+		// Monday on or before the timestamp
+		bucketSQL = func(col string) string { return "date(" + col + ", '-6 days', 'weekday 1')" }
+	case "month":
+		bucketSQL = func(col string) string { return "strftime('%Y-%m-01', " + col + ")" }
+	default:
+		return nil, fmt.Errorf("invalid bucket")
+	}
+
+	var buckets []models.AdminStatsBucket
+	index := make(map[string]*models.AdminStatsBucket)
+	for start := statsBucketStart(q.Bucket, q.From); !start.After(q.To); start = nextStatsBucket(q.Bucket, start) {
+		buckets = append(buckets, models.AdminStatsBucket{
+			Start:              start.Format("2006-01-02"),
+			SessionsCreated:    models.StatsCount{ByType: map[string]int{}},
+			VotesCast:          models.StatsCount{ByType: map[string]int{}},
+			TimeslotsProposed:  models.StatsCount{ByType: map[string]int{}},
+			ActiveParticipants: models.StatsCount{ByType: map[string]int{}},
+		})
+	}
+	for i := range buckets {
+		index[buckets[i].Start] = &buckets[i]
+	}
+
+	from := q.From.Format(time.RFC3339)
+	to := q.To.AddDate(0, 0, 1).Format(time.RFC3339)
+
+	queries := []struct {
+		sql   string
+		count func(b *models.AdminStatsBucket) *models.StatsCount
+	}{
+		{
+			sql: `SELECT ` + bucketSQL("s.created_at_utc") + `, COALESCE(s.type, 'fixed'), COUNT(*)
+				FROM sessions s
+				WHERE s.created_at_utc >= ? AND s.created_at_utc < ?
+				GROUP BY 1, 2`,
+			count: func(b *models.AdminStatsBucket) *models.StatsCount { return &b.SessionsCreated },
+		},
+		{
+			sql: `SELECT ` + bucketSQL("v.created_at_utc") + `, COALESCE(s.type, 'fixed'), COUNT(*)
+				FROM votes v
+				JOIN timeslots t ON v.timeslot_id = t.id
+				JOIN sessions s ON t.session_id = s.id
+				WHERE v.created_at_utc >= ? AND v.created_at_utc < ?
+				GROUP BY 1, 2`,
+			count: func(b *models.AdminStatsBucket) *models.StatsCount { return &b.VotesCast },
+		},
+		{
+			sql: `SELECT ` + bucketSQL("t.created_at_utc") + `, COALESCE(s.type, 'fixed'), COUNT(*)
+				FROM timeslots t
+				JOIN sessions s ON t.session_id = s.id
+				WHERE t.created_at_utc >= ? AND t.created_at_utc < ?
+				GROUP BY 1, 2`,
+			count: func(b *models.AdminStatsBucket) *models.StatsCount { return &b.TimeslotsProposed },
+		},
+		{
+			// A participant is active in a bucket if any of their votes was cast in it
+			sql: `SELECT ` + bucketSQL("v.created_at_utc") + `, COALESCE(s.type, 'fixed'), COUNT(DISTINCT t.session_id || '/' || v.voter_name)
+				FROM votes v
+				JOIN timeslots t ON v.timeslot_id = t.id
+				JOIN sessions s ON t.session_id = s.id
+				WHERE v.created_at_utc >= ? AND v.created_at_utc < ?
+				GROUP BY 1, 2`,
+			count: func(b *models.AdminStatsBucket) *models.StatsCount { return &b.ActiveParticipants },
+		},
+	}
+
+	for _, query := range queries {
+		rows, err := db.DB.QueryContext(ctx, query.sql, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var start, sessionType sql.NullString
+			var n int
+			if err := rows.Scan(&start, &sessionType, &n); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			b, ok := index[start.String]
+			if !ok {
+				continue
+			}
+			c := query.count(b)
+			c.Total += n
+			c.ByType[sessionType.String] += n
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return buckets, nil
+}
+
+func statsBucketStart(bucket string, t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch bucket {
+	case "week":
+		return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return t
+}
+
+func nextStatsBucket(bucket string, t time.Time) time.Time {
+	switch bucket {
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
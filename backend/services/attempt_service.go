@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"biameet.ir/db"
+	"biameet.ir/metrics"
+)
+
+// Brute-force protection for participant, timeslot and session access
+// passwords. Every
+// failure bumps a counter for the subject (session + name, or timeslot) and
+// for the client IP. Past a few free attempts each further failure locks the
+// key for an exponentially growing delay, capped at maxLockout.
+const (
+	subjectFreeAttempts = 3
+	ipFreeAttempts      = 20 // One IP may legitimately mistype across many names
+	backoffBase         = 2 * time.Second
+	maxLockout          = time.Hour
+	failureMemory       = 24 * time.Hour // Counters older than this start over
+)
+
+// LockoutError is returned while a subject or IP is locked out. Its message
+// is the "too_many_attempts" code used by the handlers.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return "too_many_attempts"
+}
+
+type attemptKey struct {
+	scope string
+	key   string
+	free  int
+}
+
+func participantAttemptKeys(sessionID, name, ip string) []attemptKey {
+	return withIPKey([]attemptKey{{"participant", sessionID + "/" + name, subjectFreeAttempts}}, ip)
+}
+
+func timeslotAttemptKeys(sessionID, timeslotID, ip string) []attemptKey {
+	return withIPKey([]attemptKey{{"timeslot", sessionID + "/" + timeslotID, subjectFreeAttempts}}, ip)
+}
+
+func commentAttemptKeys(sessionID, commentID, ip string) []attemptKey {
+	return withIPKey([]attemptKey{{"comment", sessionID + "/" + commentID, subjectFreeAttempts}}, ip)
+}
+
+func accessAttemptKeys(sessionID, ip string) []attemptKey {
+	return withIPKey([]attemptKey{{"access", sessionID, subjectFreeAttempts}}, ip)
+}
+
+func withIPKey(keys []attemptKey, ip string) []attemptKey {
+	if ip != "" {
+		keys = append(keys, attemptKey{"ip", ip, ipFreeAttempts})
+	}
+	return keys
+}
+
+// checkAttempts returns a *LockoutError if any of the keys is locked.
+func checkAttempts(ctx context.Context, keys []attemptKey) error {
+	now := time.Now().UTC()
+	var wait time.Duration
+	for _, k := range keys {
+		var lockedUntil sql.NullString
+		err := db.DB.QueryRowContext(ctx, "SELECT locked_until_utc FROM auth_failures WHERE scope = ? AND key = ?", k.scope, k.key).Scan(&lockedUntil)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !lockedUntil.Valid {
+			continue
+		}
+		until, err := time.Parse(time.RFC3339, lockedUntil.String)
+		if err == nil && until.After(now) && until.Sub(now) > wait {
+			wait = until.Sub(now)
+		}
+	}
+	if wait > 0 {
+		metrics.Lockouts.Inc()
+		return &LockoutError{RetryAfter: wait.Round(time.Second) + time.Second}
+	}
+	return nil
+}
+
+// recordFailedAttempt must not run inside an open write transaction, since
+// it writes through db.DB. The counter is bumped in place so concurrent
+// failures are all counted, and the lockout follows the count it returns.
+func recordFailedAttempt(ctx context.Context, keys []attemptKey) error {
+	if len(keys) > 0 {
+		metrics.PasswordFailures.WithLabelValues(keys[0].scope).Inc()
+	}
+	now := time.Now().UTC()
+	forgetBefore := now.Add(-failureMemory).Format(time.RFC3339)
+	for _, k := range keys {
+		var failures int
+		err := db.DB.QueryRowContext(ctx, `
+			INSERT INTO auth_failures (scope, key, failures, last_failure_utc)
+			VALUES (?, ?, 1, ?)
+			ON CONFLICT(scope, key) DO UPDATE SET
+				failures = CASE WHEN last_failure_utc < ? THEN 1 ELSE failures + 1 END,
+				last_failure_utc = excluded.last_failure_utc
+			RETURNING failures
+		`, k.scope, k.key, now.Format(time.RFC3339), forgetBefore).Scan(&failures)
+		if err != nil {
+			return err
+		}
+		if failures <= k.free {
+			continue
+		}
+
+		// A slower concurrent failure must not shorten a longer lockout
+		lockedUntil := now.Add(lockoutFor(failures - k.free)).Format(time.RFC3339)
+		_, err = db.DB.ExecContext(ctx, `
+			UPDATE auth_failures SET locked_until_utc = MAX(COALESCE(locked_until_utc, ''), ?)
+			WHERE scope = ? AND key = ?
+		`, lockedUntil, k.scope, k.key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneAuthFailures drops counters that have been forgotten and are no
+// longer locked.
+func pruneAuthFailures(ctx context.Context, now time.Time) (int64, error) {
+	res, err := db.DB.ExecContext(ctx, `
+		DELETE FROM auth_failures
+		WHERE last_failure_utc < ? AND (locked_until_utc IS NULL OR locked_until_utc < ?)
+	`, now.Add(-failureMemory).UTC().Format(time.RFC3339), now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// clearFailedAttempts forgets failures for the subject after a successful
+// login. IP counters are left alone so one right guess doesn't reset them.
+func clearFailedAttempts(ctx context.Context, keys []attemptKey) error {
+	for _, k := range keys {
+		if k.scope == "ip" {
+			continue
+		}
+		if _, err := db.DB.ExecContext(ctx, "DELETE FROM auth_failures WHERE scope = ? AND key = ?", k.scope, k.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lockoutFor(excess int) time.Duration {
+	d := time.Duration(float64(backoffBase) * math.Pow(2, float64(excess-1)))
+	if d > maxLockout || d <= 0 {
+		return maxLockout
+	}
+	return d
+}
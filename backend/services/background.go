@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// background tracks work that outlives its request, such as sending email,
+// so shutdown can wait for it instead of cutting it off.
+var background sync.WaitGroup
+
+func runBackground(fn func()) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		fn()
+	}()
+}
+
+// WaitBackground blocks until background work has finished or ctx is done.
+func WaitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Event types for the comment thread
+const (
+	EventCommentAdded   = "comment_added"
+	EventCommentEdited  = "comment_edited"
+	EventCommentDeleted = "comment_deleted"
+)
+
+// validateCommentBody trims body and checks its length. Errors are
+// "comment_empty" and "comment_too_long".
+func validateCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", fmt.Errorf("comment_empty")
+	}
+	if utf8.RuneCountInString(body) > models.MaxCommentLength {
+		return "", fmt.Errorf("comment_too_long")
+	}
+	return body, nil
+}
+
+// AddComment posts to a session's thread under the same name rules as
+// SubmitVote: participants' names need their password, edit token or invite
+// token, and invitee names their invite token. A password given for any
+// other name protects the comment itself.
+func AddComment(ctx context.Context, sessionID string, req models.CommentRequest) (*models.Comment, error) {
+	ctx, end := startOp(ctx, "add_comment")
+	defer end()
+
+	if err := checkSessionOpen(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	body, err := validateCommentBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.AuthorName)
+	var inviteeID string
+	switch {
+	case req.InviteToken != "":
+		if inviteeID, name, err = inviteeByToken(ctx, sessionID, req.InviteToken); err != nil {
+			return nil, err
+		}
+	case req.EditToken != "":
+		if name, err = parseEditToken(ctx, sessionID, req.EditToken); err != nil {
+			return nil, err
+		}
+	default:
+		// Only new names are free; a participant's needs their password
+		_, err := authenticateParticipant(ctx, sessionID, models.ParticipantCredentials{
+			VoterName: name,
+			Password:  req.Password,
+			ClientIP:  req.ClientIP,
+		})
+		if err != nil && err.Error() != "participant not found" {
+			return nil, err
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("author_required")
+	}
+
+	var passwordHash sql.NullString
+	if req.Password != "" {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(req.Password), config.Get().Security.BcryptCost)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = sql.NullString{String: string(bytes), Valid: true}
+	}
+	ipHash, err := eventIPHash(ctx, req.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &models.Comment{
+		ID:           uuid.New().String(),
+		AuthorName:   name,
+		Body:         body,
+		CreatedAtUTC: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if req.EditToken == "" {
+		// Invitee names can only be claimed through their personal link
+		holder, _, err := nameHolderTx(ctx, tx, sessionID, name)
+		if err != nil {
+			return nil, err
+		}
+		if holder != "" && holder != inviteeID {
+			return nil, fmt.Errorf("name_reserved_for_invitee")
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO comments (id, session_id, author_name, body, password_hash, created_at_utc)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, comment.ID, sessionID, comment.AuthorName, comment.Body, passwordHash, comment.CreatedAtUTC)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordEventTx(ctx, tx, sessionID, EventCommentAdded, name, ipHash, map[string]interface{}{"comment_id": comment.ID}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// UpdateComment replaces the body of a comment. Only its author may edit.
+func UpdateComment(ctx context.Context, sessionID, commentID string, req models.CommentRequest) (*models.Comment, error) {
+	ctx, end := startOp(ctx, "update_comment")
+	defer end()
+
+	if err := checkSessionOpen(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	body, err := validateCommentBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.OwnerToken = "" // Owners moderate by deleting, they don't rewrite
+	comment, err := authorizeComment(ctx, sessionID, commentID, req)
+	if err != nil {
+		return nil, err
+	}
+	ipHash, err := eventIPHash(ctx, req.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	comment.Body = body
+	comment.UpdatedAtUTC = time.Now().UTC().Format(time.RFC3339)
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE comments SET body = ?, updated_at_utc = ? WHERE id = ?", comment.Body, comment.UpdatedAtUTC, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordEventTx(ctx, tx, sessionID, EventCommentEdited, comment.AuthorName, ipHash, map[string]interface{}{"comment_id": commentID}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// DeleteComment removes a comment, by its author or the session owner.
+func DeleteComment(ctx context.Context, sessionID, commentID string, req models.CommentRequest) error {
+	ctx, end := startOp(ctx, "delete_comment")
+	defer end()
+
+	if err := checkSessionOpen(ctx, sessionID); err != nil {
+		return err
+	}
+	comment, err := authorizeComment(ctx, sessionID, commentID, req)
+	if err != nil {
+		return err
+	}
+	ipHash, err := eventIPHash(ctx, req.ClientIP)
+	if err != nil {
+		return err
+	}
+
+	actor := comment.AuthorName
+	if req.OwnerToken != "" {
+		actor = "" // Deleted by the owner
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM comments WHERE id = ?", commentID); err != nil {
+		return err
+	}
+	err = recordEventTx(ctx, tx, sessionID, EventCommentDeleted, actor, ipHash, map[string]interface{}{
+		"comment_id": commentID,
+		"by_owner":   req.OwnerToken != "",
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// authorizeComment loads a comment of the session and checks that req
+// may change it: the owner token, an edit or invite token for the author's
+// name, or the comment's password. Comments posted without either stay as
+// they are.
+func authorizeComment(ctx context.Context, sessionID, commentID string, req models.CommentRequest) (*models.Comment, error) {
+	var comment models.Comment
+	var updatedAt, storedHash sql.NullString
+	err := db.DB.QueryRowContext(ctx, `
+		SELECT id, author_name, body, password_hash, created_at_utc, updated_at_utc
+		FROM comments WHERE id = ? AND session_id = ?
+	`, commentID, sessionID).Scan(&comment.ID, &comment.AuthorName, &comment.Body, &storedHash, &comment.CreatedAtUTC, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("comment not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	comment.UpdatedAtUTC = updatedAt.String
+
+	switch {
+	case req.OwnerToken != "":
+		if err := VerifyOwnerToken(ctx, sessionID, req.OwnerToken); err != nil {
+			return nil, err
+		}
+		return &comment, nil
+	case req.EditToken != "":
+		name, err := parseEditToken(ctx, sessionID, req.EditToken)
+		if err != nil {
+			return nil, err
+		}
+		if name != comment.AuthorName {
+			return nil, fmt.Errorf("not_comment_author")
+		}
+		return &comment, nil
+	case req.InviteToken != "":
+		_, name, err := inviteeByToken(ctx, sessionID, req.InviteToken)
+		if err != nil {
+			return nil, err
+		}
+		if name != comment.AuthorName {
+			return nil, fmt.Errorf("not_comment_author")
+		}
+		return &comment, nil
+	}
+
+	if !storedHash.Valid || storedHash.String == "" {
+		return nil, fmt.Errorf("comment_not_protected")
+	}
+	if req.Password == "" {
+		return nil, fmt.Errorf("password_required")
+	}
+	attemptKeys := commentAttemptKeys(sessionID, commentID, req.ClientIP)
+	if err := checkAttempts(ctx, attemptKeys); err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(storedHash.String), []byte(req.Password)); err != nil {
+		if err := recordFailedAttempt(ctx, attemptKeys); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid_password")
+	}
+	if err := clearFailedAttempts(ctx, attemptKeys); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// sessionComments returns the thread of a session, oldest first.
+func sessionComments(ctx context.Context, sessionID string) ([]models.Comment, error) {
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT id, author_name, body, created_at_utc, updated_at_utc
+		FROM comments WHERE session_id = ?
+		ORDER BY created_at_utc, rowid
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	comments := []models.Comment{}
+	for rows.Next() {
+		var c models.Comment
+		var updatedAt sql.NullString
+		if err := rows.Scan(&c.ID, &c.AuthorName, &c.Body, &c.CreatedAtUTC, &updatedAt); err != nil {
+			return nil, err
+		}
+		c.UpdatedAtUTC = updatedAt.String
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
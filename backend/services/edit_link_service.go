@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/utils"
+)
+
+const (
+	// EditLinkTTL is how long a magic edit link stays valid.
+	EditLinkTTL = 30 * 24 * time.Hour
+	// EditLinkMailInterval limits how often one participant is mailed their
+	// edit link, so the server cannot be used to flood an address.
+	EditLinkMailInterval = time.Hour
+)
+
+var (
+	secretMu sync.Mutex
+	secret   []byte
+)
+
+// serverSecret returns the key used to sign edit links. security.secret wins;
+// otherwise a random key is generated once and kept in the settings table so
+// links survive restarts.
+func serverSecret(ctx context.Context) ([]byte, error) {
+	secretMu.Lock()
+	defer secretMu.Unlock()
+
+	if secret != nil {
+		return secret, nil
+	}
+	if configured := config.Get().Security.Secret; configured != "" {
+		secret = []byte(configured)
+		return secret, nil
+	}
+
+	var stored string
+	err := db.DB.QueryRowContext(ctx, "SELECT value FROM settings WHERE key = 'signing_secret'").Scan(&stored)
+	if err == sql.ErrNoRows {
+		stored, err = utils.GenerateToken(32)
+		if err != nil {
+			return nil, err
+		}
+		// Another process may have raced us; keep whichever value won.
+		_, err = db.DB.ExecContext(ctx, "INSERT OR IGNORE INTO settings (key, value) VALUES ('signing_secret', ?)", stored)
+		if err != nil {
+			return nil, err
+		}
+		err = db.DB.QueryRowContext(ctx, "SELECT value FROM settings WHERE key = 'signing_secret'").Scan(&stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	secret = []byte(stored)
+	return secret, nil
+}
+
+// IssueEditToken signs a token letting voterName change their votes in the
+// session without a password until it expires or the participant revokes
+// their edit links.
+func IssueEditToken(ctx context.Context, sessionID, voterName string) (string, time.Time, error) {
+	key, err := serverSecret(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	editKey, err := participantEditKey(ctx, sessionID, voterName)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().UTC().Add(EditLinkTTL).Truncate(time.Second)
+	// The name goes last and hex encoded since it is free-form text.
+	payload := fmt.Sprintf("edit|%s|%d|%s|%s", sessionID, expiresAt.Unix(), editKey, hex.EncodeToString([]byte(voterName)))
+	return utils.Sign(key, payload), expiresAt, nil
+}
+
+func editLinkPath(sessionID, token string) string {
+	return "/" + sessionID + "?edit=" + token
+}
+
+// participantEditKey returns the key edit links of the participant are bound
+// to, creating it on first use.
+func participantEditKey(ctx context.Context, sessionID, name string) (string, error) {
+	fresh, err := utils.GenerateToken(12)
+	if err != nil {
+		return "", err
+	}
+	_, err = db.DB.ExecContext(ctx, "UPDATE participants SET edit_key = ? WHERE session_id = ? AND name = ? AND edit_key IS NULL",
+		fresh, sessionID, name)
+	if err != nil {
+		return "", err
+	}
+	var editKey string
+	err = db.DB.QueryRowContext(ctx, "SELECT edit_key FROM participants WHERE session_id = ? AND name = ?", sessionID, name).Scan(&editKey)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("participant not found")
+	}
+	return editKey, err
+}
+
+// parseEditToken verifies an edit token for the session and returns the voter
+// name it was issued to. No token is accepted while edit links are disabled.
+func parseEditToken(ctx context.Context, sessionID, token string) (string, error) {
+	if !config.Get().Features.EditLinks {
+		return "", fmt.Errorf("edit_links_disabled")
+	}
+	key, err := serverSecret(ctx)
+	if err != nil {
+		return "", err
+	}
+	payload, ok := utils.VerifySigned(key, token)
+	if !ok {
+		return "", fmt.Errorf("invalid_edit_token")
+	}
+	parts := strings.Split(payload, "|")
+	if len(parts) != 5 || parts[0] != "edit" || parts[1] != sessionID {
+		return "", fmt.Errorf("invalid_edit_token")
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid_edit_token")
+	}
+	if time.Now().Unix() > expires {
+		return "", fmt.Errorf("edit_token_expired")
+	}
+	name, err := hex.DecodeString(parts[4])
+	if err != nil {
+		return "", fmt.Errorf("invalid_edit_token")
+	}
+
+	// Revoked once the participant's key changed or they were erased
+	var editKey sql.NullString
+	err = db.DB.QueryRowContext(ctx, "SELECT edit_key FROM participants WHERE session_id = ? AND name = ?", sessionID, string(name)).Scan(&editKey)
+	if err == sql.ErrNoRows || (err == nil && editKey.String != parts[3]) {
+		return "", fmt.Errorf("invalid_edit_token")
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(name), nil
+}
+
+// RevokeEditLinks invalidates every edit link issued to the participant and
+// returns a fresh one, so the caller keeps access. The owner token would
+// authenticate as anyone, so it is refused with "owner_token_not_allowed"
+// rather than handing the owner an edit link for someone else.
+func RevokeEditLinks(ctx context.Context, sessionID string, creds models.ParticipantCredentials) (*models.EditLinkResponse, error) {
+	ctx, end := startOp(ctx, "revoke_edit_links")
+	defer end()
+
+	if creds.OwnerToken != "" {
+		return nil, fmt.Errorf("owner_token_not_allowed")
+	}
+	if err := checkSessionOpen(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	name, err := authenticateParticipant(ctx, sessionID, creds)
+	if err != nil {
+		return nil, err
+	}
+	editKey, err := utils.GenerateToken(12)
+	if err != nil {
+		return nil, err
+	}
+	ipHash, err := eventIPHash(ctx, creds.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE participants SET edit_key = ? WHERE session_id = ? AND name = ?", editKey, sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordEventTx(ctx, tx, sessionID, EventEditLinksRevoked, name, ipHash, nil); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := IssueEditToken(ctx, sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+	return &models.EditLinkResponse{
+		EditToken:            token,
+		EditLink:             editLinkPath(sessionID, token),
+		EditLinkExpiresAtUTC: expiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// claimEditLinkMail reports whether the participant may be mailed an edit
+// link now, and if so starts a new EditLinkMailInterval for them.
+func claimEditLinkMail(ctx context.Context, sessionID, name string) (bool, error) {
+	now := time.Now().UTC()
+	res, err := db.DB.ExecContext(ctx, `
+		UPDATE participants SET edit_link_mailed_at_utc = ?
+		WHERE session_id = ? AND name = ? AND (edit_link_mailed_at_utc IS NULL OR edit_link_mailed_at_utc < ?)
+	`, now.Format(time.RFC3339), sessionID, name, now.Add(-EditLinkMailInterval).Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"biameet.ir/db"
+	"biameet.ir/logging"
+	"biameet.ir/models"
+)
+
+// EraseParticipant removes everything a participant put into a session:
+// their participants row, their votes, their comments and the timeslots
+// they proposed. Timeslots other people voted on stay, without the
+// creator's name and password, so those votes survive. An audit event records that an erasure
+// happened but not who was erased. Archived sessions can still be erased.
+func EraseParticipant(ctx context.Context, sessionID string, req models.EraseParticipantRequest) (*models.EraseParticipantResponse, error) {
+	ctx, end := startOp(ctx, "erase_participant")
+	defer end()
+
+	var exists bool
+	if err := db.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM sessions WHERE id = ?)", sessionID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	name, err := authenticateParticipant(ctx, sessionID, models.ParticipantCredentials{
+		VoterName:   req.VoterName,
+		Password:    req.Password,
+		EditToken:   req.EditToken,
+		InviteToken: req.InviteToken,
+		ClientIP:    req.ClientIP,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	resp := &models.EraseParticipantResponse{Status: "ok"}
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM votes
+		WHERE voter_name = ?
+		AND timeslot_id IN (SELECT id FROM timeslots WHERE session_id = ?)
+	`, name, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := res.RowsAffected()
+	resp.VotesDeleted = int(n)
+
+	// Their own votes are gone, so any vote left is someone else's
+	res, err = tx.ExecContext(ctx, `
+		DELETE FROM timeslots
+		WHERE session_id = ? AND created_by = ?
+		AND NOT EXISTS (SELECT 1 FROM votes WHERE votes.timeslot_id = timeslots.id)
+	`, sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+	n, _ = res.RowsAffected()
+	resp.TimeslotsDeleted = int(n)
+
+	res, err = tx.ExecContext(ctx, "UPDATE timeslots SET created_by = NULL, password_hash = NULL WHERE session_id = ? AND created_by = ?", sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+	n, _ = res.RowsAffected()
+	resp.TimeslotsAnonymized = int(n)
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM participants WHERE session_id = ? AND name = ?", sessionID, name); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM vote_versions WHERE session_id = ? AND voter_name = ?", sessionID, name); err != nil {
+		return nil, err
+	}
+	res, err = tx.ExecContext(ctx, "DELETE FROM comments WHERE session_id = ? AND author_name = ?", sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+	n, _ = res.RowsAffected()
+	resp.CommentsDeleted = int(n)
+
+	// The audit trail keeps what happened but forgets who did it
+	_, err = tx.ExecContext(ctx, "UPDATE session_events SET actor = NULL, ip_hash = NULL WHERE session_id = ? AND actor = ?", sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordEventTx(ctx, tx, sessionID, EventParticipantErased, "", "", map[string]interface{}{
+		"votes_deleted":        resp.VotesDeleted,
+		"timeslots_deleted":    resp.TimeslotsDeleted,
+		"timeslots_anonymized": resp.TimeslotsAnonymized,
+		"comments_deleted":     resp.CommentsDeleted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// The lockout row is keyed by the name too
+	if err := clearFailedAttempts(ctx, participantAttemptKeys(sessionID, name, req.ClientIP)); err != nil {
+		logging.FromContext(ctx).Error("failed to clear password attempts", "error", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"github.com/google/uuid"
+)
+
+// Event types written to session_events
+const (
+	EventSessionCreated    = "session_created"
+	EventTimeslotAdded     = "timeslot_added"
+	EventTimeslotDeleted   = "timeslot_deleted"
+	EventTimeslotUpdated   = "timeslot_updated"
+	EventVoteSubmitted     = "vote_submitted"
+	EventVotesReverted     = "votes_reverted"
+	EventParticipantErased = "participant_erased"
+	EventEditLinksRevoked  = "edit_links_revoked"
+)
+
+// recordEventTx appends to a session's audit trail inside tx, so the event
+// exists exactly when the change it describes does. ipHash comes from
+// eventIPHash, computed before tx was opened.
+func recordEventTx(ctx context.Context, tx *sql.Tx, sessionID, eventType, actor, ipHash string, details map[string]interface{}) error {
+	var detailsJSON sql.NullString
+	if len(details) > 0 {
+		bytes, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		detailsJSON = sql.NullString{String: string(bytes), Valid: true}
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO session_events (id, session_id, type, actor, ip_hash, details, created_at_utc)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, uuid.New().String(), sessionID, eventType, nullString(actor), nullString(ipHash), detailsJSON,
+		time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// eventIPHash returns a short HMAC of a client address under the server
+// secret. Unlike a plain hash it cannot be reversed by trying every IPv4
+// address. It may write the secret on first use, so call it outside of
+// transactions.
+func eventIPHash(ctx context.Context, ip string) (string, error) {
+	if ip == "" {
+		return "", nil
+	}
+	key, err := serverSecret(ctx)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("ip|" + ip))
+	return hex.EncodeToString(mac.Sum(nil))[:16], nil
+}
+
+// GetSessionHistory pages through a session's audit trail, newest first.
+func GetSessionHistory(ctx context.Context, sessionID string, q models.SessionHistoryQuery) (*models.SessionHistory, error) {
+	ctx, end := startOp(ctx, "get_session_history")
+	defer end()
+
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 || q.PerPage > 100 {
+		q.PerPage = 50
+	}
+
+	var exists bool
+	if err := db.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM sessions WHERE id = ?)", sessionID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	where := "session_id = ?"
+	args := []interface{}{sessionID}
+	if q.Type != "" {
+		where += " AND type = ?"
+		args = append(args, q.Type)
+	}
+
+	history := &models.SessionHistory{Events: []models.SessionEvent{}, Page: q.Page, PerPage: q.PerPage}
+	if err := db.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM session_events WHERE "+where, args...).Scan(&history.Total); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT id, type, actor, ip_hash, details, created_at_utc
+		FROM session_events WHERE `+where+`
+		ORDER BY created_at_utc DESC, rowid DESC
+		LIMIT ? OFFSET ?
+	`, append(args, q.PerPage, (q.Page-1)*q.PerPage)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e models.SessionEvent
+		var actor, ipHash, details sql.NullString
+		if err := rows.Scan(&e.ID, &e.Type, &actor, &ipHash, &details, &e.CreatedAtUTC); err != nil {
+			return nil, err
+		}
+		e.Actor, e.IPHash = actor.String, ipHash.String
+		if details.Valid {
+			e.Details = json.RawMessage(details.String)
+		}
+		history.Events = append(history.Events, e)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"biameet.ir/db"
+	"biameet.ir/models"
+)
+
+// ExportSessions dumps the given sessions, or all of them when ids is empty.
+func ExportSessions(ctx context.Context, ids []string) (*models.SessionExport, error) {
+	ctx, end := startOp(ctx, "export_sessions")
+	defer end()
+
+	if len(ids) == 0 {
+		rows, err := db.DB.QueryContext(ctx, "SELECT id FROM sessions ORDER BY created_at_utc")
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	export := &models.SessionExport{
+		FormatVersion: models.ExportFormatVersion,
+		ExportedAtUTC: time.Now().UTC().Format(time.RFC3339),
+		Sessions:      []models.ExportedSession{},
+	}
+	var err error
+	if export.SchemaVersion, err = db.SchemaVersion(ctx); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		s, err := exportSession(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		export.Sessions = append(export.Sessions, *s)
+	}
+	return export, nil
+}
+
+func exportSession(ctx context.Context, id string) (*models.ExportedSession, error) {
+	s := &models.ExportedSession{
+		Timeslots:    []models.ExportedTimeslot{},
+		Votes:        []models.ExportedVote{},
+		Participants: []models.ExportedParticipant{},
+		Invitees:     []models.ExportedInvitee{},
+	}
+	var expires, archived, sessionType, dynamicConfig, ownerHash, passHash, keyHash sql.NullString
+	var description, location, meetingURL sql.NullString
+	var duration sql.NullInt64
+	err := db.DB.QueryRowContext(ctx, `
+		SELECT id, title, creator_name, created_at_utc, expires_at_utc, archived_at_utc, type, dynamic_config, owner_token_hash, results_visibility,
+		       access_password_hash, access_key_hash, description, location, meeting_url, duration_minutes
+		FROM sessions WHERE id = ?
+	`, id).Scan(&s.ID, &s.Title, &s.CreatorName, &s.CreatedAtUTC, &expires, &archived, &sessionType, &dynamicConfig, &ownerHash, &s.Visibility,
+		&passHash, &keyHash, &description, &location, &meetingURL, &duration)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session not found: %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.ExpiresAtUTC, s.ArchivedAtUTC, s.Type = expires.String, archived.String, sessionType.String
+	s.DynamicConfig, s.OwnerTokenHash = dynamicConfig.String, ownerHash.String
+	s.AccessPassHash, s.AccessKeyHash = passHash.String, keyHash.String
+	s.Description, s.Location, s.MeetingURL = description.String, location.String, meetingURL.String
+	s.DurationMinutes = int(duration.Int64)
+
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT id, start_utc, end_utc, created_by, password_hash, created_at_utc, updated_at_utc
+		FROM timeslots WHERE session_id = ? ORDER BY start_utc
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var ts models.ExportedTimeslot
+		var createdBy, hash, createdAt, updatedAt sql.NullString
+		if err := rows.Scan(&ts.ID, &ts.StartUTC, &ts.EndUTC, &createdBy, &hash, &createdAt, &updatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ts.CreatedBy, ts.PasswordHash, ts.CreatedAtUTC = createdBy.String, hash.String, createdAt.String
+		ts.UpdatedAtUTC = updatedAt.String
+		s.Timeslots = append(s.Timeslots, ts)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.QueryContext(ctx, `
+		SELECT v.id, v.timeslot_id, v.voter_name, v.note, v.created_at_utc
+		FROM votes v JOIN timeslots t ON t.id = v.timeslot_id
+		WHERE t.session_id = ? ORDER BY v.created_at_utc
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var v models.ExportedVote
+		var note sql.NullString
+		if err := rows.Scan(&v.ID, &v.TimeslotID, &v.VoterName, &note, &v.CreatedAtUTC); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		v.Note = note.String
+		s.Votes = append(s.Votes, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.QueryContext(ctx, "SELECT name, password_hash, created_at_utc, timeslot_changed_at_utc FROM participants WHERE session_id = ? ORDER BY created_at_utc", id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var p models.ExportedParticipant
+		var hash, changedAt sql.NullString
+		if err := rows.Scan(&p.Name, &hash, &p.CreatedAtUTC, &changedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		p.PasswordHash, p.TimeslotChangedAtUTC = hash.String, changedAt.String
+		s.Participants = append(s.Participants, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.QueryContext(ctx, `
+		SELECT id, author_name, body, password_hash, created_at_utc, updated_at_utc
+		FROM comments WHERE session_id = ? ORDER BY created_at_utc, rowid
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var c models.ExportedComment
+		var hash, updated sql.NullString
+		if err := rows.Scan(&c.ID, &c.AuthorName, &c.Body, &hash, &c.CreatedAtUTC, &updated); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		c.PasswordHash, c.UpdatedAtUTC = hash.String, updated.String
+		s.Comments = append(s.Comments, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.QueryContext(ctx, "SELECT id, name, token_hash, created_at_utc, responded_at_utc FROM invitees WHERE session_id = ? ORDER BY created_at_utc", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var inv models.ExportedInvitee
+		var responded sql.NullString
+		if err := rows.Scan(&inv.ID, &inv.Name, &inv.TokenHash, &inv.CreatedAtUTC, &responded); err != nil {
+			return nil, err
+		}
+		inv.RespondedAtUTC = responded.String
+		s.Invitees = append(s.Invitees, inv)
+	}
+	return s, rows.Err()
+}
+
+// ImportSessions loads an export in one transaction. Sessions whose ID
+// already exists are skipped, or replaced when replace is set.
+func ImportSessions(ctx context.Context, export *models.SessionExport, replace bool) (*models.ImportResult, error) {
+	ctx, end := startOp(ctx, "import_sessions")
+	defer end()
+
+	if export.FormatVersion != models.ExportFormatVersion {
+		return nil, fmt.Errorf("unsupported export format version %d", export.FormatVersion)
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &models.ImportResult{Imported: []string{}, Skipped: []string{}}
+	for _, s := range export.Sessions {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM sessions WHERE id = ?)", s.ID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists && !replace {
+			result.Skipped = append(result.Skipped, s.ID)
+			continue
+		}
+		if exists {
+			if err := deleteSessionTx(ctx, tx, s.ID); err != nil {
+				return nil, err
+			}
+		}
+		if err := importSessionTx(ctx, tx, s); err != nil {
+			return nil, fmt.Errorf("session %s: %w", s.ID, err)
+		}
+		result.Imported = append(result.Imported, s.ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func importSessionTx(ctx context.Context, tx *sql.Tx, s models.ExportedSession) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO sessions (id, title, creator_name, created_at_utc, expires_at_utc, archived_at_utc, type, dynamic_config, owner_token_hash, results_visibility,
+		                      access_password_hash, access_key_hash, description, location, meeting_url, duration_minutes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.ID, s.Title, s.CreatorName, s.CreatedAtUTC, nullString(s.ExpiresAtUTC), nullString(s.ArchivedAtUTC),
+		s.Type, s.DynamicConfig, nullString(s.OwnerTokenHash), visibilityOrDefault(s.Visibility),
+		nullString(s.AccessPassHash), nullString(s.AccessKeyHash), nullString(s.Description), nullString(s.Location),
+		nullString(s.MeetingURL), sql.NullInt64{Int64: int64(s.DurationMinutes), Valid: s.DurationMinutes > 0})
+	if err != nil {
+		return err
+	}
+
+	for _, ts := range s.Timeslots {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO timeslots (id, session_id, start_utc, end_utc, created_by, password_hash, created_at_utc, updated_at_utc)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, ts.ID, s.ID, ts.StartUTC, ts.EndUTC, nullString(ts.CreatedBy), nullString(ts.PasswordHash), nullString(ts.CreatedAtUTC),
+			nullString(ts.UpdatedAtUTC))
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range s.Votes {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO votes (id, timeslot_id, voter_name, note, created_at_utc)
+			VALUES (?, ?, ?, ?, ?)
+		`, v.ID, v.TimeslotID, v.VoterName, v.Note, v.CreatedAtUTC)
+		if err != nil {
+			return err
+		}
+	}
+	for _, p := range s.Participants {
+		_, err = tx.ExecContext(ctx, "INSERT INTO participants (session_id, name, password_hash, created_at_utc, timeslot_changed_at_utc) VALUES (?, ?, ?, ?, ?)",
+			s.ID, p.Name, nullString(p.PasswordHash), p.CreatedAtUTC, nullString(p.TimeslotChangedAtUTC))
+		if err != nil {
+			return err
+		}
+	}
+	for _, inv := range s.Invitees {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO invitees (id, session_id, name, token_hash, created_at_utc, responded_at_utc)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, inv.ID, s.ID, inv.Name, inv.TokenHash, inv.CreatedAtUTC, nullString(inv.RespondedAtUTC))
+		if err != nil {
+			return err
+		}
+	}
+	for _, c := range s.Comments {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO comments (id, session_id, author_name, body, password_hash, created_at_utc, updated_at_utc)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, c.ID, s.ID, c.AuthorName, c.Body, nullString(c.PasswordHash), c.CreatedAtUTC, nullString(c.UpdatedAtUTC))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Exports from before results visibility existed are public.
+func visibilityOrDefault(v string) string {
+	if v == "" {
+		return models.VisibilityPublic
+	}
+	return v
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
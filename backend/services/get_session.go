@@ -1,27 +1,38 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 
 	"biameet.ir/db"
 	"biameet.ir/models"
+	"biameet.ir/utils"
 )
 
-func GetSession(id string) (*models.Session, error) {
+func GetSession(ctx context.Context, id string) (*models.Session, error) {
+	ctx, end := startOp(ctx, "get_session")
+	defer end()
+
 	var session models.Session
 
 	var expiresAt, archivedAt, dynamicConfigJSON sql.NullString
 	var sessionType sql.NullString
+	var description, location, meetingURL sql.NullString
+	var duration sql.NullInt64
 
 	// 1. Get Session
-	err := db.DB.QueryRow(`
-		SELECT id, title, creator_name, created_at_utc, expires_at_utc, archived_at_utc, type, dynamic_config
+	err := db.DB.QueryRowContext(ctx, `
+		SELECT id, title, creator_name, created_at_utc, expires_at_utc, archived_at_utc, type, dynamic_config, results_visibility,
+			access_password_hash IS NOT NULL OR access_key_hash IS NOT NULL,
+			description, location, meeting_url, duration_minutes
 		FROM sessions WHERE id = ?
 	`, id).Scan(
 		&session.ID, &session.Title, &session.CreatorName, &session.CreatedAtUTC,
-		&expiresAt, &archivedAt, &sessionType, &dynamicConfigJSON,
+		&expiresAt, &archivedAt, &sessionType, &dynamicConfigJSON, &session.ResultsVisibility,
+		&session.Private,
+		&description, &location, &meetingURL, &duration,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -39,6 +50,9 @@ func GetSession(id string) (*models.Session, error) {
 	if sessionType.Valid {
 		session.Type = sessionType.String
 	}
+	session.Description, session.Location, session.MeetingURL = description.String, location.String, meetingURL.String
+	session.DurationMinutes = int(duration.Int64)
+	session.DescriptionHTML = utils.RenderMarkdown(session.Description)
 	if dynamicConfigJSON.Valid && dynamicConfigJSON.String != "" {
 		var config models.DynamicConfig
 		if err := json.Unmarshal([]byte(dynamicConfigJSON.String), &config); err == nil {
@@ -47,8 +61,8 @@ func GetSession(id string) (*models.Session, error) {
 	}
 
 	// 2. Get Timeslots
-	rows, err := db.DB.Query(`
-		SELECT id, session_id, start_utc, end_utc, created_by
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT id, session_id, start_utc, end_utc, created_by, updated_at_utc
 		FROM timeslots WHERE session_id = ?
 	`, id)
 	if err != nil {
@@ -61,10 +75,11 @@ func GetSession(id string) (*models.Session, error) {
 
 	for rows.Next() {
 		var ts models.Timeslot
-		var createdBy sql.NullString
-		if err := rows.Scan(&ts.ID, &ts.SessionID, &ts.StartUTC, &ts.EndUTC, &createdBy); err != nil {
+		var createdBy, updatedAt sql.NullString
+		if err := rows.Scan(&ts.ID, &ts.SessionID, &ts.StartUTC, &ts.EndUTC, &createdBy, &updatedAt); err != nil {
 			return nil, err
 		}
+		ts.UpdatedAtUTC = updatedAt.String
 		if createdBy.Valid {
 			ts.CreatedBy = createdBy.String
 		}
@@ -91,7 +106,7 @@ func GetSession(id string) (*models.Session, error) {
 	// Since we don't have session_id in votes, we need to join or use IN clause.
 	// Simple approach: Iterate timeslots (if few) or use IN.
 	// Let's use a JOIN with timeslots to filter by session_id
-	voteRows, err := db.DB.Query(`
+	voteRows, err := db.DB.QueryContext(ctx, `
 		SELECT v.id, v.timeslot_id, v.voter_name, v.note, v.created_at_utc
 		FROM votes v
 		JOIN timeslots t ON v.timeslot_id = t.id
@@ -117,5 +132,102 @@ func GetSession(id string) (*models.Session, error) {
 	}
 
 	session.Timeslots = timeslots
+
+	// 4. Get the comment thread
+	if session.Comments, err = sessionComments(ctx, id); err != nil {
+		return nil, err
+	}
 	return &session, nil
 }
+
+// GetSessionForViewer is GetSession as the public API shows it: votes are
+// withheld according to the session's results visibility and who is asking.
+// Viewers always see their own votes so they can edit them.
+func GetSessionForViewer(ctx context.Context, id string, viewer models.SessionViewer) (*models.Session, error) {
+	session, err := GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if viewer.Name != "" {
+		var changedAt sql.NullString
+		err := db.DB.QueryRowContext(ctx, "SELECT timeslot_changed_at_utc FROM participants WHERE session_id = ? AND name = ?",
+			id, viewer.Name).Scan(&changedAt)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		session.TimeslotsChangedAtUTC = changedAt.String
+	}
+
+	showNames, showCounts := true, true
+	switch session.ResultsVisibility {
+	case models.VisibilityAnonymous:
+		showNames = false
+	case models.VisibilityAfterVote:
+		if !viewer.IsOwner && !hasVoted(session, viewer.Name) {
+			showNames, showCounts = false, false
+		}
+	case models.VisibilityOwnerOnly:
+		if !viewer.IsOwner {
+			showNames, showCounts = false, false
+		}
+	}
+
+	for i := range session.Timeslots {
+		ts := &session.Timeslots[i]
+		if showCounts {
+			count := len(ts.Votes)
+			ts.VoteCount = &count
+		}
+		if showNames {
+			continue
+		}
+		session.ResultsHidden = true
+		own := []models.Vote{}
+		for _, v := range ts.Votes {
+			if viewer.Name != "" && v.VoterName == viewer.Name {
+				own = append(own, v)
+			}
+		}
+		ts.Votes = own
+		if ts.CreatedBy != viewer.Name {
+			ts.CreatedBy = ""
+		}
+	}
+	return session, nil
+}
+
+func hasVoted(session *models.Session, name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, ts := range session.Timeslots {
+		for _, v := range ts.Votes {
+			if v.VoterName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ResolveViewer works out who is reading a session from the owner, edit
+// and invite tokens sent with the request. Bad tokens are not an error on
+// reads; the viewer just stays anonymous.
+func ResolveViewer(ctx context.Context, sessionID, ownerToken, editToken, inviteToken string) models.SessionViewer {
+	var viewer models.SessionViewer
+	if ownerToken != "" && VerifyOwnerToken(ctx, sessionID, ownerToken) == nil {
+		viewer.IsOwner = true
+	}
+	if editToken != "" {
+		if name, err := parseEditToken(ctx, sessionID, editToken); err == nil {
+			viewer.Name = name
+		}
+	}
+	if viewer.Name == "" && inviteToken != "" {
+		if _, name, err := inviteeByToken(ctx, sessionID, inviteToken); err == nil {
+			viewer.Name = name
+		}
+	}
+	return viewer
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"time"
+
+	"biameet.ir/models"
+	"biameet.ir/utils"
+)
+
+type calendarDay struct {
+	month, day int
+	name       string
+}
+
+// Official holidays on fixed days of the Jalali calendar
+var solarHolidays = []calendarDay{
+	{1, 1, "نوروز"},
+	{1, 2, "نوروز"},
+	{1, 3, "نوروز"},
+	{1, 4, "نوروز"},
+	{1, 12, "روز جمهوری اسلامی"},
+	{1, 13, "روز طبیعت"},
+	{3, 14, "رحلت امام خمینی"},
+	{3, 15, "قیام ۱۵ خرداد"},
+	{11, 22, "پیروزی انقلاب اسلامی"},
+	{12, 29, "ملی شدن صنعت نفت"},
+}
+
+// Official holidays of the lunar Hijri calendar. Day 0 of a month is the
+// last day of the month before, which has 29 or 30 days.
+var lunarHolidays = []calendarDay{
+	{1, 9, "تاسوعا"},
+	{1, 10, "عاشورا"},
+	{2, 20, "اربعین"},
+	{2, 28, "رحلت پیامبر و شهادت امام حسن"},
+	{3, 0, "شهادت امام رضا"},
+	{3, 8, "شهادت امام حسن عسکری"},
+	{3, 17, "میلاد پیامبر و امام صادق"},
+	{6, 3, "شهادت حضرت فاطمه"},
+	{7, 13, "ولادت امام علی"},
+	{7, 27, "مبعث"},
+	{8, 15, "ولادت امام مهدی"},
+	{9, 21, "شهادت امام علی"},
+	{10, 1, "عید فطر"},
+	{10, 2, "عید فطر"},
+	{10, 25, "شهادت امام صادق"},
+	{12, 10, "عید قربان"},
+	{12, 18, "عید غدیر"},
+}
+
+// iranianHolidays returns the public holidays between from and to
+// (inclusive, both at midnight UTC) by date. Lunar holidays follow the
+// tabular Islamic calendar and can be a day off from the announced ones.
+func iranianHolidays(from, to time.Time) map[string]models.Holiday {
+	holidays := map[string]models.Holiday{}
+	add := func(date time.Time, name string) {
+		if date.Before(from) || date.After(to) {
+			return
+		}
+		key := date.Format("2006-01-02")
+		if h, ok := holidays[key]; ok && h.Name != name {
+			name = h.Name + "، " + name
+		}
+		holidays[key] = models.Holiday{Date: key, Name: name}
+	}
+
+	// A Jalali year starts in March, so one Gregorian year touches two
+	for jy := from.Year() - 622; jy <= to.Year()-621; jy++ {
+		for _, h := range solarHolidays {
+			add(utils.JalaliToGregorian(jy, h.month, h.day), h.name)
+		}
+	}
+	for hy := utils.GregorianToHijriYear(from); hy <= utils.GregorianToHijriYear(to); hy++ {
+		for _, h := range lunarHolidays {
+			add(utils.HijriToGregorian(hy, h.month, 1).AddDate(0, 0, h.day-1), h.name)
+		}
+	}
+	return holidays
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/utils"
+	"github.com/google/uuid"
+)
+
+// CreateInvitees issues one personal link per name. The raw tokens are only
+// returned here; the database keeps their hashes.
+func CreateInvitees(ctx context.Context, sessionID string, names []string) ([]models.Invitee, error) {
+	ctx, end := startOp(ctx, "create_invitees")
+	defer end()
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	invitees := []models.Invitee{}
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		// A password-protected participant keeps their name; an invite link
+		// for it would bypass the password.
+		holder, protected, err := nameHolderTx(ctx, tx, sessionID, name)
+		if err != nil {
+			return nil, err
+		}
+		if holder != "" || protected {
+			return nil, fmt.Errorf("invitee_name_taken")
+		}
+
+		token, err := utils.GenerateToken(24)
+		if err != nil {
+			return nil, err
+		}
+
+		inv := models.Invitee{
+			ID:           uuid.New().String(),
+			SessionID:    sessionID,
+			Name:         name,
+			Token:        token,
+			Link:         invitePath(sessionID, token),
+			CreatedAtUTC: createdAt,
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO invitees (id, session_id, name, token_hash, created_at_utc)
+			VALUES (?, ?, ?, ?, ?)
+		`, inv.ID, sessionID, name, utils.HashToken(token), createdAt)
+		if err != nil {
+			return nil, err
+		}
+		invitees = append(invitees, inv)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return invitees, nil
+}
+
+// inviteeByToken resolves a personal link token to its invitee. With the
+// invitees feature off, no link is valid.
+func inviteeByToken(ctx context.Context, sessionID, token string) (id, name string, err error) {
+	if !config.Get().Features.Invitees {
+		return "", "", fmt.Errorf("invalid_invite_token")
+	}
+	err = db.DB.QueryRowContext(ctx, "SELECT id, name FROM invitees WHERE session_id = ? AND token_hash = ?",
+		sessionID, utils.HashToken(token)).Scan(&id, &name)
+	if err == sql.ErrNoRows {
+		return "", "", fmt.Errorf("invalid_invite_token")
+	}
+	return id, name, err
+}
+
+// nameHolderTx reports who already holds name in the session: the invitee it
+// is reserved for, if any, and whether a participant protects it with a
+// password. Only that invitee's link or that password may claim the name.
+func nameHolderTx(ctx context.Context, tx *sql.Tx, sessionID, name string) (inviteeID string, protected bool, err error) {
+	err = tx.QueryRowContext(ctx, "SELECT id FROM invitees WHERE session_id = ? AND name = ?", sessionID, name).Scan(&inviteeID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", false, err
+	}
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM participants
+		WHERE session_id = ? AND name = ? AND password_hash IS NOT NULL AND password_hash != '')
+	`, sessionID, name).Scan(&protected)
+	if err != nil {
+		return "", false, err
+	}
+	return inviteeID, protected, nil
+}
+
+func ListInvitees(ctx context.Context, sessionID string) ([]models.Invitee, error) {
+	ctx, end := startOp(ctx, "list_invitees")
+	defer end()
+
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT id, session_id, name, created_at_utc, responded_at_utc
+		FROM invitees WHERE session_id = ?
+		ORDER BY created_at_utc, name
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitees := []models.Invitee{}
+	for rows.Next() {
+		var inv models.Invitee
+		var respondedAt sql.NullString
+		if err := rows.Scan(&inv.ID, &inv.SessionID, &inv.Name, &inv.CreatedAtUTC, &respondedAt); err != nil {
+			return nil, err
+		}
+		if respondedAt.Valid {
+			inv.RespondedAtUTC = respondedAt.String
+			inv.Responded = true
+		}
+		invitees = append(invitees, inv)
+	}
+	return invitees, rows.Err()
+}
+
+// DeleteInvitee revokes a personal link. Votes already cast stay in place.
+func DeleteInvitee(ctx context.Context, sessionID, inviteeID string) error {
+	ctx, end := startOp(ctx, "delete_invitee")
+	defer end()
+
+	res, err := db.DB.ExecContext(ctx, "DELETE FROM invitees WHERE id = ? AND session_id = ?", inviteeID, sessionID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("invitee not found")
+	}
+	return nil
+}
+
+func invitePath(sessionID, token string) string {
+	return "/" + sessionID + "?invite=" + token
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"biameet.ir/config"
+)
+
+// BaseURL is the public origin used to build absolute links in emails.
+func BaseURL() string {
+	return strings.TrimRight(config.Get().Server.BaseURL, "/")
+}
+
+// MailEnabled reports whether an SMTP host is configured.
+func MailEnabled() bool {
+	return config.Get().Mail.SMTPHost != ""
+}
+
+// SendMail delivers a plain-text UTF-8 email through the configured SMTP
+// server. to may carry a display name; only the parsed address is used.
+func SendMail(to, subject, body string) error {
+	cfg := config.Get().Mail
+	if cfg.SMTPHost == "" {
+		return fmt.Errorf("mail is not configured")
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if cfg.SMTPUser != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+
+	msg := "From: " + cfg.From + "\r\n" +
+		"To: " + rcpt.Address + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+	addr := cfg.SMTPHost + ":" + strconv.Itoa(cfg.SMTPPort)
+	return smtp.SendMail(addr, auth, cfg.From, []string{rcpt.Address}, []byte(msg))
+}
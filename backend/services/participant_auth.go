@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authenticateParticipant resolves the participant creds speak for. The
+// owner token lets the session owner act for any participant by name; an
+// invite or edit token names the participant itself; otherwise the
+// participant's password is checked with the usual lockout. Errors match
+// SubmitVote's.
+func authenticateParticipant(ctx context.Context, sessionID string, creds models.ParticipantCredentials) (string, error) {
+	name := creds.VoterName
+	ownerAuth, inviteAuth, editAuth := false, false, false
+	switch {
+	case creds.OwnerToken != "":
+		if err := VerifyOwnerToken(ctx, sessionID, creds.OwnerToken); err != nil {
+			return "", err
+		}
+		ownerAuth = true
+	case creds.InviteToken != "":
+		var err error
+		if _, name, err = inviteeByToken(ctx, sessionID, creds.InviteToken); err != nil {
+			return "", err
+		}
+		inviteAuth = true
+	case creds.EditToken != "":
+		var err error
+		if name, err = parseEditToken(ctx, sessionID, creds.EditToken); err != nil {
+			return "", err
+		}
+		editAuth = true
+	}
+
+	var storedHash sql.NullString
+	err := db.DB.QueryRowContext(ctx, "SELECT password_hash FROM participants WHERE session_id = ? AND name = ?", sessionID, name).Scan(&storedHash)
+	if err == sql.ErrNoRows {
+		if editAuth {
+			return "", fmt.Errorf("invalid_edit_token")
+		}
+		// An invitee who has not voted yet has nothing to act on
+		return "", fmt.Errorf("participant not found")
+	}
+	if err != nil {
+		return "", err
+	}
+	if ownerAuth || inviteAuth || editAuth {
+		return name, nil
+	}
+
+	// Without a password anyone could claim the name
+	if !storedHash.Valid || storedHash.String == "" {
+		return "", fmt.Errorf("name_taken_no_password")
+	}
+	if creds.Password == "" {
+		return "", fmt.Errorf("password_required")
+	}
+	attemptKeys := participantAttemptKeys(sessionID, name, creds.ClientIP)
+	if err := checkAttempts(ctx, attemptKeys); err != nil {
+		return "", err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(storedHash.String), []byte(creds.Password)); err != nil {
+		if err := recordFailedAttempt(ctx, attemptKeys); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("invalid_password")
+	}
+	return name, nil
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/utils"
+)
+
+const powChallengeTTL = 5 * time.Minute
+
+// PowDifficulty is the number of leading zero bits session creation must
+// prove, from security.pow_difficulty. Zero (the default) turns the check off.
+func PowDifficulty() int {
+	return config.Get().Security.PowDifficulty
+}
+
+// IssuePowChallenge returns a signed, short-lived challenge. The client must
+// find a nonce such that sha256(challenge + ":" + nonce) starts with
+// Difficulty zero bits.
+func IssuePowChallenge(ctx context.Context) (*models.PowChallenge, error) {
+	ctx, end := startOp(ctx, "issue_pow_challenge")
+	defer end()
+
+	difficulty := PowDifficulty()
+	if difficulty == 0 {
+		return &models.PowChallenge{Difficulty: 0}, nil
+	}
+
+	key, err := serverSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	random, err := utils.GenerateToken(12)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().UTC().Add(powChallengeTTL).Truncate(time.Second)
+	payload := fmt.Sprintf("pow|%s|%d|%d", random, expiresAt.Unix(), difficulty)
+
+	return &models.PowChallenge{
+		Challenge:    utils.Sign(key, payload),
+		Difficulty:   difficulty,
+		ExpiresAtUTC: expiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// VerifyProofOfWork checks a solved challenge. It does not spend it;
+// CreateSession does that together with the insert, so a failed creation
+// leaves the challenge usable.
+func VerifyProofOfWork(ctx context.Context, challenge, nonce string) error {
+	ctx, end := startOp(ctx, "verify_pow")
+	defer end()
+
+	if challenge == "" || nonce == "" {
+		return fmt.Errorf("pow_required")
+	}
+	key, err := serverSecret(ctx)
+	if err != nil {
+		return err
+	}
+	payload, ok := utils.VerifySigned(key, challenge)
+	if !ok {
+		return fmt.Errorf("invalid_pow")
+	}
+	parts := strings.Split(payload, "|")
+	if len(parts) != 4 || parts[0] != "pow" {
+		return fmt.Errorf("invalid_pow")
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid_pow")
+	}
+	difficulty, err := strconv.Atoi(parts[3])
+	// Challenges issued before the difficulty was raised no longer count
+	if err != nil || difficulty < PowDifficulty() {
+		return fmt.Errorf("invalid_pow")
+	}
+	if time.Now().Unix() > expires {
+		return fmt.Errorf("pow_expired")
+	}
+
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	if leadingZeroBits(sum[:]) < difficulty {
+		return fmt.Errorf("invalid_pow")
+	}
+
+	var used bool
+	err = db.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM used_pow_challenges WHERE challenge_hash = ?)",
+		utils.HashToken(challenge)).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used {
+		return fmt.Errorf("pow_reused")
+	}
+	return nil
+}
+
+// spendPowChallengeTx marks a verified challenge as used inside tx. Each
+// challenge is good for one session. The row only has to outlive the
+// challenge, so it is kept for a full TTL.
+func spendPowChallengeTx(ctx context.Context, tx *sql.Tx, challenge string) error {
+	now := time.Now().UTC()
+	_, err := tx.ExecContext(ctx, "DELETE FROM used_pow_challenges WHERE expires_at_utc < ?", now.Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO used_pow_challenges (challenge_hash, expires_at_utc) VALUES (?, ?)",
+		utils.HashToken(challenge), now.Add(powChallengeTTL).Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("pow_reused")
+	}
+	return nil
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, x := range b {
+		if x != 0 {
+			return n + bits.LeadingZeros8(x)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"biameet.ir/db"
+)
+
+// PurgeSessions deletes sessions created before the cutoff, with all their
+// timeslots, votes, participants and invitees. With dryRun it only reports
+// which sessions would go.
+func PurgeSessions(ctx context.Context, before time.Time, dryRun bool) ([]string, error) {
+	ctx, end := startOp(ctx, "purge_sessions")
+	defer end()
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM sessions WHERE created_at_utc < ? ORDER BY created_at_utc",
+		before.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return ids, nil
+	}
+	for _, id := range ids {
+		if err := deleteSessionTx(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit()
+}
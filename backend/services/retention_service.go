@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/logging"
+	"biameet.ir/metrics"
+	"biameet.ir/models"
+)
+
+// ApplyRetention deletes the sessions that the policy expires at now, with
+// their timeslots, votes, participants and invitees, and forgets expired
+// password attempts. With dryRun nothing is deleted and the report says
+// which sessions would have been.
+func ApplyRetention(ctx context.Context, policy config.RetentionConfig, now time.Time, dryRun bool) (*models.RetentionReport, error) {
+	ctx, end := startOp(ctx, "apply_retention")
+	defer end()
+
+	report := &models.RetentionReport{
+		DryRun:   dryRun,
+		RunAtUTC: now.UTC().Format(time.RFC3339),
+		Sessions: []models.RetainedSession{},
+	}
+	if !policy.Enabled() {
+		return report, nil
+	}
+
+	// An empty cutoff disables its rule
+	var inactiveCutoff, slotCutoff string
+	if policy.InactiveDays > 0 {
+		inactiveCutoff = now.AddDate(0, 0, -policy.InactiveDays).UTC().Format(time.RFC3339)
+	}
+	if policy.AfterLastSlotDays > 0 {
+		slotCutoff = now.AddDate(0, 0, -policy.AfterLastSlotDays).UTC().Format(time.RFC3339)
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, title, last_activity, last_slot_end FROM (
+			SELECT s.id, s.title,
+				MAX(s.created_at_utc,
+					COALESCE((SELECT MAX(MAX(created_at_utc, COALESCE(updated_at_utc, ''))) FROM timeslots WHERE session_id = s.id), ''),
+					COALESCE((SELECT MAX(v.created_at_utc) FROM votes v JOIN timeslots t ON t.id = v.timeslot_id WHERE t.session_id = s.id), ''),
+					COALESCE((SELECT MAX(created_at_utc) FROM participants WHERE session_id = s.id), ''),
+					COALESCE((SELECT MAX(MAX(created_at_utc, COALESCE(updated_at_utc, ''))) FROM comments WHERE session_id = s.id), '')
+				) AS last_activity,
+				(SELECT MAX(end_utc) FROM timeslots WHERE session_id = s.id) AS last_slot_end
+			FROM sessions s
+		)
+		WHERE (? != '' AND last_activity < ?) OR (? != '' AND last_slot_end < ?)
+		ORDER BY last_activity
+	`, inactiveCutoff, inactiveCutoff, slotCutoff, slotCutoff)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var s models.RetainedSession
+		var lastSlot sql.NullString
+		if err := rows.Scan(&s.ID, &s.Title, &s.LastActivityUTC, &lastSlot); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.LastSlotEndUTC = lastSlot.String
+		s.Reason = "inactive"
+		if inactiveCutoff == "" || s.LastActivityUTC >= inactiveCutoff {
+			s.Reason = "last_slot_passed"
+		}
+		report.Sessions = append(report.Sessions, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	for _, s := range report.Sessions {
+		if err := deleteSessionTx(ctx, tx, s.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	for _, s := range report.Sessions {
+		metrics.SessionsPurged.WithLabelValues(s.Reason).Inc()
+	}
+	if report.PrunedAuthFailures, err = pruneAuthFailures(ctx, now); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// RunRetention applies the policy every policy.Interval until ctx is done.
+func RunRetention(ctx context.Context, policy config.RetentionConfig) {
+	logger := logging.FromContext(ctx)
+	run := func() {
+		report, err := ApplyRetention(ctx, policy, time.Now(), policy.DryRun)
+		if err != nil {
+			logger.Error("retention run failed", "error", err)
+			return
+		}
+		for _, s := range report.Sessions {
+			logger.Info("retention", "dry_run", report.DryRun, "session_id", s.ID,
+				"reason", s.Reason, "last_activity_utc", s.LastActivityUTC)
+		}
+	}
+
+	run()
+	ticker := time.NewTicker(policy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run()
+		}
+	}
+}
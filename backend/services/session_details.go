@@ -0,0 +1,37 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"biameet.ir/models"
+)
+
+// NormalizeSessionDetails trims d in place and checks it against the
+// limits in models. Errors are "description_too_long", "location_too_long",
+// "invalid_meeting_url" and "invalid_duration".
+func NormalizeSessionDetails(d *models.SessionDetails) error {
+	d.Description = strings.TrimSpace(d.Description)
+	d.Location = strings.TrimSpace(d.Location)
+	d.MeetingURL = strings.TrimSpace(d.MeetingURL)
+
+	if utf8.RuneCountInString(d.Description) > models.MaxDescriptionLength {
+		return fmt.Errorf("description_too_long")
+	}
+	if utf8.RuneCountInString(d.Location) > models.MaxLocationLength {
+		return fmt.Errorf("location_too_long")
+	}
+	if d.MeetingURL != "" {
+		// Only web links; javascript: and friends would end up in an href
+		u, err := url.Parse(d.MeetingURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(d.MeetingURL) > models.MaxMeetingURLLength {
+			return fmt.Errorf("invalid_meeting_url")
+		}
+	}
+	if d.DurationMinutes < 0 || d.DurationMinutes > models.MaxDurationMinutes {
+		return fmt.Errorf("invalid_duration")
+	}
+	return nil
+}
@@ -1,28 +1,60 @@
 package services
 
 import (
+	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"time"
 
+	"biameet.ir/config"
 	"biameet.ir/db"
+	"biameet.ir/logging"
+	"biameet.ir/metrics"
 	"biameet.ir/models"
 	"biameet.ir/utils"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func CreateSession(req models.CreateSessionRequest) (*models.CreateSessionResponse, error) {
-	sessionID := utils.GenerateShortID(5)
+func CreateSession(ctx context.Context, req models.CreateSessionRequest) (*models.CreateSessionResponse, error) {
+	ctx, end := startOp(ctx, "create_session")
+	defer end()
+
+	// Viewers prove they voted with their edit link, so after_vote would
+	// hide the results from everyone but invitees without one
+	if req.ResultsVisibility == models.VisibilityAfterVote && !config.Get().Features.EditLinks {
+		return nil, fmt.Errorf("after_vote_requires_edit_links")
+	}
+
 	createdAt := time.Now().UTC().Format(time.RFC3339)
 
-	tx, err := db.DB.Begin()
+	ownerToken, err := utils.GenerateToken(24)
+	if err != nil {
+		return nil, err
+	}
+	ipHash, err := eventIPHash(ctx, req.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	sessionID, err := newSessionID(ctx, tx, req.Slug)
+	if err != nil {
+		return nil, err
+	}
+	if req.PowChallenge != "" {
+		if err := spendPowChallengeTx(ctx, tx, req.PowChallenge); err != nil {
+			return nil, err
+		}
+	}
+
 	// Serialize DynamicConfig
 	var dynamicConfigJSON string
 	if req.DynamicConfig != nil {
@@ -39,11 +71,36 @@ func CreateSession(req models.CreateSessionRequest) (*models.CreateSessionRespon
 		sessionType = "fixed"
 	}
 
+	visibility := req.ResultsVisibility
+	if visibility == "" {
+		visibility = models.VisibilityPublic
+	}
+
+	// Private sessions
+	var accessPasswordHash, accessKeyHash sql.NullString
+	var accessKey string
+	if req.AccessPassword != "" {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(req.AccessPassword), config.Get().Security.BcryptCost)
+		if err != nil {
+			return nil, err
+		}
+		accessPasswordHash = sql.NullString{String: string(bytes), Valid: true}
+	}
+	if req.PrivateLink {
+		if accessKey, err = utils.GenerateToken(18); err != nil {
+			return nil, err
+		}
+		accessKeyHash = sql.NullString{String: utils.HashToken(accessKey), Valid: true}
+	}
+
 	// Insert Session
-	_, err = tx.Exec(`
-		INSERT INTO sessions (id, title, creator_name, created_at_utc, type, dynamic_config)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, sessionID, req.Title, req.CreatorName, createdAt, sessionType, dynamicConfigJSON)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sessions (id, title, creator_name, created_at_utc, type, dynamic_config, owner_token_hash, results_visibility,
+			access_password_hash, access_key_hash, description, location, meeting_url, duration_minutes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, sessionID, req.Title, req.CreatorName, createdAt, sessionType, dynamicConfigJSON, utils.HashToken(ownerToken), visibility,
+		accessPasswordHash, accessKeyHash, nullString(req.Description), nullString(req.Location), nullString(req.MeetingURL),
+		sql.NullInt64{Int64: int64(req.DurationMinutes), Valid: req.DurationMinutes > 0})
 	if err != nil {
 		return nil, err
 	}
@@ -51,29 +108,77 @@ func CreateSession(req models.CreateSessionRequest) (*models.CreateSessionRespon
 	// Insert Timeslots
 	for _, ts := range req.Timeslots {
 		tsID := uuid.New().String()
-		_, err = tx.Exec(`
-			INSERT INTO timeslots (id, session_id, start_utc, end_utc)
-			VALUES (?, ?, ?, ?)
-		`, tsID, sessionID, ts.StartUTC, ts.EndUTC)
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO timeslots (id, session_id, start_utc, end_utc, created_at_utc)
+			VALUES (?, ?, ?, ?, ?)
+		`, tsID, sessionID, ts.StartUTC, ts.EndUTC, createdAt)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	err = recordEventTx(ctx, tx, sessionID, EventSessionCreated, req.CreatorName, ipHash, map[string]interface{}{
+		"type":      sessionType,
+		"timeslots": len(req.Timeslots),
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
+	metrics.SessionsCreated.WithLabelValues(sessionType).Inc()
 
+	link := "/sessions/" + sessionID // Frontend route
+	if accessKey != "" {
+		link += "?key=" + accessKey
+	}
 	return &models.CreateSessionResponse{
-		ID:   sessionID,
-		Link: "/sessions/" + sessionID, // Frontend route
+		ID:         sessionID,
+		Link:       link,
+		OwnerToken: ownerToken,
+		AccessKey:  accessKey,
 	}, nil
 }
 
-func AddTimeslot(sessionID string, req models.TimeslotRequest) (*models.Timeslot, error) {
+// VerifyOwnerToken checks the owner token handed out by CreateSession.
+// Sessions created before owner tokens existed can never be verified.
+func VerifyOwnerToken(ctx context.Context, sessionID, token string) error {
+	ctx, end := startOp(ctx, "verify_owner_token")
+	defer end()
+
+	var storedHash sql.NullString
+	err := db.DB.QueryRowContext(ctx, "SELECT owner_token_hash FROM sessions WHERE id = ?", sessionID).Scan(&storedHash)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return fmt.Errorf("owner_token_required")
+	}
+	if !storedHash.Valid || subtle.ConstantTimeCompare([]byte(storedHash.String), []byte(utils.HashToken(token))) != 1 {
+		return fmt.Errorf("invalid_owner_token")
+	}
+	return nil
+}
+
+func AddTimeslot(ctx context.Context, sessionID string, req models.TimeslotRequest) (*models.Timeslot, error) {
+	ctx, end := startOp(ctx, "add_timeslot")
+	defer end()
+
+	if err := checkSessionOpen(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	if err := checkSlotRules(ctx, sessionID, req.StartUTC, req.EndUTC, ""); err != nil {
+		return nil, err
+	}
+
 	// Check for duplicates
 	var count int
-	err := db.DB.QueryRow(`
+	err := db.DB.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM timeslots 
 		WHERE session_id = ? AND start_utc = ? AND end_utc = ?
 	`, sessionID, req.StartUTC, req.EndUTC).Scan(&count)
@@ -81,14 +186,14 @@ func AddTimeslot(sessionID string, req models.TimeslotRequest) (*models.Timeslot
 		return nil, err
 	}
 	if count > 0 {
-		return nil, fmt.Errorf("این زمان قبلاً ثبت شده است")
+		return nil, fmt.Errorf("timeslot_exists")
 	}
 
 	tsID := uuid.New().String()
 
 	var passwordHash sql.NullString
 	if req.Password != "" {
-		bytes, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		bytes, err := bcrypt.GenerateFromPassword([]byte(req.Password), config.Get().Security.BcryptCost)
 		if err != nil {
 			return nil, err
 		}
@@ -96,21 +201,34 @@ func AddTimeslot(sessionID string, req models.TimeslotRequest) (*models.Timeslot
 		passwordHash.Valid = true
 	}
 
-	tx, err := db.DB.Begin()
+	ipHash, err := eventIPHash(ctx, req.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+	// Lockouts are read through db.DB, so before the transaction holds a
+	// connection
+	if req.CreatedBy != "" && req.Password != "" {
+		if err := checkAttempts(ctx, participantAttemptKeys(sessionID, req.CreatedBy, req.ClientIP)); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(`
-		INSERT INTO timeslots (id, session_id, start_utc, end_utc, created_by, password_hash)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, tsID, sessionID, req.StartUTC, req.EndUTC, req.CreatedBy, passwordHash)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO timeslots (id, session_id, start_utc, end_utc, created_by, password_hash, created_at_utc)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, tsID, sessionID, req.StartUTC, req.EndUTC, req.CreatedBy, passwordHash, time.Now().UTC().Format(time.RFC3339))
 	if err != nil {
 		return nil, err
 	}
 
 	// Automatically vote for the creator if name is provided
+	var attemptKeys []attemptKey
 	if req.CreatedBy != "" {
 		voteID := uuid.New().String()
 		createdAt := time.Now().UTC().Format(time.RFC3339)
@@ -120,11 +238,20 @@ func AddTimeslot(sessionID string, req models.TimeslotRequest) (*models.Timeslot
 		// Let's duplicate the minimal logic needed: Insert participant if new, then insert vote.
 
 		// 1. Handle Participant
+		// Invitee names can only be claimed through their personal link
+		holder, _, err := nameHolderTx(ctx, tx, sessionID, req.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		if holder != "" {
+			return nil, fmt.Errorf("name_reserved_for_invitee")
+		}
+
 		var pStoredHash sql.NullString
-		err = tx.QueryRow("SELECT password_hash FROM participants WHERE session_id = ? AND name = ?", sessionID, req.CreatedBy).Scan(&pStoredHash)
+		err = tx.QueryRowContext(ctx, "SELECT password_hash FROM participants WHERE session_id = ? AND name = ?", sessionID, req.CreatedBy).Scan(&pStoredHash)
 		if err == sql.ErrNoRows {
 			// New participant
-			_, err = tx.Exec("INSERT INTO participants (session_id, name, password_hash, created_at_utc) VALUES (?, ?, ?, ?)",
+			_, err = tx.ExecContext(ctx, "INSERT INTO participants (session_id, name, password_hash, created_at_utc) VALUES (?, ?, ?, ?)",
 				sessionID, req.CreatedBy, passwordHash, createdAt) // Use same password hash for participant
 			if err != nil {
 				return nil, err
@@ -150,25 +277,48 @@ func AddTimeslot(sessionID string, req models.TimeslotRequest) (*models.Timeslot
 					// Erroring out seems safer to prevent confusion.
 					return nil, fmt.Errorf("password_required")
 				}
+				attemptKeys = participantAttemptKeys(sessionID, req.CreatedBy, req.ClientIP)
 				if err := bcrypt.CompareHashAndPassword([]byte(pStoredHash.String), []byte(req.Password)); err != nil {
+					tx.Rollback() // Release the database before recording the failure
+					if err := recordFailedAttempt(ctx, attemptKeys); err != nil {
+						return nil, err
+					}
 					return nil, fmt.Errorf("invalid_password")
 				}
 			}
 		}
 
 		// 2. Insert Vote
-		_, err = tx.Exec(`
+		_, err = tx.ExecContext(ctx, `
 			INSERT INTO votes (id, timeslot_id, voter_name, created_at_utc)
 			VALUES (?, ?, ?, ?)
 		`, voteID, tsID, req.CreatedBy, createdAt)
 		if err != nil {
 			return nil, err
 		}
+		if _, err := snapshotVotesTx(ctx, tx, sessionID, req.CreatedBy, models.VersionCauseTimeslotAdded); err != nil {
+			return nil, err
+		}
+	}
+
+	err = recordEventTx(ctx, tx, sessionID, EventTimeslotAdded, req.CreatedBy, ipHash, map[string]interface{}{
+		"timeslot_id": tsID,
+		"start_utc":   req.StartUTC,
+		"end_utc":     req.EndUTC,
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
+	if attemptKeys != nil {
+		if err := clearFailedAttempts(ctx, attemptKeys); err != nil {
+			logging.FromContext(ctx).Error("failed to clear password attempts", "error", err)
+		}
+	}
+	metrics.TimeslotsAdded.Inc()
 
 	return &models.Timeslot{
 		ID:        tsID,
@@ -179,21 +329,29 @@ func AddTimeslot(sessionID string, req models.TimeslotRequest) (*models.Timeslot
 	}, nil
 }
 
-func DeleteTimeslot(sessionID, timeslotID, password string) error {
+func DeleteTimeslot(ctx context.Context, sessionID, timeslotID string, req models.DeleteTimeslotRequest) error {
+	ctx, end := startOp(ctx, "delete_timeslot")
+	defer end()
+
+	if err := checkSessionOpen(ctx, sessionID); err != nil {
+		return err
+	}
+
 	// Check if timeslot exists and belongs to session
-	var count int
 	var storedHash sql.NullString
-	err := db.DB.QueryRow("SELECT COUNT(*), password_hash FROM timeslots WHERE id = ? AND session_id = ?", timeslotID, sessionID).Scan(&count, &storedHash)
+	var startUTC, endUTC string
+	err := db.DB.QueryRowContext(ctx, "SELECT password_hash, start_utc, end_utc FROM timeslots WHERE id = ? AND session_id = ?", timeslotID, sessionID).
+		Scan(&storedHash, &startUTC, &endUTC)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("timeslot not found")
+	}
 	if err != nil {
 		return err
 	}
-	if count == 0 {
-		return fmt.Errorf("timeslot not found")
-	}
 
 	// Check if timeslot has votes
 	var voteCount int
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM votes WHERE timeslot_id = ?", timeslotID).Scan(&voteCount)
+	err = db.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM votes WHERE timeslot_id = ?", timeslotID).Scan(&voteCount)
 	if err != nil {
 		return err
 	}
@@ -203,15 +361,267 @@ func DeleteTimeslot(sessionID, timeslotID, password string) error {
 
 	// Check password if set
 	if storedHash.Valid && storedHash.String != "" {
-		if password == "" {
+		if req.Password == "" {
 			return fmt.Errorf("password_required")
 		}
-		err = bcrypt.CompareHashAndPassword([]byte(storedHash.String), []byte(password))
+		attemptKeys := timeslotAttemptKeys(sessionID, timeslotID, req.ClientIP)
+		if err := checkAttempts(ctx, attemptKeys); err != nil {
+			return err
+		}
+		err = bcrypt.CompareHashAndPassword([]byte(storedHash.String), []byte(req.Password))
 		if err != nil {
+			if err := recordFailedAttempt(ctx, attemptKeys); err != nil {
+				return err
+			}
 			return fmt.Errorf("invalid_password")
 		}
+		if err := clearFailedAttempts(ctx, attemptKeys); err != nil {
+			return err
+		}
+	}
+
+	ipHash, err := eventIPHash(ctx, req.ClientIP)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM timeslots WHERE id = ?", timeslotID); err != nil {
+		return err
+	}
+	err = recordEventTx(ctx, tx, sessionID, EventTimeslotDeleted, "", ipHash, map[string]interface{}{
+		"timeslot_id": timeslotID,
+		"start_utc":   startUTC,
+		"end_utc":     endUTC,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateTimeslot moves a timeslot to new times. It is allowed with the
+// owner token, or with the timeslot password when one was set. Votes on the
+// timeslot are kept unless req.InvalidateVotes is set; either way their
+// voters are flagged until they vote again, see Session.TimeslotsChangedAtUTC.
+// Nobody is mailed: voter addresses are not stored, so the notice only
+// shows when they next open the session.
+func UpdateTimeslot(ctx context.Context, sessionID, timeslotID string, req models.UpdateTimeslotRequest) (*models.UpdateTimeslotResponse, error) {
+	ctx, end := startOp(ctx, "update_timeslot")
+	defer end()
+
+	if err := checkSessionOpen(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	if err := validateTimeslotTimes(req.StartUTC, req.EndUTC); err != nil {
+		return nil, err
+	}
+	if err := checkSlotRules(ctx, sessionID, req.StartUTC, req.EndUTC, timeslotID); err != nil {
+		return nil, err
+	}
+
+	var oldStart, oldEnd string
+	var createdBy, storedHash sql.NullString
+	err := db.DB.QueryRowContext(ctx, "SELECT start_utc, end_utc, created_by, password_hash FROM timeslots WHERE id = ? AND session_id = ?",
+		timeslotID, sessionID).Scan(&oldStart, &oldEnd, &createdBy, &storedHash)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("timeslot not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if req.OwnerToken != "" {
+		if err := VerifyOwnerToken(ctx, sessionID, req.OwnerToken); err != nil {
+			return nil, err
+		}
+	} else {
+		if !storedHash.Valid || storedHash.String == "" {
+			return nil, fmt.Errorf("timeslot_not_protected")
+		}
+		if req.Password == "" {
+			return nil, fmt.Errorf("password_required")
+		}
+		attemptKeys := timeslotAttemptKeys(sessionID, timeslotID, req.ClientIP)
+		if err := checkAttempts(ctx, attemptKeys); err != nil {
+			return nil, err
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(storedHash.String), []byte(req.Password)); err != nil {
+			if err := recordFailedAttempt(ctx, attemptKeys); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("invalid_password")
+		}
+		if err := clearFailedAttempts(ctx, attemptKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	ipHash, err := eventIPHash(ctx, req.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var taken bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM timeslots WHERE session_id = ? AND start_utc = ? AND end_utc = ? AND id != ?)",
+		sessionID, req.StartUTC, req.EndUTC, timeslotID).Scan(&taken)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, fmt.Errorf("timeslot_exists")
+	}
+
+	voters, err := timeslotVotersTx(ctx, tx, timeslotID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = tx.ExecContext(ctx, "UPDATE timeslots SET start_utc = ?, end_utc = ?, updated_at_utc = ? WHERE id = ?",
+		req.StartUTC, req.EndUTC, now, timeslotID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.UpdateTimeslotResponse{
+		Timeslot: models.Timeslot{
+			ID:           timeslotID,
+			SessionID:    sessionID,
+			StartUTC:     req.StartUTC,
+			EndUTC:       req.EndUTC,
+			CreatedBy:    createdBy.String,
+			UpdatedAtUTC: now,
+		},
+	}
+	for _, name := range voters {
+		if req.InvalidateVotes {
+			if err := snapshotBaselineTx(ctx, tx, sessionID, name); err != nil {
+				return nil, err
+			}
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE participants SET timeslot_changed_at_utc = ? WHERE session_id = ? AND name = ?",
+			now, sessionID, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if req.InvalidateVotes {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM votes WHERE timeslot_id = ?", timeslotID); err != nil {
+			return nil, err
+		}
+		for _, name := range voters {
+			if _, err := snapshotVotesTx(ctx, tx, sessionID, name, models.VersionCauseTimeslotChanged); err != nil {
+				return nil, err
+			}
+		}
+		resp.VotesInvalidated = len(voters)
+	} else {
+		resp.VotesKept = len(voters)
 	}
 
-	_, err = db.DB.Exec("DELETE FROM timeslots WHERE id = ?", timeslotID)
-	return err
+	err = recordEventTx(ctx, tx, sessionID, EventTimeslotUpdated, "", ipHash, map[string]interface{}{
+		"timeslot_id":       timeslotID,
+		"old_start_utc":     oldStart,
+		"old_end_utc":       oldEnd,
+		"start_utc":         req.StartUTC,
+		"end_utc":           req.EndUTC,
+		"votes_kept":        resp.VotesKept,
+		"votes_invalidated": resp.VotesInvalidated,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// validateTimeslotTimes checks that start and end are RFC 3339 times with
+// end after start. The error is "invalid_timeslot".
+func validateTimeslotTimes(startUTC, endUTC string) error {
+	start, err := time.Parse(time.RFC3339, startUTC)
+	if err != nil {
+		return fmt.Errorf("invalid_timeslot")
+	}
+	end, err := time.Parse(time.RFC3339, endUTC)
+	if err != nil || !end.After(start) {
+		return fmt.Errorf("invalid_timeslot")
+	}
+	return nil
+}
+
+// timeslotVotersTx returns the names that voted for a timeslot.
+func timeslotVotersTx(ctx context.Context, tx *sql.Tx, timeslotID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT voter_name FROM votes WHERE timeslot_id = ? ORDER BY voter_name", timeslotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// checkSessionOpen fails for missing sessions and for archived ones, which
+// are read-only.
+func checkSessionOpen(ctx context.Context, sessionID string) error {
+	var archivedAt sql.NullString
+	err := db.DB.QueryRowContext(ctx, "SELECT archived_at_utc FROM sessions WHERE id = ?", sessionID).Scan(&archivedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return err
+	}
+	if archivedAt.Valid && archivedAt.String != "" {
+		return fmt.Errorf("session_archived")
+	}
+	return nil
+}
+
+// deleteSessionTx removes a session and everything hanging off it. Foreign
+// key enforcement is off in SQLite by default, so children go explicitly.
+func deleteSessionTx(ctx context.Context, tx *sql.Tx, id string) error {
+	res, err := tx.ExecContext(ctx, "DELETE FROM sessions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("session not found")
+	}
+
+	stmts := []string{
+		"DELETE FROM votes WHERE timeslot_id IN (SELECT id FROM timeslots WHERE session_id = ?)",
+		"DELETE FROM timeslots WHERE session_id = ?",
+		"DELETE FROM participants WHERE session_id = ?",
+		"DELETE FROM invitees WHERE session_id = ?",
+		"DELETE FROM session_events WHERE session_id = ?",
+		"DELETE FROM vote_versions WHERE session_id = ?",
+		"DELETE FROM comments WHERE session_id = ?",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"biameet.ir/db"
+	"biameet.ir/models"
+)
+
+// ValidateSlotRules checks the timeslot rules of a dynamic config. The
+// error is "invalid_slot_rules", or "invalid_timezone".
+func ValidateSlotRules(cfg *models.DynamicConfig) error {
+	for _, minutes := range []int{cfg.SlotMinutes, cfg.MinSlotMinutes, cfg.MaxSlotMinutes, cfg.GranularityMinutes, cfg.BufferMinutes} {
+		if minutes < 0 || minutes > models.MaxDurationMinutes {
+			return fmt.Errorf("invalid_slot_rules")
+		}
+	}
+	if cfg.SlotMinutes > 0 && (cfg.MinSlotMinutes > 0 || cfg.MaxSlotMinutes > 0) {
+		return fmt.Errorf("invalid_slot_rules")
+	}
+	if cfg.MaxSlotMinutes > 0 && cfg.MinSlotMinutes > cfg.MaxSlotMinutes {
+		return fmt.Errorf("invalid_slot_rules")
+	}
+	// Granularities that do not divide a day would drift from day to day
+	if cfg.GranularityMinutes > 0 && models.MaxDurationMinutes%cfg.GranularityMinutes != 0 {
+		return fmt.Errorf("invalid_slot_rules")
+	}
+	if cfg.MinTime != "" || cfg.MaxTime != "" {
+		minMinutes, okMin := parseClock(cfg.MinTime)
+		maxMinutes, okMax := parseClock(cfg.MaxTime)
+		if !okMin || !okMax || minMinutes >= maxMinutes {
+			return fmt.Errorf("invalid_slot_rules")
+		}
+	}
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+			return fmt.Errorf("invalid_timezone")
+		}
+	}
+	return nil
+}
+
+// parseClock reads an "HH:MM" time of day as minutes after midnight.
+// "24:00" is accepted as the end of the day.
+func parseClock(clock string) (int, bool) {
+	t, err := time.Parse("15:04", clock)
+	if err == nil {
+		return t.Hour()*60 + t.Minute(), true
+	}
+	if clock == "24:00" {
+		return models.MaxDurationMinutes, true
+	}
+	return 0, false
+}
+
+// checkSlotRules enforces the session's timeslot rules on a slot from
+// startUTC to endUTC. ignoreID is a timeslot the buffer does not apply
+// to, the one being moved. Errors are "invalid_timeslot",
+// "slot_outside_window", "slot_duration_not_allowed",
+// "slot_start_not_aligned" and "slot_too_close".
+func checkSlotRules(ctx context.Context, sessionID, startUTC, endUTC, ignoreID string) error {
+	var configJSON sql.NullString
+	err := db.DB.QueryRowContext(ctx, "SELECT dynamic_config FROM sessions WHERE id = ?", sessionID).Scan(&configJSON)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return err
+	}
+	var cfg models.DynamicConfig
+	if configJSON.String != "" {
+		if err := json.Unmarshal([]byte(configJSON.String), &cfg); err != nil {
+			return err
+		}
+	}
+	minMinutes, hasWindow := parseClock(cfg.MinTime)
+	maxMinutes, okMax := parseClock(cfg.MaxTime)
+	hasWindow = hasWindow && okMax
+	if !hasWindow && cfg.SlotMinutes == 0 && cfg.MinSlotMinutes == 0 && cfg.MaxSlotMinutes == 0 && cfg.GranularityMinutes == 0 && cfg.BufferMinutes == 0 {
+		return nil
+	}
+
+	if err := validateTimeslotTimes(startUTC, endUTC); err != nil {
+		return err
+	}
+	start, _ := time.Parse(time.RFC3339, startUTC)
+	end, _ := time.Parse(time.RFC3339, endUTC)
+
+	tzName := cfg.Timezone
+	if tzName == "" {
+		tzName = DefaultPatternTimezone
+	}
+	loc, err := time.LoadLocation(tzName)
+	if err != nil {
+		return err
+	}
+
+	// The window is a time of day in the session's timezone, and a slot
+	// may not run past midnight out of it
+	if hasWindow {
+		localStart := start.In(loc)
+		day := time.Date(localStart.Year(), localStart.Month(), localStart.Day(), 0, 0, 0, 0, loc)
+		if start.Before(day.Add(time.Duration(minMinutes)*time.Minute)) || end.After(day.Add(time.Duration(maxMinutes)*time.Minute)) {
+			return fmt.Errorf("slot_outside_window")
+		}
+	}
+
+	length := end.Sub(start)
+	switch {
+	case cfg.SlotMinutes > 0 && length != time.Duration(cfg.SlotMinutes)*time.Minute,
+		cfg.MinSlotMinutes > 0 && length < time.Duration(cfg.MinSlotMinutes)*time.Minute,
+		cfg.MaxSlotMinutes > 0 && length > time.Duration(cfg.MaxSlotMinutes)*time.Minute:
+		return fmt.Errorf("slot_duration_not_allowed")
+	}
+
+	if cfg.GranularityMinutes > 0 {
+		local := start.In(loc)
+		if local.Second() != 0 || (local.Hour()*60+local.Minute())%cfg.GranularityMinutes != 0 {
+			return fmt.Errorf("slot_start_not_aligned")
+		}
+	}
+
+	if cfg.BufferMinutes > 0 {
+		buffer := time.Duration(cfg.BufferMinutes) * time.Minute
+		rows, err := db.DB.QueryContext(ctx, "SELECT start_utc, end_utc FROM timeslots WHERE session_id = ? AND id != ?", sessionID, ignoreID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var otherStart, otherEnd string
+			if err := rows.Scan(&otherStart, &otherEnd); err != nil {
+				return err
+			}
+			// Timeslots from before these rules were stored unchecked
+			s, err1 := time.Parse(time.RFC3339, otherStart)
+			e, err2 := time.Parse(time.RFC3339, otherEnd)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			if s.Before(end.Add(buffer)) && e.After(start.Add(-buffer)) {
+				return fmt.Errorf("slot_too_close")
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
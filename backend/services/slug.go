@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+
+	"biameet.ir/config"
+	"biameet.ir/utils"
+)
+
+// maxIDAttempts bounds how often CreateSession redraws a random ID that is
+// already taken before giving up.
+const maxIDAttempts = 5
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{2,38})[a-z0-9]$`)
+
+// reservedSlugs are paths the frontend, nginx or the server already use,
+// plus a few that would make a session look official.
+var reservedSlugs = map[string]bool{
+	"admin": true, "api": true, "health": true, "livez": true, "readyz": true,
+	"metrics": true, "sessions": true, "session": true, "static": true,
+	"assets": true, "index": true, "favicon": true, "new": true, "create": true,
+	"login": true, "logout": true, "signup": true, "settings": true,
+	"about": true, "help": true, "support": true, "privacy": true, "terms": true,
+	"biameet": true, "official": true, "root": true, "null": true, "undefined": true,
+}
+
+// ValidateSlug checks a requested vanity slug: 4 to 40 lowercase letters,
+// digits and inner hyphens, not a reserved word. Errors are "invalid_slug"
+// and "slug_reserved".
+func ValidateSlug(slug string) error {
+	if !slugPattern.MatchString(slug) {
+		return fmt.Errorf("invalid_slug")
+	}
+	if reservedSlugs[slug] {
+		return fmt.Errorf("slug_reserved")
+	}
+	return nil
+}
+
+// newSessionID picks the ID for a new session inside tx: the requested
+// slug when it is free ("slug_taken" otherwise), else a random ID redrawn
+// on collision.
+func newSessionID(ctx context.Context, tx *sql.Tx, slug string) (string, error) {
+	if slug != "" {
+		if err := ValidateSlug(slug); err != nil {
+			return "", err
+		}
+		taken, err := sessionIDTaken(ctx, tx, slug)
+		if err != nil {
+			return "", err
+		}
+		if taken {
+			return "", fmt.Errorf("slug_taken")
+		}
+		return slug, nil
+	}
+
+	length := config.Get().Security.SessionIDLength
+	for i := 0; i < maxIDAttempts; i++ {
+		id, err := utils.GenerateShortID(length)
+		if err != nil {
+			return "", err
+		}
+		taken, err := sessionIDTaken(ctx, tx, id)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("session_id_exhausted")
+}
+
+func sessionIDTaken(ctx context.Context, tx *sql.Tx, id string) (bool, error) {
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM sessions WHERE id = ?", id).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
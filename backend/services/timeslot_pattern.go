@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+	_ "time/tzdata" // The runtime image has no zoneinfo
+
+	"biameet.ir/models"
+)
+
+// DefaultPatternTimezone is used when a pattern names no timezone.
+const DefaultPatternTimezone = "Asia/Tehran"
+
+// GenerateTimeslots expands a pattern into timeslots, in order. Nothing is
+// saved. Errors are "invalid_date_range", "invalid_weekday",
+// "invalid_daily_window", "invalid_slot_duration", "invalid_gap",
+// "invalid_timezone", "too_many_timeslots" and "no_timeslots".
+func GenerateTimeslots(ctx context.Context, p models.TimeslotPattern) (*models.TimeslotPreview, error) {
+	_, end := startOp(ctx, "generate_timeslots")
+	defer end()
+
+	tzName := p.Timezone
+	if tzName == "" {
+		tzName = DefaultPatternTimezone
+	}
+	loc, err := time.LoadLocation(tzName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid_timezone")
+	}
+
+	from, err := time.Parse("2006-01-02", p.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid_date_range")
+	}
+	to, err := time.Parse("2006-01-02", p.EndDate)
+	if err != nil || to.Before(from) || to.Sub(from) >= models.MaxPatternDays*24*time.Hour {
+		return nil, fmt.Errorf("invalid_date_range")
+	}
+
+	weekdays := map[time.Weekday]bool{}
+	for _, d := range p.Weekdays {
+		if d < 0 || d > 6 {
+			return nil, fmt.Errorf("invalid_weekday")
+		}
+		weekdays[time.Weekday(d)] = true
+	}
+
+	dayStart, err := time.Parse("15:04", p.DayStart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid_daily_window")
+	}
+	dayEnd, err := time.Parse("15:04", p.DayEnd)
+	if err != nil || !dayEnd.After(dayStart) {
+		return nil, fmt.Errorf("invalid_daily_window")
+	}
+	slot := time.Duration(p.SlotMinutes) * time.Minute
+	if p.SlotMinutes < models.MinSlotMinutes || slot > dayEnd.Sub(dayStart) {
+		return nil, fmt.Errorf("invalid_slot_duration")
+	}
+	if p.GapMinutes < 0 {
+		return nil, fmt.Errorf("invalid_gap")
+	}
+	step := slot + time.Duration(p.GapMinutes)*time.Minute
+
+	var holidays map[string]models.Holiday
+	if p.SkipHolidays {
+		holidays = iranianHolidays(from, to)
+	}
+
+	preview := &models.TimeslotPreview{Timeslots: []models.TimeslotRequest{}}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if len(weekdays) > 0 && !weekdays[day.Weekday()] {
+			continue
+		}
+		if h, ok := holidays[day.Format("2006-01-02")]; ok {
+			preview.SkippedHolidays = append(preview.SkippedHolidays, h)
+			continue
+		}
+
+		windowStart := time.Date(day.Year(), day.Month(), day.Day(), dayStart.Hour(), dayStart.Minute(), 0, 0, loc)
+		windowEnd := time.Date(day.Year(), day.Month(), day.Day(), dayEnd.Hour(), dayEnd.Minute(), 0, 0, loc)
+		for start := windowStart; !start.Add(slot).After(windowEnd); start = start.Add(step) {
+			if len(preview.Timeslots) == models.MaxGeneratedTimeslots {
+				return nil, fmt.Errorf("too_many_timeslots")
+			}
+			preview.Timeslots = append(preview.Timeslots, models.TimeslotRequest{
+				StartUTC: start.UTC().Format(time.RFC3339),
+				EndUTC:   start.Add(slot).UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	if len(preview.Timeslots) == 0 {
+		return nil, fmt.Errorf("no_timeslots")
+	}
+	return preview, nil
+}
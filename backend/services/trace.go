@@ -0,0 +1,20 @@
+package services
+
+import (
+	"context"
+
+	"biameet.ir/metrics"
+	"biameet.ir/tracing"
+)
+
+// startOp opens a span for a service operation and times it for the
+// biameet_service_operation_duration_seconds metric. SQL statements get
+// their own spans from otelsql. Call the returned func when done.
+func startOp(ctx context.Context, operation string) (context.Context, func()) {
+	ctx, span := tracing.Tracer().Start(ctx, "services."+operation)
+	stop := metrics.ObserveOperation(operation)
+	return ctx, func() {
+		stop()
+		span.End()
+	}
+}
@@ -1,83 +1,166 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"biameet.ir/config"
 	"biameet.ir/db"
+	"biameet.ir/logging"
+	"biameet.ir/metrics"
 	"biameet.ir/models"
+	"biameet.ir/utils"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func SubmitVote(sessionID string, req models.VoteRequest) error {
-	// 1. Validate Session exists
-	var exists bool
-	err := db.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM sessions WHERE id = ?)", sessionID).Scan(&exists)
+func SubmitVote(ctx context.Context, sessionID string, req models.VoteRequest) (*models.VoteResponse, error) {
+	ctx, end := startOp(ctx, "submit_vote")
+	defer end()
+
+	// 1. Validate Session exists and is open
+	if err := checkSessionOpen(ctx, sessionID); err != nil {
+		return nil, err
+	}
+
+	var err error
+
+	// Magic edit links authenticate voters who already took part
+	if req.EditToken != "" && req.InviteToken == "" {
+		req.VoterName, err = parseEditToken(ctx, sessionID, req.EditToken)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		req.EditToken = ""
+	}
+	if req.InviteToken == "" && strings.TrimSpace(req.VoterName) == "" {
+		return nil, fmt.Errorf("voter_name_required")
+	}
+
+	ipHash, err := eventIPHash(ctx, req.ClientIP)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if !exists {
-		return fmt.Errorf("session not found")
+	// Lockouts are read through db.DB, so before the transaction holds a
+	// connection
+	if req.Password != "" && req.InviteToken == "" && req.EditToken == "" {
+		if err := checkAttempts(ctx, participantAttemptKeys(sessionID, req.VoterName, req.ClientIP)); err != nil {
+			return nil, err
+		}
 	}
 
-	tx, err := db.DB.Begin()
+	tx, err := db.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback()
 
 	createdAt := time.Now().UTC().Format(time.RFC3339)
 
+	// Personal invite links do the same for invited people
+	var inviteeID string
+	if req.InviteToken != "" {
+		if !config.Get().Features.Invitees {
+			return nil, fmt.Errorf("invalid_invite_token")
+		}
+		err = tx.QueryRowContext(ctx, "SELECT id, name FROM invitees WHERE session_id = ? AND token_hash = ?",
+			sessionID, utils.HashToken(req.InviteToken)).Scan(&inviteeID, &req.VoterName)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invalid_invite_token")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	tokenAuth := inviteeID != "" || req.EditToken != ""
+
 	// 2. Handle Participant Logic
+	var attemptKeys []attemptKey
+	previous := map[string]string{} // Timeslot ID -> note, for the audit diff
 	var storedHash sql.NullString
-	err = tx.QueryRow("SELECT password_hash FROM participants WHERE session_id = ? AND name = ?", sessionID, req.VoterName).Scan(&storedHash)
+	err = tx.QueryRowContext(ctx, "SELECT password_hash FROM participants WHERE session_id = ? AND name = ?", sessionID, req.VoterName).Scan(&storedHash)
 
 	if err == sql.ErrNoRows {
 		// New participant
+		if req.EditToken != "" && inviteeID == "" {
+			// Edit links are only issued to existing participants
+			return nil, fmt.Errorf("invalid_edit_token")
+		}
+		if inviteeID == "" {
+			// Invitee names can only be claimed through their personal link
+			holder, _, err := nameHolderTx(ctx, tx, sessionID, req.VoterName)
+			if err != nil {
+				return nil, err
+			}
+			if holder != "" {
+				return nil, fmt.Errorf("name_reserved_for_invitee")
+			}
+		}
+
 		var hash sql.NullString
-		if req.Password != "" {
-			bytes, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if req.Password != "" && !tokenAuth {
+			bytes, err := bcrypt.GenerateFromPassword([]byte(req.Password), config.Get().Security.BcryptCost)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			hash.String = string(bytes)
 			hash.Valid = true
 		}
 
-		_, err = tx.Exec("INSERT INTO participants (session_id, name, password_hash, created_at_utc) VALUES (?, ?, ?, ?)",
+		_, err = tx.ExecContext(ctx, "INSERT INTO participants (session_id, name, password_hash, created_at_utc) VALUES (?, ?, ?, ?)",
 			sessionID, req.VoterName, hash, createdAt)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else if err != nil {
-		return err
+		return nil, err
 	} else {
 		// Existing participant
-		if storedHash.Valid && storedHash.String != "" {
+		if tokenAuth {
+			// Authenticated by invite or edit token
+		} else if storedHash.Valid && storedHash.String != "" {
 			// Password required
 			if req.Password == "" {
-				return fmt.Errorf("password_required") // Specific error for frontend to handle
+				return nil, fmt.Errorf("password_required") // Specific error for frontend to handle
 			}
+			attemptKeys = participantAttemptKeys(sessionID, req.VoterName, req.ClientIP)
 			err = bcrypt.CompareHashAndPassword([]byte(storedHash.String), []byte(req.Password))
 			if err != nil {
-				return fmt.Errorf("invalid_password") // Specific error
+				tx.Rollback() // Release the database before recording the failure
+				if err := recordFailedAttempt(ctx, attemptKeys); err != nil {
+					return nil, err
+				}
+				return nil, fmt.Errorf("invalid_password") // Specific error
 			}
 		} else {
 			// User exists but has no password set.
 			// Prevent editing to avoid impersonation.
-			return fmt.Errorf("name_taken_no_password")
+			return nil, fmt.Errorf("name_taken_no_password")
+		}
+
+		previous, err = participantVotesTx(ctx, tx, sessionID, req.VoterName)
+		if err != nil {
+			return nil, err
+		}
+		if len(previous) > 0 {
+			if err := snapshotBaselineTx(ctx, tx, sessionID, req.VoterName); err != nil {
+				return nil, err
+			}
 		}
 
 		// Delete existing votes for this user in this session
-		_, err = tx.Exec(`
+		_, err = tx.ExecContext(ctx, `
 			DELETE FROM votes 
 			WHERE voter_name = ? 
 			AND timeslot_id IN (SELECT id FROM timeslots WHERE session_id = ?)
 		`, req.VoterName, sessionID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -85,24 +168,152 @@ func SubmitVote(sessionID string, req models.VoteRequest) error {
 	for _, item := range req.Votes {
 		// Validate Timeslot belongs to Session
 		var tsSessionID string
-		err := tx.QueryRow("SELECT session_id FROM timeslots WHERE id = ?", item.TimeslotID).Scan(&tsSessionID)
+		err := tx.QueryRowContext(ctx, "SELECT session_id FROM timeslots WHERE id = ?", item.TimeslotID).Scan(&tsSessionID)
 		if err != nil {
-			return fmt.Errorf("invalid timeslot id: %s", item.TimeslotID)
+			return nil, fmt.Errorf("invalid timeslot id: %s", item.TimeslotID)
 		}
 		if tsSessionID != sessionID {
-			return fmt.Errorf("timeslot %s does not belong to session %s", item.TimeslotID, sessionID)
+			return nil, fmt.Errorf("timeslot %s does not belong to session %s", item.TimeslotID, sessionID)
 		}
 
 		voteID := uuid.New().String()
-		_, err = tx.Exec(`
+		_, err = tx.ExecContext(ctx, `
 			INSERT INTO votes (id, timeslot_id, voter_name, note, created_at_utc)
 			VALUES (?, ?, ?, ?, ?)
 		`, voteID, item.TimeslotID, req.VoterName, item.Note, createdAt)
 
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	if inviteeID != "" {
+		_, err = tx.ExecContext(ctx, "UPDATE invitees SET responded_at_utc = ? WHERE id = ?", createdAt, inviteeID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Voting again confirms any timeslots that moved since
+	_, err = tx.ExecContext(ctx, "UPDATE participants SET timeslot_changed_at_utc = NULL WHERE session_id = ? AND name = ?", sessionID, req.VoterName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := snapshotVotesTx(ctx, tx, sessionID, req.VoterName, models.VersionCauseVote); err != nil {
+		return nil, err
+	}
+
+	details := voteDiff(previous, req.Votes)
+	switch {
+	case inviteeID != "":
+		details["auth"] = "invite_link"
+	case req.EditToken != "":
+		details["auth"] = "edit_link"
+	case attemptKeys != nil:
+		details["auth"] = "password"
+	}
+	if err := recordEventTx(ctx, tx, sessionID, EventVoteSubmitted, req.VoterName, ipHash, details); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	if attemptKeys != nil {
+		if err := clearFailedAttempts(ctx, attemptKeys); err != nil {
+			logging.FromContext(ctx).Error("failed to clear password attempts", "error", err)
+		}
+	}
+	metrics.VotesSubmitted.Inc()
+	metrics.VotesCast.Add(float64(len(req.Votes)))
+
+	resp := &models.VoteResponse{Status: "ok"}
+	if !config.Get().Features.EditLinks {
+		return resp, nil
+	}
+
+	// Hand out a fresh magic edit link so the voter can come back without a password
+	editToken, expiresAt, err := IssueEditToken(ctx, sessionID, req.VoterName)
+	if err != nil {
+		return nil, err
+	}
+	editLink := editLinkPath(sessionID, editToken)
+
+	if req.Email != "" && MailEnabled() {
+		// One mail per participant and interval, whoever asks for it
+		resp.EditLinkMailed, err = claimEditLinkMail(ctx, sessionID, req.VoterName)
+		if err != nil {
+			return nil, err
 		}
 	}
+	if resp.EditLinkMailed {
+		logger := logging.FromContext(ctx)
+		to, link := req.Email, BaseURL()+editLink
+		runBackground(func() {
+			body := "برای ویرایش رای خود در بیا میت از این لینک استفاده کنید:\n\n" + link +
+				"\n\nاین لینک تا " + expiresAt.Format("2006-01-02") + " معتبر است."
+			if err := SendMail(to, "لینک ویرایش رای | بیا میت", body); err != nil {
+				logger.Error("failed to send edit link email", "error", err)
+			}
+		})
+	}
 
-	return tx.Commit()
+	resp.EditToken = editToken
+	resp.EditLink = editLink
+	resp.EditLinkExpiresAtUTC = expiresAt.Format(time.RFC3339)
+	return resp, nil
+}
+
+// participantVotesTx returns the timeslots name voted for in the session,
+// with the note of each vote.
+func participantVotesTx(ctx context.Context, tx *sql.Tx, sessionID, name string) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT v.timeslot_id, v.note FROM votes v
+		JOIN timeslots t ON t.id = v.timeslot_id
+		WHERE t.session_id = ? AND v.voter_name = ?
+	`, sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	votes := map[string]string{}
+	for rows.Next() {
+		var tsID string
+		var note sql.NullString
+		if err := rows.Scan(&tsID, &note); err != nil {
+			return nil, err
+		}
+		votes[tsID] = note.String
+	}
+	return votes, rows.Err()
+}
+
+// voteDiff describes how a ballot changed: timeslots added, removed and
+// those whose note changed, each as a sorted list of timeslot IDs.
+func voteDiff(previous map[string]string, votes []models.VoteItem) map[string]interface{} {
+	added, removed, noteChanged := []string{}, []string{}, []string{}
+	current := make(map[string]bool, len(votes))
+	for _, v := range votes {
+		current[v.TimeslotID] = true
+		note, ok := previous[v.TimeslotID]
+		if !ok {
+			added = append(added, v.TimeslotID)
+		} else if note != v.Note {
+			noteChanged = append(noteChanged, v.TimeslotID)
+		}
+	}
+	for tsID := range previous {
+		if !current[tsID] {
+			removed = append(removed, tsID)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(noteChanged)
+	return map[string]interface{}{
+		"added":        added,
+		"removed":      removed,
+		"note_changed": noteChanged,
+	}
 }
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"biameet.ir/db"
+	"biameet.ir/metrics"
+	"biameet.ir/models"
+	"github.com/google/uuid"
+)
+
+// currentBallotTx returns name's votes in the session in timeslot order.
+func currentBallotTx(ctx context.Context, tx *sql.Tx, sessionID, name string) ([]models.VoteItem, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT v.timeslot_id, v.note FROM votes v
+		JOIN timeslots t ON t.id = v.timeslot_id
+		WHERE t.session_id = ? AND v.voter_name = ?
+		ORDER BY t.start_utc, t.id
+	`, sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ballot := []models.VoteItem{}
+	for rows.Next() {
+		var item models.VoteItem
+		var note sql.NullString
+		if err := rows.Scan(&item.TimeslotID, &note); err != nil {
+			return nil, err
+		}
+		item.Note = note.String
+		ballot = append(ballot, item)
+	}
+	return ballot, rows.Err()
+}
+
+// snapshotVotesTx stores name's current ballot as their next version and
+// returns its number.
+func snapshotVotesTx(ctx context.Context, tx *sql.Tx, sessionID, name, cause string) (int, error) {
+	ballot, err := currentBallotTx(ctx, tx, sessionID, name)
+	if err != nil {
+		return 0, err
+	}
+	votesJSON, err := json.Marshal(ballot)
+	if err != nil {
+		return 0, err
+	}
+
+	var version int
+	err = tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) + 1 FROM vote_versions WHERE session_id = ? AND voter_name = ?",
+		sessionID, name).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO vote_versions (id, session_id, voter_name, version, cause, votes, created_at_utc)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, uuid.New().String(), sessionID, name, version, cause, string(votesJSON), time.Now().UTC().Format(time.RFC3339))
+	return version, err
+}
+
+// snapshotBaselineTx keeps the ballot of a participant who voted before
+// versions were recorded, so their first change can still be undone.
+func snapshotBaselineTx(ctx context.Context, tx *sql.Tx, sessionID, name string) error {
+	var versioned bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM vote_versions WHERE session_id = ? AND voter_name = ?)",
+		sessionID, name).Scan(&versioned)
+	if err != nil || versioned {
+		return err
+	}
+	_, err = snapshotVotesTx(ctx, tx, sessionID, name, models.VersionCauseBaseline)
+	return err
+}
+
+// GetVoteHistory lists every version of a participant's ballot, newest
+// first. See authenticateParticipant for who may read it.
+func GetVoteHistory(ctx context.Context, sessionID string, creds models.ParticipantCredentials) (*models.VoteHistory, error) {
+	ctx, end := startOp(ctx, "get_vote_history")
+	defer end()
+
+	name, err := authenticateParticipant(ctx, sessionID, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.QueryContext(ctx, `
+		SELECT version, cause, votes, created_at_utc FROM vote_versions
+		WHERE session_id = ? AND voter_name = ?
+		ORDER BY version DESC
+	`, sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := &models.VoteHistory{VoterName: name, Versions: []models.VoteVersion{}}
+	for rows.Next() {
+		var v models.VoteVersion
+		var votesJSON string
+		if err := rows.Scan(&v.Version, &v.Cause, &votesJSON, &v.CreatedAtUTC); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(votesJSON), &v.Votes); err != nil {
+			return nil, err
+		}
+		history.Versions = append(history.Versions, v)
+	}
+	return history, rows.Err()
+}
+
+// RevertVotes replaces a participant's ballot with an earlier version. The
+// revert is itself a new version, so it can be undone too. Votes for
+// timeslots deleted since are skipped.
+func RevertVotes(ctx context.Context, sessionID string, req models.RevertVotesRequest) (*models.RevertVotesResponse, error) {
+	ctx, end := startOp(ctx, "revert_votes")
+	defer end()
+
+	if err := checkSessionOpen(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	name, err := authenticateParticipant(ctx, sessionID, req.ParticipantCredentials)
+	if err != nil {
+		return nil, err
+	}
+	ipHash, err := eventIPHash(ctx, req.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var votesJSON string
+	err = tx.QueryRowContext(ctx, "SELECT votes FROM vote_versions WHERE session_id = ? AND voter_name = ? AND version = ?",
+		sessionID, name, req.Version).Scan(&votesJSON)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("version not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ballot []models.VoteItem
+	if err := json.Unmarshal([]byte(votesJSON), &ballot); err != nil {
+		return nil, err
+	}
+
+	previous, err := participantVotesTx(ctx, tx, sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM votes
+		WHERE voter_name = ? AND timeslot_id IN (SELECT id FROM timeslots WHERE session_id = ?)
+	`, name, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.RevertVotesResponse{Status: "ok"}
+	restored := []models.VoteItem{}
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	for _, item := range ballot {
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM timeslots WHERE id = ? AND session_id = ?)", item.TimeslotID, sessionID).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			resp.Skipped = append(resp.Skipped, item.TimeslotID)
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO votes (id, timeslot_id, voter_name, note, created_at_utc)
+			VALUES (?, ?, ?, ?, ?)
+		`, uuid.New().String(), item.TimeslotID, name, item.Note, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		restored = append(restored, item)
+	}
+	resp.Restored = len(restored)
+	_, err = tx.ExecContext(ctx, "UPDATE participants SET timeslot_changed_at_utc = NULL WHERE session_id = ? AND name = ?", sessionID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Version, err = snapshotVotesTx(ctx, tx, sessionID, name, models.VersionCauseRevert); err != nil {
+		return nil, err
+	}
+
+	details := voteDiff(previous, restored)
+	details["reverted_to"] = req.Version
+	switch {
+	case req.OwnerToken != "":
+		details["auth"] = "owner"
+	case req.InviteToken != "":
+		details["auth"] = "invite_link"
+	case req.EditToken != "":
+		details["auth"] = "edit_link"
+	default:
+		details["auth"] = "password"
+	}
+	if err := recordEventTx(ctx, tx, sessionID, EventVotesReverted, name, ipHash, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	metrics.VotesCast.Add(float64(resp.Restored))
+	return resp, nil
+}
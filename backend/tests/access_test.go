@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupAccessApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_access.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions/:id/access", api.UnlockSessionHandler)
+	apiGroup.Get("/sessions/:id", api.RequireSessionAccess, api.GetSessionHandler)
+	apiGroup.Post("/sessions/:id/vote", api.RequireSessionAccess, api.VoteHandler)
+	app.Get("/:id", api.ServeSessionPage)
+
+	return app
+}
+
+func TestPrivateSessionAccess(t *testing.T) {
+	app := setupAccessApp()
+	defer os.Remove("test_access.db")
+	ctx := context.Background()
+
+	seed := func(req models.CreateSessionRequest) *models.CreateSessionResponse {
+		req.CreatorName = "Tester"
+		req.Timeslots = []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		}
+		created, err := services.CreateSession(ctx, req)
+		if err != nil {
+			t.Fatalf("Failed to seed session: %v", err)
+		}
+		return created
+	}
+	get := func(id string, headers map[string]string) int {
+		req := httptest.NewRequest("GET", "/api/v1/sessions/"+id, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	public := seed(models.CreateSessionRequest{Title: "Public"})
+	if status := get(public.ID, nil); status != 200 {
+		t.Errorf("Expected 200 for a public session, got %d", status)
+	}
+
+	linked := seed(models.CreateSessionRequest{Title: "Linked", PrivateLink: true})
+	if linked.AccessKey == "" || !strings.Contains(linked.Link, "?key="+linked.AccessKey) {
+		t.Fatalf("Expected the link to carry the access key, got %+v", linked)
+	}
+	if status := get(linked.ID, nil); status != 401 {
+		t.Errorf("Expected 401 without credentials, got %d", status)
+	}
+	if status := get(linked.ID, map[string]string{"X-Access-Key": "wrong"}); status != 403 {
+		t.Errorf("Expected 403 for a wrong key, got %d", status)
+	}
+	if status := get(linked.ID, map[string]string{"X-Access-Key": linked.AccessKey}); status != 200 {
+		t.Errorf("Expected 200 with the key, got %d", status)
+	}
+	if status := get(linked.ID, map[string]string{"X-Owner-Token": linked.OwnerToken}); status != 200 {
+		t.Errorf("Expected 200 for the owner, got %d", status)
+	}
+
+	// Votes are guarded by the same check
+	body, _ := json.Marshal(models.VoteRequest{VoterName: "Ali", Votes: []models.VoteItem{}})
+	voteReq := httptest.NewRequest("POST", "/api/v1/sessions/"+linked.ID+"/vote", bytes.NewReader(body))
+	voteReq.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(voteReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("Expected 401 when voting without credentials, got %d", resp.StatusCode)
+	}
+
+	protected := seed(models.CreateSessionRequest{Title: "Protected", AccessPassword: "secret"})
+	unlock := func(password string) (int, models.AccessResponse) {
+		body, _ := json.Marshal(models.AccessRequest{Password: password})
+		req := httptest.NewRequest("POST", "/api/v1/sessions/"+protected.ID+"/access", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out models.AccessResponse
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	if status, _ := unlock("wrong"); status != 401 {
+		t.Errorf("Expected 401 for a wrong password, got %d", status)
+	}
+	status, granted := unlock("secret")
+	if status != 200 || granted.AccessToken == "" {
+		t.Fatalf("Expected an access token, got %d %+v", status, granted)
+	}
+	if status := get(protected.ID, map[string]string{"X-Access-Token": granted.AccessToken}); status != 200 {
+		t.Errorf("Expected 200 with the access token, got %d", status)
+	}
+	if status := get(linked.ID, map[string]string{"X-Access-Token": granted.AccessToken}); status != 403 {
+		t.Errorf("Expected an access token to be bound to its session, got %d", status)
+	}
+}
+
+func TestPrivateSessionPageMeta(t *testing.T) {
+	app := setupAccessApp()
+	defer os.Remove("test_access.db")
+
+	// ServeSessionPage reads index.html from the working directory
+	page := "<title>BiaMeet | بیا میت</title>"
+	if err := os.WriteFile("index.html", []byte(page), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("index.html")
+
+	created, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:       "Secret Merger Talks",
+		CreatorName: "Tester",
+		PrivateLink: true,
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+
+	fetch := func(path string) (string, string) {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.Header.Get("Referrer-Policy")
+	}
+
+	body, referrer := fetch("/" + created.ID)
+	if strings.Contains(body, "Secret Merger Talks") {
+		t.Errorf("Expected the title to stay out of the page without the key")
+	}
+	if referrer != "no-referrer" {
+		t.Errorf("Expected Referrer-Policy no-referrer, got %q", referrer)
+	}
+
+	body, _ = fetch("/" + created.ID + "?key=" + created.AccessKey)
+	if !strings.Contains(body, "Secret Merger Talks") {
+		t.Errorf("Expected the title in the page with the key")
+	}
+}
@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"biameet.ir/api"
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupAdminApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_admin.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions/:id/vote", api.VoteHandler)
+	apiGroup.Delete("/sessions/:id/timeslots/:ts_id", api.DeleteTimeslotHandler)
+	admin := apiGroup.Group("/admin", api.RequireAdmin)
+	admin.Get("/sessions", api.ListAdminSessionsHandler)
+	admin.Get("/sessions/:id", api.GetAdminSessionHandler)
+	admin.Post("/sessions/:id/archive", api.ArchiveSessionHandler)
+	admin.Delete("/sessions/:id", api.DeleteSessionHandler)
+
+	return app
+}
+
+func TestAdminSessions(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.Admin.Token = "secret-admin-token"
+	})
+	app := setupAdminApp()
+	defer os.Remove("test_admin.db")
+
+	created, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:       "Admin Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+
+	adminReq := func(method, path, token string) (int, []byte) {
+		httpReq := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(httpReq)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, body
+	}
+
+	if status, _ := adminReq("GET", "/api/v1/admin/sessions", ""); status != 401 {
+		t.Errorf("Expected 401 without credentials, got %d", status)
+	}
+	if status, _ := adminReq("GET", "/api/v1/admin/sessions", "wrong"); status != 401 {
+		t.Errorf("Expected 401 with wrong token, got %d", status)
+	}
+
+	status, listBody := adminReq("GET", "/api/v1/admin/sessions?q=admin", "secret-admin-token")
+	if status != 200 {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	var list models.AdminSessionList
+	if err := json.Unmarshal(listBody, &list); err != nil {
+		t.Fatalf("Failed to decode list: %v", err)
+	}
+	if list.Total != 1 || list.Sessions[0].ID != created.ID || list.Sessions[0].TimeslotCount != 1 {
+		t.Errorf("Unexpected session list %+v", list)
+	}
+
+	// Archived sessions no longer accept votes
+	if status, _ := adminReq("POST", "/api/v1/admin/sessions/"+created.ID+"/archive", "secret-admin-token"); status != 200 {
+		t.Fatalf("Expected 200 archiving, got %d", status)
+	}
+	body, _ := json.Marshal(models.VoteRequest{VoterName: "Voter", Votes: []models.VoteItem{}})
+	httpReq := httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/vote", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(httpReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 409 {
+		t.Errorf("Expected 409 voting on archived session, got %d", resp.StatusCode)
+	}
+	session, err := services.GetSession(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	httpReq = httptest.NewRequest("DELETE", "/api/v1/sessions/"+created.ID+"/timeslots/"+session.Timeslots[0].ID, nil)
+	resp, err = app.Test(httpReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	if resp.StatusCode != 409 || out["error"] != "session_archived" {
+		t.Errorf("Expected 409 session_archived deleting a timeslot, got %d %v", resp.StatusCode, out)
+	}
+
+	if status, _ := adminReq("DELETE", "/api/v1/admin/sessions/"+created.ID, "secret-admin-token"); status != 200 {
+		t.Fatalf("Expected 200 deleting, got %d", status)
+	}
+	if status, _ := adminReq("GET", "/api/v1/admin/sessions/"+created.ID, "secret-admin-token"); status != 404 {
+		t.Errorf("Expected 404 after delete, got %d", status)
+	}
+}
+
+func TestAdminStatsSeries(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.Admin.Token = "secret-admin-token"
+	})
+	app := setupAdminApp()
+	app.Get("/api/v1/admin/stats", api.RequireAdmin, api.GetAdminStatsHandler)
+	defer os.Remove("test_admin.db")
+
+	created, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:       "Stats Test",
+		CreatorName: "Tester",
+		Type:        "fixed",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	session, _ := services.GetSession(context.Background(), created.ID)
+	if _, err := services.SubmitVote(context.Background(), created.ID, models.VoteRequest{
+		VoterName: "Voter",
+		Votes:     []models.VoteItem{{TimeslotID: session.Timeslots[0].ID}},
+	}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	httpReq := httptest.NewRequest("GET", "/api/v1/admin/stats?bucket=day&from="+yesterday+"&to="+today, nil)
+	httpReq.Header.Set("Authorization", "Bearer secret-admin-token")
+	resp, err := app.Test(httpReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	var stats models.AdminStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if len(stats.Series) != 2 {
+		t.Fatalf("Expected 2 daily buckets, got %d", len(stats.Series))
+	}
+	if stats.Series[0].SessionsCreated.Total != 0 {
+		t.Errorf("Expected no sessions yesterday, got %d", stats.Series[0].SessionsCreated.Total)
+	}
+	last := stats.Series[1]
+	if last.Start != today {
+		t.Errorf("Expected last bucket %s, got %s", today, last.Start)
+	}
+	if last.SessionsCreated.ByType["fixed"] != 1 || last.TimeslotsProposed.Total != 1 ||
+		last.VotesCast.Total != 1 || last.ActiveParticipants.Total != 1 {
+		t.Errorf("Unexpected bucket %+v", last)
+	}
+
+	httpReq = httptest.NewRequest("GET", "/api/v1/admin/stats?bucket=year", nil)
+	httpReq.Header.Set("Authorization", "Bearer secret-admin-token")
+	resp, err = app.Test(httpReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("Expected 400 for invalid bucket, got %d", resp.StatusCode)
+	}
+}
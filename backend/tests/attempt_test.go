@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"biameet.ir/api"
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupAttemptApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_attempt.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions/:id/vote", api.VoteHandler)
+	apiGroup.Post("/sessions/:id/timeslots", api.AddTimeslotHandler)
+
+	return app
+}
+
+func TestPasswordLockout(t *testing.T) {
+	app := setupAttemptApp()
+	defer os.Remove("test_attempt.db")
+
+	created, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:       "Lockout Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+
+	post := func(password string) (int, string) {
+		body, _ := json.Marshal(models.VoteRequest{
+			VoterName: "Voter 1",
+			Password:  password,
+			Votes:     []models.VoteItem{},
+		})
+		httpReq := httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/vote", bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(httpReq)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		msg, _ := out["error"].(string)
+		return resp.StatusCode, msg
+	}
+
+	if status, _ := post("1234"); status != 200 {
+		t.Fatalf("Expected status 200 for first vote, got %d", status)
+	}
+
+	// A few wrong guesses are allowed, then the name gets locked
+	for i := 0; i < 4; i++ {
+		if _, msg := post("0000"); msg != "invalid_password" {
+			t.Fatalf("Expected invalid_password on attempt %d, got %q", i+1, msg)
+		}
+	}
+
+	status, msg := post("1234")
+	if status != 429 || msg != "too_many_attempts" {
+		t.Errorf("Expected 429 too_many_attempts while locked, got %d %q", status, msg)
+	}
+}
+
+func TestTimeslotProposerPassword(t *testing.T) {
+	app := setupAttemptApp()
+	defer os.Remove("test_attempt.db")
+
+	created, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:       "Proposer Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	if _, err := services.SubmitVote(context.Background(), created.ID, models.VoteRequest{VoterName: "Voter 1", Password: "1234"}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+
+	// Proposing a timeslot under a protected name needs its password
+	for _, tc := range []struct {
+		password string
+		err      string
+	}{
+		{"", "password_required"},
+		{"0000", "invalid_password"},
+	} {
+		body, _ := json.Marshal(models.TimeslotRequest{
+			StartUTC: "2023-01-01T14:00:00Z", EndUTC: "2023-01-01T15:00:00Z", CreatedBy: "Voter 1", Password: tc.password,
+		})
+		httpReq := httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/timeslots", bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(httpReq)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		if resp.StatusCode != 401 || out["error"] != tc.err {
+			t.Errorf("Expected 401 %s, got %d %v", tc.err, resp.StatusCode, out)
+		}
+	}
+}
+
+func TestConcurrentFailuresAreCounted(t *testing.T) {
+	setupAttemptApp()
+	defer os.Remove("test_attempt.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Race Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Voter 1", Password: "1234"}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wrong := 0
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Voter 1", Password: "0000"})
+			if err != nil && err.Error() == "invalid_password" {
+				mu.Lock()
+				wrong++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failures int
+	err = db.DB.QueryRow("SELECT failures FROM auth_failures WHERE scope = 'participant' AND key = ?", created.ID+"/Voter 1").Scan(&failures)
+	if err != nil {
+		t.Fatalf("Failed to read counter: %v", err)
+	}
+	if failures != wrong {
+		t.Errorf("Expected %d failures counted, got %d", wrong, failures)
+	}
+}
+
+func TestLockoutWithSingleConnection(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.Database.MaxOpenConns = 1
+	})
+	setupAttemptApp()
+	defer os.Remove("test_attempt.db")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Single Connection",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Voter 1", Password: "1234"}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+
+	// Both check the password of an existing participant
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Voter 1", Password: "1234"}); err != nil {
+		t.Errorf("Expected the vote to go through, got %v", err)
+	}
+	_, err = services.AddTimeslot(ctx, created.ID, models.TimeslotRequest{
+		StartUTC:  "2023-01-02T12:00:00Z",
+		EndUTC:    "2023-01-02T13:00:00Z",
+		CreatedBy: "Voter 1",
+		Password:  "1234",
+	})
+	if err != nil {
+		t.Errorf("Expected the timeslot to be added, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupCommentApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_comment.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Get("/sessions/:id", api.GetSessionHandler)
+	apiGroup.Post("/sessions/:id/comments", api.AddCommentHandler)
+	apiGroup.Put("/sessions/:id/comments/:comment_id", api.UpdateCommentHandler)
+	apiGroup.Delete("/sessions/:id/comments/:comment_id", api.DeleteCommentHandler)
+
+	return app
+}
+
+func TestCommentThread(t *testing.T) {
+	app := setupCommentApp()
+	defer os.Remove("test_comment.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Comment Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	voted, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Ali", Password: "1234", Votes: []models.VoteItem{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(method, path string, req models.CommentRequest, ownerToken string) (int, models.Comment, string) {
+		body, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest(method, "/api/v1/sessions/"+created.ID+"/comments"+path, bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		if ownerToken != "" {
+			httpReq.Header.Set("X-Owner-Token", ownerToken)
+		}
+		resp, err := app.Test(httpReq)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		var out models.Comment
+		json.Unmarshal(buf.Bytes(), &out)
+		var errBody struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(buf.Bytes(), &errBody)
+		return resp.StatusCode, out, errBody.Error
+	}
+
+	// Ali is a password-protected participant, so his name needs the password
+	if status, _, code := send("POST", "", models.CommentRequest{AuthorName: "Ali", Body: "hi"}, ""); status != 401 || code != "password_required" {
+		t.Errorf("Expected 401 password_required when posting as Ali, got %d %s", status, code)
+	}
+	status, ali, _ := send("POST", "", models.CommentRequest{AuthorName: "Ali", Password: "1234", Body: "  Friday works better  "}, "")
+	if status != 201 || ali.Body != "Friday works better" {
+		t.Fatalf("Expected the comment to be created, got %d %+v", status, ali)
+	}
+	status, guest, _ := send("POST", "", models.CommentRequest{AuthorName: "Guest", Body: "<b>hi</b>"}, "")
+	if status != 201 {
+		t.Fatalf("Expected 201 for a guest comment, got %d", status)
+	}
+	if status, _, code := send("POST", "", models.CommentRequest{AuthorName: "Guest", Body: strings.Repeat("x", models.MaxCommentLength+1)}, ""); status != 400 || code != "comment_too_long" {
+		t.Errorf("Expected 400 comment_too_long, got %d %s", status, code)
+	}
+
+	// Edits need the author's password or edit link
+	if status, _, _ := send("PUT", "/"+ali.ID, models.CommentRequest{Body: "x", Password: "wrong"}, ""); status != 401 {
+		t.Errorf("Expected 401 editing with a wrong password, got %d", status)
+	}
+	status, edited, _ := send("PUT", "/"+ali.ID, models.CommentRequest{Body: "Saturday then", EditToken: voted.EditToken}, "")
+	if status != 200 || edited.Body != "Saturday then" || edited.UpdatedAtUTC == "" {
+		t.Errorf("Expected the edit via edit link, got %d %+v", status, edited)
+	}
+	if status, _, code := send("PUT", "/"+guest.ID, models.CommentRequest{Body: "x"}, ""); status != 403 || code != "comment_not_protected" {
+		t.Errorf("Expected 403 editing an unprotected comment, got %d %s", status, code)
+	}
+	if status, _, code := send("PUT", "/"+guest.ID, models.CommentRequest{Body: "x", EditToken: voted.EditToken}, ""); status != 403 || code != "not_comment_author" {
+		t.Errorf("Expected 403 editing someone else's comment, got %d %s", status, code)
+	}
+
+	// The owner moderates by deleting
+	if status, _, _ := send("DELETE", "/"+guest.ID, models.CommentRequest{}, created.OwnerToken); status != 200 {
+		t.Errorf("Expected the owner to delete, got %d", status)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/sessions/"+created.ID, nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var session models.Session
+	json.NewDecoder(resp.Body).Decode(&session)
+	if len(session.Comments) != 1 || session.Comments[0].Body != "Saturday then" || session.Comments[0].AuthorName != "Ali" {
+		t.Errorf("Expected Ali's edited comment with the session, got %+v", session.Comments)
+	}
+
+	// Erasing Ali takes his comments too
+	erased, err := services.EraseParticipant(ctx, created.ID, models.EraseParticipantRequest{VoterName: "Ali", Password: "1234"})
+	if err != nil || erased.CommentsDeleted != 1 {
+		t.Errorf("Expected one comment erased, got %+v %v", erased, err)
+	}
+}
+
+func TestCommentNameRules(t *testing.T) {
+	setupCommentApp()
+	defer os.Remove("test_comment.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Comment Names",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Reza"}); err != nil {
+		t.Fatal(err)
+	}
+	invitees, err := services.CreateInvitees(ctx, created.ID, []string{"Sara"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A participant without a password cannot be impersonated
+	_, err = services.AddComment(ctx, created.ID, models.CommentRequest{AuthorName: "Reza", Body: "hi"})
+	if err == nil || err.Error() != "name_taken_no_password" {
+		t.Errorf("Expected name_taken_no_password, got %v", err)
+	}
+
+	// Invitees comment through their link, and nobody else can use the name
+	_, err = services.AddComment(ctx, created.ID, models.CommentRequest{AuthorName: "Sara", Body: "hi"})
+	if err == nil || err.Error() != "name_reserved_for_invitee" {
+		t.Errorf("Expected name_reserved_for_invitee, got %v", err)
+	}
+	comment, err := services.AddComment(ctx, created.ID, models.CommentRequest{InviteToken: invitees[0].Token, Body: "hi"})
+	if err != nil || comment.AuthorName != "Sara" {
+		t.Fatalf("Expected the invitee to comment as Sara, got %+v (%v)", comment, err)
+	}
+	_, err = services.UpdateComment(ctx, created.ID, comment.ID, models.CommentRequest{InviteToken: invitees[0].Token, Body: "edited"})
+	if err != nil {
+		t.Errorf("Expected the invitee to edit their comment, got %v", err)
+	}
+	_, err = services.AddComment(ctx, created.ID, models.CommentRequest{InviteToken: "nope", Body: "hi"})
+	if err == nil || err.Error() != "invalid_invite_token" {
+		t.Errorf("Expected invalid_invite_token, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"biameet.ir/config"
+)
+
+// withConfig runs the rest of the test against a modified default config.
+func withConfig(t *testing.T, modify func(*config.Config)) {
+	t.Helper()
+	cfg := config.Default()
+	modify(cfg)
+	previous := config.Get()
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(previous) })
+}
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := config.ParseRateLimit("30/1m")
+	if err != nil || limit.Max != 30 || limit.Window != time.Minute {
+		t.Errorf("Expected 30 per minute, got %+v (%v)", limit, err)
+	}
+	if limit, err := config.ParseRateLimit("off"); err != nil || limit.Max != 0 {
+		t.Errorf("Expected disabled limit, got %+v (%v)", limit, err)
+	}
+	if _, err := config.ParseRateLimit("30"); err == nil {
+		t.Error("Expected error for missing window")
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "biameet.yaml")
+	yaml := "server:\n  listen_addr: \":9000\"\n  base_url: https://file.example\ndatabase:\n  path: file.db\nsecurity:\n  bcrypt_cost: 12\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DB_PATH", "env.db")
+	t.Setenv("BCRYPT_COST", "11")
+
+	cfg, _, err := config.Load([]string{"--config", path, "--bcrypt-cost", "6"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.ListenAddr != ":9000" || cfg.Server.BaseURL != "https://file.example" {
+		t.Errorf("Expected file values, got %+v", cfg.Server)
+	}
+	if cfg.Database.Path != "env.db" {
+		t.Errorf("Expected env to override file, got %q", cfg.Database.Path)
+	}
+	if cfg.Security.BcryptCost != 6 {
+		t.Errorf("Expected flag to override env, got %d", cfg.Security.BcryptCost)
+	}
+	if cfg.RateLimits.IP != "60/1m" {
+		t.Errorf("Expected default rate limit, got %q", cfg.RateLimits.IP)
+	}
+}
+
+func TestConfigTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "biameet.toml")
+	toml := "[security]\nsession_id_length = 8\n\n[features]\nmetrics = false\n"
+	if err := os.WriteFile(path, []byte(toml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := config.Load([]string{"--config", path})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Security.SessionIDLength != 8 || cfg.Features.Metrics {
+		t.Errorf("Expected TOML values, got %+v %+v", cfg.Security, cfg.Features)
+	}
+
+	os.WriteFile(path, []byte("[security]\nbcrypt = 8\n"), 0o600)
+	if _, _, err := config.Load([]string{"--config", path}); err == nil {
+		t.Error("Expected error for unknown key")
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	cases := map[string][]string{
+		"bcrypt_cost":       {"--bcrypt-cost", "3"},
+		"session_id_length": {"--id-length", "2"},
+		"base_url":          {"--base-url", "biameet.ir"},
+		"logging.level":     {"--log-level", "verbose"},
+	}
+	for want, args := range cases {
+		_, _, err := config.Load(args)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%v: expected error mentioning %s, got %v", args, want, err)
+		}
+	}
+
+	t.Setenv("RATE_LIMIT_IP", "lots")
+	if _, _, err := config.Load(nil); err == nil {
+		t.Error("Expected error for invalid rate limit")
+	}
+}
+
+func TestPrintConfigRedactsSecrets(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "top-secret")
+	cfg, opts, err := config.Load([]string{"--print-config"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !opts.PrintConfig {
+		t.Error("Expected --print-config to be set")
+	}
+
+	var buf bytes.Buffer
+	if err := config.Print(&buf, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "top-secret") {
+		t.Error("Admin token leaked into printed config")
+	}
+	if !strings.Contains(buf.String(), "listen_addr: :8080") {
+		t.Errorf("Expected listen address in output:\n%s", buf.String())
+	}
+	if cfg.Admin.Token != "top-secret" {
+		t.Error("Redaction must not modify the loaded config")
+	}
+}
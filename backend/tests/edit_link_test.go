@@ -0,0 +1,227 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupEditLinkApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_edit_link.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions/:id/vote", api.VoteHandler)
+
+	return app
+}
+
+func TestEditLinkVote(t *testing.T) {
+	app := setupEditLinkApp()
+	defer os.Remove("test_edit_link.db")
+
+	created, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:       "Edit Link Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	session, err := services.GetSession(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	tsID := session.Timeslots[0].ID
+
+	post := func(req models.VoteRequest) (int, models.VoteResponse) {
+		body, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/vote", bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(httpReq)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out models.VoteResponse
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, first := post(models.VoteRequest{
+		VoterName: "Voter 1",
+		Password:  "1234",
+		Votes:     []models.VoteItem{{TimeslotID: tsID}},
+	})
+	if status != 200 {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if first.EditToken == "" || first.EditLink == "" {
+		t.Fatalf("Expected an edit link in the response, got %+v", first)
+	}
+
+	// Editing with the magic link needs no name or password
+	status, _ = post(models.VoteRequest{
+		EditToken: first.EditToken,
+		Votes:     []models.VoteItem{},
+	})
+	if status != 200 {
+		t.Errorf("Expected status 200 when editing via edit token, got %d", status)
+	}
+
+	// A tampered token is rejected
+	status, _ = post(models.VoteRequest{
+		EditToken: first.EditToken + "x",
+		Votes:     []models.VoteItem{},
+	})
+	if status != 401 {
+		t.Errorf("Expected status 401 for tampered edit token, got %d", status)
+	}
+}
+
+func TestEditLinkDisabled(t *testing.T) {
+	app := setupEditLinkApp()
+	defer os.Remove("test_edit_link.db")
+
+	created, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:       "Edit Link Off",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	voted, err := services.SubmitVote(context.Background(), created.ID, models.VoteRequest{VoterName: "Voter 1", Password: "1234"})
+	if err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	token := voted.EditToken
+
+	withConfig(t, func(c *config.Config) {
+		c.Features.EditLinks = false
+	})
+
+	// Without the token the request has no name to vote under
+	body, _ := json.Marshal(models.VoteRequest{EditToken: token, Votes: []models.VoteItem{}})
+	httpReq := httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/vote", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(httpReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 403 {
+		t.Errorf("Expected status 403 with edit links disabled, got %d", resp.StatusCode)
+	}
+
+	var count int
+	db.DB.QueryRow("SELECT COUNT(*) FROM participants WHERE session_id = ?", created.ID).Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected only the existing participant, got %d", count)
+	}
+}
+
+func TestRevokeEditLinks(t *testing.T) {
+	setupEditLinkApp()
+	defer os.Remove("test_edit_link.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Revoke Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	voted, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Voter 1", Password: "1234"})
+	if err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+
+	// The owner token must not mint an edit link for someone else
+	owned, err := services.RevokeEditLinks(ctx, created.ID, models.ParticipantCredentials{VoterName: "Voter 1", OwnerToken: created.OwnerToken})
+	if err == nil || err.Error() != "owner_token_not_allowed" || owned != nil {
+		t.Errorf("Expected the owner to get no edit token, got %v, %v", owned, err)
+	}
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{EditToken: voted.EditToken}); err != nil {
+		t.Errorf("Expected the owner's attempt to leave links intact, got %v", err)
+	}
+
+	fresh, err := services.RevokeEditLinks(ctx, created.ID, models.ParticipantCredentials{VoterName: "Voter 1", Password: "1234"})
+	if err != nil {
+		t.Fatalf("Failed to revoke: %v", err)
+	}
+	_, err = services.SubmitVote(ctx, created.ID, models.VoteRequest{EditToken: voted.EditToken})
+	if err == nil || err.Error() != "invalid_edit_token" {
+		t.Errorf("Expected the old token to be revoked, got %v", err)
+	}
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{EditToken: fresh.EditToken}); err != nil {
+		t.Errorf("Expected the fresh token to work, got %v", err)
+	}
+
+	// Erasing the participant revokes their links too
+	if _, err := services.EraseParticipant(ctx, created.ID, models.EraseParticipantRequest{VoterName: "Voter 1", Password: "1234"}); err != nil {
+		t.Fatalf("Failed to erase: %v", err)
+	}
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Voter 1", Password: "5678"}); err != nil {
+		t.Fatalf("Failed to vote again: %v", err)
+	}
+	_, err = services.SubmitVote(ctx, created.ID, models.VoteRequest{EditToken: fresh.EditToken})
+	if err == nil || err.Error() != "invalid_edit_token" {
+		t.Errorf("Expected the erased participant's token to be revoked, got %v", err)
+	}
+}
+
+func TestEditLinkMailLimit(t *testing.T) {
+	setupEditLinkApp()
+	defer os.Remove("test_edit_link.db")
+	ctx := context.Background()
+
+	// Nothing listens here, so sending fails quietly in the background
+	withConfig(t, func(c *config.Config) {
+		c.Mail.SMTPHost = "127.0.0.1"
+		c.Mail.SMTPPort = 1
+	})
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Mail Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	req := models.VoteRequest{VoterName: "Voter 1", Password: "1234", Email: "someone@example.com"}
+	first, err := services.SubmitVote(ctx, created.ID, req)
+	if err != nil || !first.EditLinkMailed {
+		t.Fatalf("Expected the first link to be mailed, got %+v (%v)", first, err)
+	}
+	second, err := services.SubmitVote(ctx, created.ID, req)
+	if err != nil || second.EditLinkMailed {
+		t.Errorf("Expected the second mail to be held back, got %+v (%v)", second, err)
+	}
+	services.WaitBackground(ctx)
+}
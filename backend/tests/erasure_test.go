@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupErasureApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_erasure.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions/:id/participants/erase", api.EraseParticipantHandler)
+
+	return app
+}
+
+func TestEraseParticipant(t *testing.T) {
+	app := setupErasureApp()
+	defer os.Remove("test_erasure.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Erasure Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	id := created.ID
+
+	// Ali proposes two timeslots, Sara votes on one of them
+	shared, err := services.AddTimeslot(ctx, id, models.TimeslotRequest{
+		StartUTC: "2023-01-02T12:00:00Z", EndUTC: "2023-01-02T13:00:00Z", CreatedBy: "Ali", Password: "pw",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.AddTimeslot(ctx, id, models.TimeslotRequest{
+		StartUTC: "2023-01-03T12:00:00Z", EndUTC: "2023-01-03T13:00:00Z", CreatedBy: "Ali", Password: "pw",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sara, err := services.SubmitVote(ctx, id, models.VoteRequest{
+		VoterName: "Sara",
+		Votes:     []models.VoteItem{{TimeslotID: shared.ID}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(req models.EraseParticipantRequest) (int, map[string]interface{}) {
+		body, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest("POST", "/api/v1/sessions/"+id+"/participants/erase", bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(httpReq)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	if status, _ := post(models.EraseParticipantRequest{VoterName: "Ali", Password: "wrong"}); status != 401 {
+		t.Errorf("Expected 401 for wrong password, got %d", status)
+	}
+
+	status, out := post(models.EraseParticipantRequest{VoterName: "Ali", Password: "pw"})
+	if status != 200 {
+		t.Fatalf("Expected 200, got %d %v", status, out)
+	}
+	if out["votes_deleted"] != 2.0 || out["timeslots_deleted"] != 1.0 || out["timeslots_anonymized"] != 1.0 {
+		t.Errorf("Unexpected erasure counts: %v", out)
+	}
+
+	session, err := services.GetSession(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Timeslots) != 2 {
+		t.Errorf("Expected the original and the shared timeslot to remain, got %d", len(session.Timeslots))
+	}
+	for _, ts := range session.Timeslots {
+		if ts.CreatedBy == "Ali" {
+			t.Error("Erased name still shown as timeslot creator")
+		}
+		for _, v := range ts.Votes {
+			if v.VoterName == "Ali" {
+				t.Error("Erased participant still has votes")
+			}
+		}
+	}
+
+	// The audit trail records the erasure but not the name
+	var details string
+	err = db.DB.QueryRow("SELECT details FROM session_events WHERE session_id = ? AND type = 'participant_erased'", id).Scan(&details)
+	if err != nil {
+		t.Fatalf("Expected an audit entry: %v", err)
+	}
+	if strings.Contains(details, "Ali") {
+		t.Errorf("Audit entry leaks the erased name: %s", details)
+	}
+	var named int
+	db.DB.QueryRow("SELECT COUNT(*) FROM session_events WHERE session_id = ? AND actor = 'Ali'", id).Scan(&named)
+	if named != 0 {
+		t.Errorf("Expected earlier events by the erased participant to lose the name, got %d", named)
+	}
+
+	// Participants without a password use their edit link
+	if status, _ := post(models.EraseParticipantRequest{VoterName: "Sara"}); status != 403 {
+		t.Errorf("Expected 403 without credentials, got %d", status)
+	}
+	if status, out := post(models.EraseParticipantRequest{EditToken: sara.EditToken}); status != 200 {
+		t.Errorf("Expected 200 via edit token, got %d %v", status, out)
+	}
+	if status, _ := post(models.EraseParticipantRequest{EditToken: sara.EditToken}); status != 401 {
+		t.Errorf("Expected the edit token to stop working after erasure, got %d", status)
+	}
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"encoding/json"
 	"net/http/httptest"
 	"os"
@@ -43,7 +44,7 @@ func TestGetSession(t *testing.T) {
 			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
 		},
 	}
-	created, err := services.CreateSession(req)
+	created, err := services.CreateSession(context.Background(), req)
 	if err != nil {
 		t.Fatalf("Failed to seed session: %v", err)
 	}
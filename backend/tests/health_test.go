@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupHealthApp() *fiber.App {
+	app := fiber.New()
+
+	testDB := "test_health.db"
+	os.Remove(testDB)
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	app.Get("/livez", api.LivenessHandler)
+	app.Get("/readyz", api.ReadinessHandler)
+	return app
+}
+
+func readyStatus(t *testing.T, app *fiber.App) (int, map[string]interface{}) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/readyz", nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestProbes(t *testing.T) {
+	app := setupHealthApp()
+	defer os.Remove("test_health.db")
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/livez", nil))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("Expected live, got %v %v", resp, err)
+	}
+
+	if status, body := readyStatus(t, app); status != 200 {
+		t.Errorf("Expected ready, got %d %v", status, body)
+	}
+
+	// A migration that has not run makes the server unready
+	if _, err := db.DB.Exec("DELETE FROM schema_migrations WHERE version = '009'"); err != nil {
+		t.Fatal(err)
+	}
+	status, body := readyStatus(t, app)
+	if status != 503 {
+		t.Errorf("Expected 503 with pending migration, got %d %v", status, body)
+	}
+	checks, _ := body["checks"].(map[string]interface{})
+	if _, ok := checks["migrations"].(map[string]interface{}); !ok {
+		t.Errorf("Expected pending migrations in checks, got %v", checks)
+	}
+
+	// A closed database is not ready either
+	db.Close()
+	if status, _ := readyStatus(t, app); status != 503 {
+		t.Errorf("Expected 503 with closed database, got %d", status)
+	}
+}
+
+func TestMigrationsAdoptLegacyDatabase(t *testing.T) {
+	testDB := "test_legacy.db"
+	os.Remove(testDB)
+	defer os.Remove(testDB)
+
+	// A database from before migrations were tracked: tables exist but
+	// there is no schema_migrations table
+	legacy, err := sql.Open("sqlite", testDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = legacy.Exec(`
+		CREATE TABLE sessions (id TEXT PRIMARY KEY, title TEXT NOT NULL, creator_name TEXT NOT NULL,
+			created_at_utc TEXT NOT NULL, expires_at_utc TEXT, archived_at_utc TEXT, type TEXT DEFAULT 'fixed');
+	`)
+	legacy.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.InitDB(testDB); err != nil {
+		t.Fatalf("Expected legacy database to be adopted, got %v", err)
+	}
+	pending, err := db.PendingMigrations(context.Background())
+	if err != nil || pending != 0 {
+		t.Errorf("Expected no pending migrations, got %d (%v)", pending, err)
+	}
+	var hasColumn bool
+	db.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM pragma_table_info('sessions') WHERE name = 'dynamic_config')").Scan(&hasColumn)
+	if !hasColumn {
+		t.Error("Expected later migrations to be applied to the legacy database")
+	}
+}
+
+func TestReadinessDoesNotWrite(t *testing.T) {
+	testDB := "test_health_readonly.db"
+	os.Remove(testDB)
+	defer os.Remove(testDB)
+
+	// Opened without migrating, as a tool would
+	if err := db.Open(testDB); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	app := fiber.New()
+	app.Get("/readyz", api.ReadinessHandler)
+
+	if status, _ := readyStatus(t, app); status != 503 {
+		t.Errorf("Expected 503 without a schema, got %d", status)
+	}
+	var exists bool
+	db.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE name = 'schema_migrations')").Scan(&exists)
+	if exists {
+		t.Error("Expected the probe not to create schema_migrations")
+	}
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupHistoryApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_history.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions/:id/vote", api.VoteHandler)
+	apiGroup.Get("/sessions/:id/history", api.RequireOwner, api.SessionHistoryHandler)
+
+	return app
+}
+
+func TestSessionHistory(t *testing.T) {
+	app := setupHistoryApp()
+	defer os.Remove("test_history.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "History Test",
+		CreatorName: "Tester",
+		ClientIP:    "10.0.0.1",
+		Type:        "fixed",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+			{StartUTC: "2023-01-02T12:00:00Z", EndUTC: "2023-01-02T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	session, _ := services.GetSession(ctx, created.ID)
+	first, second := session.Timeslots[0].ID, session.Timeslots[1].ID
+
+	vote := func(votes []models.VoteItem) {
+		body, _ := json.Marshal(models.VoteRequest{VoterName: "Ali", Password: "1234", Votes: votes})
+		req := httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/vote", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf("Vote failed: %v %v", err, resp.StatusCode)
+		}
+	}
+	vote([]models.VoteItem{{TimeslotID: first}})
+	vote([]models.VoteItem{{TimeslotID: first, Note: "late"}, {TimeslotID: second}})
+	vote([]models.VoteItem{{TimeslotID: second}})
+
+	added, err := services.AddTimeslot(ctx, created.ID, models.TimeslotRequest{
+		StartUTC: "2023-01-03T12:00:00Z", EndUTC: "2023-01-03T13:00:00Z",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := services.DeleteTimeslot(ctx, created.ID, added.ID, models.DeleteTimeslotRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query, ownerToken string) (int, models.SessionHistory) {
+		req := httptest.NewRequest("GET", "/api/v1/sessions/"+created.ID+"/history"+query, nil)
+		req.Header.Set("X-Owner-Token", ownerToken)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out models.SessionHistory
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	if status, _ := get("", ""); status != 401 {
+		t.Errorf("Expected 401 without the owner token, got %d", status)
+	}
+
+	status, history := get("", created.OwnerToken)
+	if status != 200 {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	var types []string
+	for _, e := range history.Events {
+		types = append(types, e.Type)
+	}
+	want := []string{"timeslot_deleted", "timeslot_added", "vote_submitted", "vote_submitted", "vote_submitted", "session_created"}
+	if len(types) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, types)
+		}
+	}
+	if created := history.Events[5]; created.Actor != "Tester" || created.IPHash == "" || created.IPHash == "10.0.0.1" {
+		t.Errorf("Expected the creator with a hashed address, got %+v", created)
+	}
+
+	// The second ballot added one slot and changed a note, the third removed one
+	status, votes := get("?type=vote_submitted", created.OwnerToken)
+	if status != 200 || votes.Total != 3 {
+		t.Fatalf("Expected 3 vote events, got %d %+v", status, votes)
+	}
+	var diff struct {
+		Added       []string `json:"added"`
+		Removed     []string `json:"removed"`
+		NoteChanged []string `json:"note_changed"`
+		Auth        string   `json:"auth"`
+	}
+	json.Unmarshal(votes.Events[1].Details, &diff)
+	if len(diff.Added) != 1 || diff.Added[0] != second || len(diff.NoteChanged) != 1 || diff.NoteChanged[0] != first || diff.Auth != "password" {
+		t.Errorf("Unexpected diff for the second ballot: %+v", diff)
+	}
+	json.Unmarshal(votes.Events[0].Details, &diff)
+	if len(diff.Removed) != 1 || diff.Removed[0] != first || len(diff.Added) != 0 {
+		t.Errorf("Unexpected diff for the third ballot: %+v", diff)
+	}
+}
@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupInviteeApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_invitee.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions/:id/vote", api.VoteHandler)
+	apiGroup.Post("/sessions/:id/invitees", api.RequireOwner, api.CreateInviteesHandler)
+	apiGroup.Get("/sessions/:id/invitees", api.RequireOwner, api.ListInviteesHandler)
+
+	return app
+}
+
+func TestInviteeVote(t *testing.T) {
+	app := setupInviteeApp()
+	defer os.Remove("test_invitee.db")
+
+	created, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:       "Invite Test",
+		CreatorName: "Owner",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	session, err := services.GetSession(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	tsID := session.Timeslots[0].ID
+
+	// Creating invitees requires the owner token
+	body, _ := json.Marshal(models.CreateInviteesRequest{Names: []string{"Sara"}})
+	httpReq := httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/invitees", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(httpReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("Expected status 401 without owner token, got %d", resp.StatusCode)
+	}
+
+	httpReq = httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/invitees", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Owner-Token", created.OwnerToken)
+	resp, err = app.Test(httpReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 201 {
+		t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+	}
+	var invitees []models.Invitee
+	if err := json.NewDecoder(resp.Body).Decode(&invitees); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(invitees) != 1 || invitees[0].Token == "" {
+		t.Fatalf("Expected one invitee with a token, got %+v", invitees)
+	}
+
+	// Nobody else can squat the invitee's name
+	body, _ = json.Marshal(models.VoteRequest{
+		VoterName: "Sara",
+		Votes:     []models.VoteItem{{TimeslotID: tsID}},
+	})
+	httpReq = httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/vote", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(httpReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 409 {
+		t.Errorf("Expected status 409 for reserved name, got %d", resp.StatusCode)
+	}
+
+	// The invite token votes, and can vote again to edit without a password
+	body, _ = json.Marshal(models.VoteRequest{
+		InviteToken: invitees[0].Token,
+		Votes:       []models.VoteItem{{TimeslotID: tsID}},
+	})
+	for i := 0; i < 2; i++ {
+		httpReq = httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/vote", bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		resp, err = app.Test(httpReq)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if resp.StatusCode != 200 {
+			t.Errorf("Expected status 200 for invitee vote %d, got %d", i+1, resp.StatusCode)
+		}
+	}
+
+	httpReq = httptest.NewRequest("GET", "/api/v1/sessions/"+created.ID+"/invitees", nil)
+	httpReq.Header.Set("X-Owner-Token", created.OwnerToken)
+	resp, err = app.Test(httpReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	invitees = nil
+	if err := json.NewDecoder(resp.Body).Decode(&invitees); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(invitees) != 1 || !invitees[0].Responded {
+		t.Errorf("Expected invitee to be marked as responded, got %+v", invitees)
+	}
+	if invitees[0].Token != "" {
+		t.Error("Expected token to be omitted from the listing")
+	}
+}
+
+func TestInviteeNameReservation(t *testing.T) {
+	setupInviteeApp()
+	defer os.Remove("test_invitee.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Reservation Test",
+		CreatorName: "Owner",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	invitees, err := services.CreateInvitees(ctx, created.ID, []string{"Sara"})
+	if err != nil {
+		t.Fatalf("Failed to create invitee: %v", err)
+	}
+	if want := "/" + created.ID + "?invite=" + invitees[0].Token; invitees[0].Link != want {
+		t.Errorf("Expected link %q, got %q", want, invitees[0].Link)
+	}
+
+	// Nobody can take the invitee's name by adding a timeslot or a comment
+	_, err = services.AddTimeslot(ctx, created.ID, models.TimeslotRequest{
+		StartUTC:  "2023-01-02T12:00:00Z",
+		EndUTC:    "2023-01-02T13:00:00Z",
+		CreatedBy: "Sara",
+		Password:  "mine",
+	})
+	if err == nil || err.Error() != "name_reserved_for_invitee" {
+		t.Errorf("Expected name_reserved_for_invitee for a timeslot, got %v", err)
+	}
+	_, err = services.AddComment(ctx, created.ID, models.CommentRequest{AuthorName: "Sara", Body: "hi"})
+	if err == nil || err.Error() != "name_reserved_for_invitee" {
+		t.Errorf("Expected name_reserved_for_invitee for a comment, got %v", err)
+	}
+
+	// An invite link cannot take over a password-protected participant
+	_, err = services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Reza", Password: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	if _, err = services.CreateInvitees(ctx, created.ID, []string{"Reza"}); err == nil || err.Error() != "invitee_name_taken" {
+		t.Errorf("Expected invitee_name_taken, got %v", err)
+	}
+}
+
+func TestInviteeLinksDisabled(t *testing.T) {
+	setupInviteeApp()
+	defer os.Remove("test_invitee.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:          "Disabled Invite Test",
+		CreatorName:    "Owner",
+		AccessPassword: "secret",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	invitees, err := services.CreateInvitees(ctx, created.ID, []string{"Sara"})
+	if err != nil {
+		t.Fatalf("Failed to create invitee: %v", err)
+	}
+	token := invitees[0].Token
+
+	// Links handed out before the feature was turned off stop working
+	withConfig(t, func(c *config.Config) {
+		c.Features.Invitees = false
+	})
+	if err := services.CheckSessionAccess(ctx, created.ID, models.SessionAccess{InviteToken: token}); err == nil {
+		t.Errorf("Expected the invite link to no longer open the session")
+	}
+	if viewer := services.ResolveViewer(ctx, created.ID, "", "", token); viewer.Name != "" {
+		t.Errorf("Expected an anonymous viewer, got %+v", viewer)
+	}
+	_, err = services.SubmitVote(ctx, created.ID, models.VoteRequest{InviteToken: token})
+	if err == nil || err.Error() != "invalid_invite_token" {
+		t.Errorf("Expected invalid_invite_token voting, got %v", err)
+	}
+	_, err = services.AddComment(ctx, created.ID, models.CommentRequest{InviteToken: token, Body: "Hello"})
+	if err == nil || err.Error() != "invalid_invite_token" {
+		t.Errorf("Expected invalid_invite_token commenting, got %v", err)
+	}
+}
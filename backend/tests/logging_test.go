@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"biameet.ir/logging"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+func TestRequestLogging(t *testing.T) {
+	previous := slog.Default()
+	defer slog.SetDefault(previous)
+
+	var buf bytes.Buffer
+	if err := logging.Setup(&buf, "info", "json"); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := logging.Setup(&buf, "loud", "json"); err == nil {
+		t.Error("Expected error for invalid level")
+	}
+
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Use(logging.Middleware())
+	app.Get("/ping", func(c *fiber.Ctx) error {
+		logging.FromContext(c.UserContext()).Info("inside handler")
+		return c.SendString("pong")
+	})
+
+	httpReq := httptest.NewRequest("GET", "/ping", nil)
+	httpReq.Header.Set("X-Request-ID", "req-123")
+	resp, err := app.Test(httpReq)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.Header.Get("X-Request-ID") != "req-123" {
+		t.Errorf("Expected X-Request-ID to be echoed, got %q", resp.Header.Get("X-Request-ID"))
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %d: %s", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line is not JSON: %s", line)
+		}
+		if entry["request_id"] != "req-123" {
+			t.Errorf("Expected request_id on every line, got %s", line)
+		}
+	}
+	if !strings.Contains(lines[1], `"route":"/ping"`) || !strings.Contains(lines[1], `"status":200`) {
+		t.Errorf("Unexpected access log line %s", lines[1])
+	}
+}
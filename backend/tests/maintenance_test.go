@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	_ "modernc.org/sqlite"
+)
+
+func setupMaintenanceDB() {
+	testDB := "test_maintenance.db"
+	os.Remove(testDB)
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+}
+
+func latestMigration(t *testing.T) string {
+	t.Helper()
+	migrations, err := db.LoadMigrations()
+	if err != nil || len(migrations) == 0 {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func createVotedSession(t *testing.T, title string) string {
+	t.Helper()
+	ctx := context.Background()
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       title,
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	session, err := services.GetSession(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	_, err = services.SubmitVote(ctx, created.ID, models.VoteRequest{
+		VoterName: "Ali",
+		Password:  "secret",
+		Votes:     []models.VoteItem{{TimeslotID: session.Timeslots[0].ID, Note: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	return created.ID
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	setupMaintenanceDB()
+	defer os.Remove("test_maintenance.db")
+	ctx := context.Background()
+
+	id := createVotedSession(t, "Export Test")
+	export, err := services.ExportSessions(ctx, nil)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(export.Sessions) != 1 || export.SchemaVersion == "" {
+		t.Fatalf("Unexpected export: %+v", export)
+	}
+
+	// Existing sessions are skipped unless replaced
+	result, err := services.ImportSessions(ctx, export, false)
+	if err != nil || len(result.Skipped) != 1 || len(result.Imported) != 0 {
+		t.Fatalf("Expected skip, got %+v (%v)", result, err)
+	}
+
+	if err := services.DeleteSession(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	result, err = services.ImportSessions(ctx, export, false)
+	if err != nil || len(result.Imported) != 1 {
+		t.Fatalf("Expected import, got %+v (%v)", result, err)
+	}
+
+	session, err := services.GetSession(ctx, id)
+	if err != nil {
+		t.Fatalf("Imported session missing: %v", err)
+	}
+	if len(session.Timeslots) != 1 || len(session.Timeslots[0].Votes) != 1 || session.Timeslots[0].Votes[0].Note != "hi" {
+		t.Errorf("Votes not restored: %+v", session.Timeslots)
+	}
+
+	// The participant password survives the round trip
+	_, err = services.SubmitVote(ctx, id, models.VoteRequest{VoterName: "Ali", Password: "wrong"})
+	if err == nil || err.Error() != "invalid_password" {
+		t.Errorf("Expected invalid_password after import, got %v", err)
+	}
+}
+
+func TestPurgeSessions(t *testing.T) {
+	setupMaintenanceDB()
+	defer os.Remove("test_maintenance.db")
+	ctx := context.Background()
+
+	oldID := createVotedSession(t, "Old")
+	newID := createVotedSession(t, "New")
+	db.DB.Exec("UPDATE sessions SET created_at_utc = '2020-01-01T00:00:00Z' WHERE id = ?", oldID)
+
+	cutoff := time.Now().AddDate(0, 0, -30)
+	ids, err := services.PurgeSessions(ctx, cutoff, true)
+	if err != nil || len(ids) != 1 || ids[0] != oldID {
+		t.Fatalf("Dry run: expected [%s], got %v (%v)", oldID, ids, err)
+	}
+	if _, err := services.GetSession(ctx, oldID); err != nil {
+		t.Errorf("Dry run must not delete: %v", err)
+	}
+
+	if _, err := services.PurgeSessions(ctx, cutoff, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.GetSession(ctx, oldID); err == nil {
+		t.Error("Expected old session to be purged")
+	}
+	var votes int
+	db.DB.QueryRow("SELECT COUNT(*) FROM votes").Scan(&votes)
+	if votes != 1 {
+		t.Errorf("Expected only the new session's vote to remain, got %d", votes)
+	}
+	if _, err := services.GetSession(ctx, newID); err != nil {
+		t.Errorf("New session should remain: %v", err)
+	}
+}
+
+func TestMigrateDownAndBackup(t *testing.T) {
+	setupMaintenanceDB()
+	defer os.Remove("test_maintenance.db")
+	ctx := context.Background()
+
+	latest := latestMigration(t)
+	reverted, err := db.MigrateDown(ctx, 2)
+	if err != nil || len(reverted) != 2 || reverted[0].Version != latest {
+		t.Fatalf("Expected the last two migrations reverted, got %+v (%v)", reverted, err)
+	}
+	if pending, _ := db.PendingMigrations(ctx); pending != 2 {
+		t.Errorf("Expected 2 pending migrations, got %d", pending)
+	}
+	if n, err := db.Migrate(ctx); err != nil || n != 2 {
+		t.Fatalf("Expected 2 migrations re-applied, got %d (%v)", n, err)
+	}
+
+	createVotedSession(t, "Backup Test")
+	backup := "test_maintenance_backup.db"
+	os.Remove(backup)
+	defer os.Remove(backup)
+	if err := db.Backup(ctx, backup); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := db.Backup(ctx, backup); err == nil {
+		t.Error("Expected backup to refuse overwriting a file")
+	}
+}
+
+func TestBackupRetentionAndRestore(t *testing.T) {
+	setupMaintenanceDB()
+	defer os.Remove("test_maintenance.db")
+	ctx := context.Background()
+	id := createVotedSession(t, "Restore Test")
+
+	dir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		if _, err := db.BackupToDir(ctx, dir, 2); err != nil {
+			t.Fatalf("Backup %d failed: %v", i, err)
+		}
+	}
+	backups, err := db.ListBackups(dir)
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("Expected 2 retained backups, got %v (%v)", backups, err)
+	}
+	latest := backups[len(backups)-1]
+	if version, err := db.CheckBackup(ctx, latest); err != nil || version != latestMigration(t) {
+		t.Fatalf("Expected valid backup at the latest version, got %q (%v)", version, err)
+	}
+
+	target := filepath.Join(dir, "restored.db")
+	os.WriteFile(target, []byte("old"), 0o600)
+	previous, err := db.Restore(ctx, latest, target)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if data, _ := os.ReadFile(previous); string(data) != "old" {
+		t.Errorf("Expected previous database kept at %s", previous)
+	}
+	restored, err := sql.Open("sqlite", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var title string
+	restored.QueryRow("SELECT title FROM sessions WHERE id = ?", id).Scan(&title)
+	restored.Close()
+	if title != "Restore Test" {
+		t.Errorf("Expected restored session, got %q", title)
+	}
+
+	// Snapshots from a newer build are refused
+	newer, _ := sql.Open("sqlite", latest)
+	newer.Exec("INSERT INTO schema_migrations (version, name, applied_at_utc) VALUES ('999', 'future', '2030-01-01T00:00:00Z')")
+	newer.Close()
+	if _, err := db.Restore(ctx, latest, target); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Expected newer schema to be refused, got %v", err)
+	}
+
+	// So are files that are not biameet databases
+	junk := filepath.Join(dir, "junk.db")
+	os.WriteFile(junk, []byte("not sqlite"), 0o600)
+	if _, err := db.CheckBackup(ctx, junk); err == nil {
+		t.Error("Expected junk file to be refused")
+	}
+}
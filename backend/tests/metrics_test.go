@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/metrics"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	app := fiber.New()
+	testDB := "test_metrics.db"
+	os.Remove(testDB)
+	if err := db.InitDB(testDB); err != nil {
+		t.Fatalf("Failed to init db: %v", err)
+	}
+	defer os.Remove(testDB)
+
+	app.Use(metrics.Middleware())
+	app.Get("/metrics", metrics.Handler())
+	app.Get("/api/v1/sessions/:id", api.GetSessionHandler)
+
+	created, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:       "Metrics Test",
+		CreatorName: "Tester",
+		Type:        "fixed",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	if _, err := app.Test(httptest.NewRequest("GET", "/api/v1/sessions/"+created.ID, nil)); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	text := string(body)
+
+	for _, want := range []string{
+		`biameet_http_requests_total{method="GET",route="/api/v1/sessions/:id",status="200"}`,
+		`biameet_service_operation_duration_seconds_count{operation="get_session"}`,
+		`biameet_sessions_created_total{type="fixed"}`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected metrics output to contain %s", want)
+		}
+	}
+}
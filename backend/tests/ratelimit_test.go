@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"math/bits"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"biameet.ir/api"
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func TestSessionRateLimiter(t *testing.T) {
+	app := fiber.New()
+	app.Post("/sessions/:id/vote", api.SessionRateLimiter(config.RateLimit{Max: 2, Window: time.Minute}), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	for i := 1; i <= 3; i++ {
+		resp, err := app.Test(httptest.NewRequest("POST", "/sessions/abcde/vote", nil))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if i <= 2 && resp.StatusCode != 200 {
+			t.Errorf("Request %d: expected 200, got %d", i, resp.StatusCode)
+		}
+		if i == 3 && resp.StatusCode != 429 {
+			t.Errorf("Request %d: expected 429, got %d", i, resp.StatusCode)
+		}
+		if i == 1 && resp.Header.Get("X-RateLimit-Remaining") != "1" {
+			t.Errorf("Expected X-RateLimit-Remaining 1, got %q", resp.Header.Get("X-RateLimit-Remaining"))
+		}
+	}
+
+	// Other sessions have their own budget
+	resp, err := app.Test(httptest.NewRequest("POST", "/sessions/fghij/vote", nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected 200 for another session, got %d", resp.StatusCode)
+	}
+}
+
+func TestCreateSessionProofOfWork(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.Security.PowDifficulty = 8
+	})
+
+	app := fiber.New()
+	testDB := "test_pow.db"
+	os.Remove(testDB)
+	if err := db.InitDB(testDB); err != nil {
+		t.Fatalf("Failed to init db: %v", err)
+	}
+	defer os.Remove(testDB)
+
+	app.Get("/api/v1/challenges", api.GetChallengeHandler)
+	app.Post("/api/v1/sessions", api.CreateSessionHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/challenges", nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var challenge models.PowChallenge
+	if err := json.NewDecoder(resp.Body).Decode(&challenge); err != nil {
+		t.Fatalf("Failed to decode challenge: %v", err)
+	}
+	if challenge.Difficulty != 8 || challenge.Challenge == "" {
+		t.Fatalf("Unexpected challenge %+v", challenge)
+	}
+
+	// Solve it the same way the frontend does
+	nonce := 0
+	for ; ; nonce++ {
+		sum := sha256.Sum256([]byte(challenge.Challenge + ":" + strconv.Itoa(nonce)))
+		if bits.LeadingZeros8(sum[0]) == 8 {
+			break
+		}
+	}
+
+	create := func(pow bool) int {
+		payload := models.CreateSessionRequest{
+			Title:       "PoW Meeting",
+			CreatorName: "Tester",
+			Timeslots: []models.TimeslotRequest{
+				{StartUTC: "2023-01-01T10:00:00Z", EndUTC: "2023-01-01T11:00:00Z"},
+			},
+		}
+		if pow {
+			payload.PowChallenge = challenge.Challenge
+			payload.PowNonce = strconv.Itoa(nonce)
+		}
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", "/api/v1/sessions", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := create(false); status != 403 {
+		t.Errorf("Expected 403 without proof of work, got %d", status)
+	}
+	if status := create(true); status != 201 {
+		t.Errorf("Expected 201 with proof of work, got %d", status)
+	}
+	if status := create(true); status != 403 {
+		t.Errorf("Expected 403 when reusing a challenge, got %d", status)
+	}
+}
+
+func TestProofOfWorkSpentOnlyOnSuccess(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.Security.PowDifficulty = 8
+	})
+	testDB := "test_pow.db"
+	os.Remove(testDB)
+	if err := db.InitDB(testDB); err != nil {
+		t.Fatalf("Failed to init db: %v", err)
+	}
+	defer os.Remove(testDB)
+	ctx := context.Background()
+
+	solve := func() (string, string) {
+		challenge, err := services.IssuePowChallenge(ctx)
+		if err != nil {
+			t.Fatalf("Failed to issue challenge: %v", err)
+		}
+		for nonce := 0; ; nonce++ {
+			sum := sha256.Sum256([]byte(challenge.Challenge + ":" + strconv.Itoa(nonce)))
+			if bits.LeadingZeros8(sum[0]) == 8 {
+				return challenge.Challenge, strconv.Itoa(nonce)
+			}
+		}
+	}
+	request := func(slug, challenge string) models.CreateSessionRequest {
+		return models.CreateSessionRequest{
+			Title:        "PoW Meeting",
+			CreatorName:  "Tester",
+			Slug:         slug,
+			Timeslots:    []models.TimeslotRequest{{StartUTC: "2023-01-01T10:00:00Z", EndUTC: "2023-01-01T11:00:00Z"}},
+			PowChallenge: challenge,
+		}
+	}
+
+	if _, err := services.CreateSession(ctx, request("team-sync", "")); err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	challenge, nonce := solve()
+	if err := services.VerifyProofOfWork(ctx, challenge, nonce); err != nil {
+		t.Fatalf("Expected a valid proof, got %v", err)
+	}
+	if _, err := services.CreateSession(ctx, request("team-sync", challenge)); err == nil || err.Error() != "slug_taken" {
+		t.Fatalf("Expected slug_taken, got %v", err)
+	}
+	// The failed creation left the challenge unspent
+	if err := services.VerifyProofOfWork(ctx, challenge, nonce); err != nil {
+		t.Errorf("Expected the challenge to be reusable after a failure, got %v", err)
+	}
+	if _, err := services.CreateSession(ctx, request("", challenge)); err != nil {
+		t.Fatalf("Expected the session to be created, got %v", err)
+	}
+	if err := services.VerifyProofOfWork(ctx, challenge, nonce); err == nil || err.Error() != "pow_reused" {
+		t.Errorf("Expected pow_reused, got %v", err)
+	}
+
+	// Raising the difficulty voids challenges issued before
+	challenge, nonce = solve()
+	withConfig(t, func(c *config.Config) {
+		c.Security.PowDifficulty = 12
+	})
+	if err := services.VerifyProofOfWork(ctx, challenge, nonce); err == nil || err.Error() != "invalid_pow" {
+		t.Errorf("Expected invalid_pow after raising the difficulty, got %v", err)
+	}
+}
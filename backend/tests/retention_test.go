@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	_ "modernc.org/sqlite"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	setupMaintenanceDB()
+	defer os.Remove("test_maintenance.db")
+	ctx := context.Background()
+
+	// Fixed "now" so the seeded 2023 timeslots are well in the past
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	inactiveID := createVotedSession(t, "Inactive")
+	pastID := createVotedSession(t, "Past")
+	activeID := createVotedSession(t, "Active")
+
+	old := "2023-01-01T00:00:00Z"
+	db.DB.Exec("UPDATE sessions SET created_at_utc = ? WHERE id = ?", old, inactiveID)
+	db.DB.Exec("UPDATE timeslots SET created_at_utc = ? WHERE session_id = ?", old, inactiveID)
+	db.DB.Exec("UPDATE participants SET created_at_utc = ? WHERE session_id = ?", old, inactiveID)
+	db.DB.Exec("UPDATE votes SET created_at_utc = ? WHERE timeslot_id IN (SELECT id FROM timeslots WHERE session_id = ?)", old, inactiveID)
+	db.DB.Exec("UPDATE timeslots SET start_utc = '2030-01-01T12:00:00Z', end_utc = '2030-01-01T13:00:00Z' WHERE session_id = ?", activeID)
+
+	policy := config.RetentionConfig{InactiveDays: 90, AfterLastSlotDays: 30}
+	report, err := services.ApplyRetention(ctx, policy, now, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	reasons := map[string]string{}
+	for _, s := range report.Sessions {
+		reasons[s.ID] = s.Reason
+	}
+	if len(reasons) != 2 || reasons[inactiveID] != "inactive" || reasons[pastID] != "last_slot_passed" {
+		t.Fatalf("Unexpected dry run report: %+v", report.Sessions)
+	}
+	if _, err := services.GetSession(ctx, inactiveID); err != nil {
+		t.Fatalf("Dry run must not delete: %v", err)
+	}
+
+	// Only the inactivity rule
+	report, err = services.ApplyRetention(ctx, config.RetentionConfig{InactiveDays: 90}, now, true)
+	if err != nil || len(report.Sessions) != 1 || report.Sessions[0].ID != inactiveID {
+		t.Errorf("Expected only the inactive session, got %+v (%v)", report, err)
+	}
+
+	if _, err := services.ApplyRetention(ctx, policy, now, false); err != nil {
+		t.Fatalf("Retention failed: %v", err)
+	}
+	for _, id := range []string{inactiveID, pastID} {
+		if _, err := services.GetSession(ctx, id); err == nil {
+			t.Errorf("Expected session %s to be deleted", id)
+		}
+		var participants int
+		db.DB.QueryRow("SELECT COUNT(*) FROM participants WHERE session_id = ?", id).Scan(&participants)
+		if participants != 0 {
+			t.Errorf("Expected participants of %s to be deleted", id)
+		}
+	}
+	if _, err := services.GetSession(ctx, activeID); err != nil {
+		t.Errorf("Active session should remain: %v", err)
+	}
+	var votes int
+	db.DB.QueryRow("SELECT COUNT(*) FROM votes").Scan(&votes)
+	if votes != 1 {
+		t.Errorf("Expected 1 remaining vote, got %d", votes)
+	}
+}
+
+func TestRetentionCountsCommentsAsActivity(t *testing.T) {
+	setupMaintenanceDB()
+	defer os.Remove("test_maintenance.db")
+	ctx := context.Background()
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	commentedID := createVotedSession(t, "Commented")
+	movedID := createVotedSession(t, "Moved")
+	for _, id := range []string{commentedID, movedID} {
+		old := "2023-01-01T00:00:00Z"
+		db.DB.Exec("UPDATE sessions SET created_at_utc = ? WHERE id = ?", old, id)
+		db.DB.Exec("UPDATE timeslots SET created_at_utc = ? WHERE session_id = ?", old, id)
+		db.DB.Exec("UPDATE participants SET created_at_utc = ? WHERE session_id = ?", old, id)
+		db.DB.Exec("UPDATE votes SET created_at_utc = ? WHERE timeslot_id IN (SELECT id FROM timeslots WHERE session_id = ?)", old, id)
+	}
+	if _, err := services.AddComment(ctx, commentedID, models.CommentRequest{AuthorName: "Guest", Body: "still on?"}); err != nil {
+		t.Fatal(err)
+	}
+	db.DB.Exec("UPDATE comments SET created_at_utc = '2023-01-01T00:00:00Z', updated_at_utc = '2024-05-20T00:00:00Z'")
+	db.DB.Exec("UPDATE timeslots SET updated_at_utc = '2024-05-20T00:00:00Z' WHERE session_id = ?", movedID)
+
+	report, err := services.ApplyRetention(ctx, config.RetentionConfig{InactiveDays: 90}, now, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(report.Sessions) != 0 {
+		t.Errorf("Expected recent comments and timeslot edits to count as activity, got %+v", report.Sessions)
+	}
+}
+
+func TestRetentionPrunesAuthFailures(t *testing.T) {
+	setupMaintenanceDB()
+	defer os.Remove("test_maintenance.db")
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	db.DB.Exec("INSERT INTO auth_failures (scope, key, failures, last_failure_utc, locked_until_utc) VALUES ('ip', 'old', 9, '2024-05-01T00:00:00Z', '2024-05-01T01:00:00Z')")
+	db.DB.Exec("INSERT INTO auth_failures (scope, key, failures, last_failure_utc, locked_until_utc) VALUES ('ip', 'recent', 9, '2024-05-31T23:00:00Z', '2024-06-01T00:30:00Z')")
+
+	report, err := services.ApplyRetention(context.Background(), config.RetentionConfig{InactiveDays: 90}, now, false)
+	if err != nil {
+		t.Fatalf("Retention failed: %v", err)
+	}
+	if report.PrunedAuthFailures != 1 {
+		t.Errorf("Expected 1 pruned counter, got %d", report.PrunedAuthFailures)
+	}
+	var keys []string
+	rows, _ := db.DB.Query("SELECT key FROM auth_failures")
+	for rows.Next() {
+		var k string
+		rows.Scan(&k)
+		keys = append(keys, k)
+	}
+	rows.Close()
+	if len(keys) != 1 || keys[0] != "recent" {
+		t.Errorf("Expected only the recent counter to remain, got %v", keys)
+	}
+}
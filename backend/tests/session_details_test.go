@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/utils"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupSessionDetailsApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_session_details.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions", api.CreateSessionHandler)
+	apiGroup.Get("/sessions/:id", api.GetSessionHandler)
+	app.Get("/:id", api.ServeSessionPage)
+
+	return app
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"**Agenda**\n\n- budget\n- *hiring*", "<p><strong>Agenda</strong></p><ul>"},
+		{"- budget\n- *hiring*", "<ul><li>budget</li><li><em>hiring</em></li></ul>"},
+		{"<img src=x onerror=alert(1)>", "<p>&lt;img src=x onerror=alert(1)&gt;</p>"},
+		{"[notes](https://example.com/a_b_?x=1&y=2)", `<p><a href="https://example.com/a_b_?x=1&amp;y=2" target="_blank" rel="nofollow noopener noreferrer">notes</a></p>`},
+		{"[click](javascript:alert(1))", "<p>[click](javascript:alert(1))</p>"},
+		{"`**raw**`", "<p><code>**raw**</code></p>"},
+	} {
+		if got := utils.RenderMarkdown(tc.in); !strings.Contains(got, tc.want) {
+			t.Errorf("RenderMarkdown(%q) = %q, want it to contain %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSessionDetails(t *testing.T) {
+	app := setupSessionDetailsApp()
+	defer os.Remove("test_session_details.db")
+
+	page := `<title>BiaMeet | بیا میت</title><meta property="og:description" content="زمان‌بندی ساده جلسات. بدون نیاز به ثبت‌نام.">`
+	if err := os.WriteFile("index.html", []byte(page), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("index.html")
+
+	create := func(details models.SessionDetails) (int, map[string]string) {
+		body, _ := json.Marshal(models.CreateSessionRequest{
+			Title:          `Planning "Q3" <b>`,
+			CreatorName:    "Tester",
+			SessionDetails: details,
+			Timeslots: []models.TimeslotRequest{
+				{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+			},
+		})
+		req := httptest.NewRequest("POST", "/api/v1/sessions", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out map[string]string
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	for _, tc := range []struct {
+		details models.SessionDetails
+		err     string
+	}{
+		{models.SessionDetails{MeetingURL: "javascript:alert(1)"}, "invalid_meeting_url"},
+		{models.SessionDetails{MeetingURL: "meet.example.com"}, "invalid_meeting_url"},
+		{models.SessionDetails{DurationMinutes: -5}, "invalid_duration"},
+		{models.SessionDetails{Location: strings.Repeat("x", models.MaxLocationLength+1)}, "location_too_long"},
+		{models.SessionDetails{Description: strings.Repeat("x", models.MaxDescriptionLength+1)}, "description_too_long"},
+	} {
+		if status, out := create(tc.details); status != 400 || out["error"] != tc.err {
+			t.Errorf("Expected 400 %s for %+v, got %d %v", tc.err, tc.details, status, out)
+		}
+	}
+
+	status, out := create(models.SessionDetails{
+		Description:     "  Review the **roadmap**  ",
+		Location:        "Room 2",
+		MeetingURL:      "https://meet.example.com/abc",
+		DurationMinutes: 45,
+	})
+	if status != 201 {
+		t.Fatalf("Expected 201, got %d %v", status, out)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/sessions/"+out["id"], nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var session models.Session
+	json.NewDecoder(resp.Body).Decode(&session)
+	if session.Description != "Review the **roadmap**" || session.Location != "Room 2" ||
+		session.MeetingURL != "https://meet.example.com/abc" || session.DurationMinutes != 45 {
+		t.Errorf("Unexpected session details %+v", session.SessionDetails)
+	}
+	if session.DescriptionHTML != "<p>Review the <strong>roadmap</strong></p>" {
+		t.Errorf("Unexpected description HTML %q", session.DescriptionHTML)
+	}
+
+	// Link previews carry the location and description, escaped
+	resp, err = app.Test(httptest.NewRequest("GET", "/"+out["id"], nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	html, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(html), "Room 2 — Review the roadmap") {
+		t.Errorf("Expected the details in the meta description, got %s", html)
+	}
+	if strings.Contains(string(html), "<b>") || !strings.Contains(string(html), "Planning &#34;Q3&#34; &lt;b&gt;") {
+		t.Errorf("Expected the title to be escaped, got %s", html)
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/utils"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupSessionIDApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_session_id.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions", api.CreateSessionHandler)
+
+	return app
+}
+
+func TestGenerateShortID(t *testing.T) {
+	valid := regexp.MustCompile(`^[a-zA-Z0-9]{12}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id, err := utils.GenerateShortID(12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !valid.MatchString(id) {
+			t.Fatalf("Unexpected ID %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("Duplicate ID %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestCreateSessionSlug(t *testing.T) {
+	withConfig(t, func(c *config.Config) {
+		c.Security.SessionIDLength = 8
+	})
+	app := setupSessionIDApp()
+	defer os.Remove("test_session_id.db")
+
+	create := func(slug string) (int, map[string]string) {
+		body, _ := json.Marshal(models.CreateSessionRequest{
+			Title:       "Slug Test",
+			CreatorName: "Tester",
+			Slug:        slug,
+			Timeslots: []models.TimeslotRequest{
+				{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+			},
+		})
+		req := httptest.NewRequest("POST", "/api/v1/sessions", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out map[string]string
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, out := create("")
+	if status != 201 || len(out["id"]) != 8 {
+		t.Errorf("Expected a random 8 character ID, got %d %v", status, out)
+	}
+
+	status, out = create("team-sync")
+	if status != 201 || out["id"] != "team-sync" {
+		t.Fatalf("Expected the slug as ID, got %d %v", status, out)
+	}
+
+	for _, tc := range []struct {
+		slug   string
+		status int
+		err    string
+	}{
+		{"team-sync", 409, "slug_taken"},
+		{"admin", 400, "slug_reserved"},
+		{"Team-Sync", 400, "invalid_slug"},
+		{"-team", 400, "invalid_slug"},
+		{"abc", 400, "invalid_slug"},
+		{"team/sync", 400, "invalid_slug"},
+	} {
+		status, out := create(tc.slug)
+		if status != tc.status || out["error"] != tc.err {
+			t.Errorf("Slug %q: expected %d %s, got %d %v", tc.slug, tc.status, tc.err, status, out)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupSlotRulesApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_slot_rules.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions", api.CreateSessionHandler)
+	apiGroup.Post("/sessions/:id/timeslots", api.AddTimeslotHandler)
+
+	return app
+}
+
+func TestSlotRules(t *testing.T) {
+	app := setupSlotRulesApp()
+	defer os.Remove("test_slot_rules.db")
+	ctx := context.Background()
+
+	post := func(path string, body interface{}) (int, map[string]interface{}) {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/v1"+path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	create := func(cfg models.DynamicConfig) (int, map[string]interface{}) {
+		cfg.DateUTC, cfg.MinTime, cfg.MaxTime = "2023-01-01T00:00:00Z", "08:00", "18:00"
+		return post("/sessions", models.CreateSessionRequest{
+			Title:         "Rules Test",
+			CreatorName:   "Tester",
+			Type:          "dynamic",
+			DynamicConfig: &cfg,
+		})
+	}
+
+	for _, tc := range []struct {
+		cfg models.DynamicConfig
+		err string
+	}{
+		{models.DynamicConfig{SlotMinutes: 30, MinSlotMinutes: 15}, "invalid_slot_rules"},
+		{models.DynamicConfig{MinSlotMinutes: 90, MaxSlotMinutes: 30}, "invalid_slot_rules"},
+		{models.DynamicConfig{GranularityMinutes: 7}, "invalid_slot_rules"},
+		{models.DynamicConfig{BufferMinutes: -10}, "invalid_slot_rules"},
+		{models.DynamicConfig{GranularityMinutes: 15, Timezone: "Mars/Olympus"}, "invalid_timezone"},
+	} {
+		if status, out := create(tc.cfg); status != 400 || out["error"] != tc.err {
+			t.Errorf("Expected 400 %s for %+v, got %d %v", tc.err, tc.cfg, status, out)
+		}
+	}
+
+	if status, out := post("/sessions", models.CreateSessionRequest{
+		Title:         "Rules Test",
+		CreatorName:   "Tester",
+		Type:          "dynamic",
+		DynamicConfig: &models.DynamicConfig{DateUTC: "2023-01-01T00:00:00Z", MinTime: "18:00", MaxTime: "08:00"},
+	}); status != 400 || out["error"] != "invalid_slot_rules" {
+		t.Errorf("Expected 400 invalid_slot_rules for a reversed window, got %d %v", status, out)
+	}
+
+	status, out := create(models.DynamicConfig{MinSlotMinutes: 30, MaxSlotMinutes: 90, GranularityMinutes: 15, BufferMinutes: 15})
+	if status != 201 {
+		t.Fatalf("Expected 201, got %d %v", status, out)
+	}
+	id := out["id"].(string)
+	ownerToken := out["owner_token"].(string)
+
+	// Clients read the rules from the session
+	session, err := services.GetSession(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if cfg := session.DynamicConfig; cfg.GranularityMinutes != 15 || cfg.BufferMinutes != 15 || cfg.MaxSlotMinutes != 90 {
+		t.Errorf("Expected the slot rules in the session, got %+v", cfg)
+	}
+
+	// Times of day are in Tehran, UTC+3:30
+	for _, tc := range []struct {
+		name       string
+		start, end string
+		status     int
+		err        string
+	}{
+		{"allowed", "2023-01-01T05:30:00Z", "2023-01-01T06:30:00Z", 201, ""},
+		{"too short", "2023-01-01T10:30:00Z", "2023-01-01T10:50:00Z", 400, "slot_duration_not_allowed"},
+		{"too long", "2023-01-01T10:30:00Z", "2023-01-01T12:30:00Z", 400, "slot_duration_not_allowed"},
+		{"end before start", "2023-01-01T10:30:00Z", "2023-01-01T10:00:00Z", 400, "invalid_timeslot"},
+		{"off the grid", "2023-01-01T06:50:00Z", "2023-01-01T07:50:00Z", 400, "slot_start_not_aligned"},
+		{"inside the buffer", "2023-01-01T06:30:00Z", "2023-01-01T07:15:00Z", 409, "slot_too_close"},
+		{"overlapping", "2023-01-01T05:00:00Z", "2023-01-01T06:00:00Z", 409, "slot_too_close"},
+		{"right after the buffer", "2023-01-01T06:45:00Z", "2023-01-01T07:30:00Z", 201, ""},
+		{"browser timestamps", "2023-01-01T08:00:00.000Z", "2023-01-01T09:00:00.000Z", 201, ""},
+		{"before the window", "2023-01-01T04:00:00Z", "2023-01-01T05:00:00Z", 400, "slot_outside_window"},
+		{"past the window", "2023-01-01T14:00:00Z", "2023-01-01T15:00:00Z", 400, "slot_outside_window"},
+		{"past midnight", "2023-01-01T20:00:00Z", "2023-01-01T21:00:00Z", 400, "slot_outside_window"},
+	} {
+		status, out := post("/sessions/"+id+"/timeslots", models.TimeslotRequest{StartUTC: tc.start, EndUTC: tc.end})
+		if status != tc.status || (tc.err != "" && out["error"] != tc.err) {
+			t.Errorf("%s: expected %d %s, got %d %v", tc.name, tc.status, tc.err, status, out)
+		}
+	}
+
+	// Moving a timeslot follows the same rules, ignoring its old place
+	session, _ = services.GetSession(ctx, id)
+	moving := findTimeslotAt(session, "2023-01-01T05:30:00Z")
+	if moving == nil {
+		t.Fatalf("Expected the first timeslot, got %+v", session.Timeslots)
+	}
+	_, err = services.UpdateTimeslot(ctx, id, moving.ID, models.UpdateTimeslotRequest{
+		StartUTC: "2023-01-01T05:35:00Z", EndUTC: "2023-01-01T06:35:00Z", OwnerToken: ownerToken,
+	})
+	if err == nil || err.Error() != "slot_start_not_aligned" {
+		t.Errorf("Expected slot_start_not_aligned when moving off the grid, got %v", err)
+	}
+	_, err = services.UpdateTimeslot(ctx, id, moving.ID, models.UpdateTimeslotRequest{
+		StartUTC: "2023-01-01T05:15:00Z", EndUTC: "2023-01-01T06:15:00Z", OwnerToken: ownerToken,
+	})
+	if err != nil {
+		t.Errorf("Expected the slot to move next to its old place, got %v", err)
+	}
+
+	// A fixed length allows nothing else
+	status, out = create(models.DynamicConfig{SlotMinutes: 30})
+	if status != 201 {
+		t.Fatalf("Expected 201, got %d %v", status, out)
+	}
+	fixedID := out["id"].(string)
+	if status, out := post("/sessions/"+fixedID+"/timeslots", models.TimeslotRequest{StartUTC: "2023-01-01T06:00:00Z", EndUTC: "2023-01-01T06:45:00Z"}); status != 400 {
+		t.Errorf("Expected 400 for a 45 minute slot, got %d %v", status, out)
+	}
+	if status, out := post("/sessions/"+fixedID+"/timeslots", models.TimeslotRequest{StartUTC: "2023-01-01T06:00:00Z", EndUTC: "2023-01-01T06:30:00Z"}); status != 201 {
+		t.Errorf("Expected 201 for a 30 minute slot, got %d %v", status, out)
+	}
+	if status, out := post("/sessions/"+fixedID+"/timeslots", models.TimeslotRequest{StartUTC: "2023-01-01T06:00:00Z", EndUTC: "2023-01-01T06:30:00Z"}); status != 409 || out["error"] != "timeslot_exists" {
+		t.Errorf("Expected 409 timeslot_exists for the same slot again, got %d %v", status, out)
+	}
+}
+
+func findTimeslotAt(session *models.Session, startUTC string) *models.Timeslot {
+	for i := range session.Timeslots {
+		if session.Timeslots[i].StartUTC == startUTC {
+			return &session.Timeslots[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupTimeslotPatternApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_timeslot_pattern.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions", api.CreateSessionHandler)
+	apiGroup.Post("/timeslots/preview", api.PreviewTimeslotsHandler)
+
+	return app
+}
+
+func TestTimeslotPattern(t *testing.T) {
+	app := setupTimeslotPatternApp()
+	defer os.Remove("test_timeslot_pattern.db")
+
+	post := func(path string, body interface{}) (int, []byte) {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/v1"+path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.Bytes()
+	}
+
+	// Saturday to Wednesday around Nowruz 1404, two slots a day
+	pattern := models.TimeslotPattern{
+		StartDate:    "2025-03-15",
+		EndDate:      "2025-03-24",
+		Weekdays:     []int{6, 0, 1, 2, 3},
+		DayStart:     "09:00",
+		DayEnd:       "12:00",
+		SlotMinutes:  60,
+		GapMinutes:   30,
+		SkipHolidays: true,
+	}
+
+	status, body := post("/timeslots/preview", pattern)
+	if status != 200 {
+		t.Fatalf("Expected 200, got %d %s", status, body)
+	}
+	var preview models.TimeslotPreview
+	json.Unmarshal(body, &preview)
+	if len(preview.Timeslots) != 8 {
+		t.Fatalf("Expected 8 timeslots, got %d: %+v", len(preview.Timeslots), preview.Timeslots)
+	}
+	if first := preview.Timeslots[0]; first.StartUTC != "2025-03-15T05:30:00Z" || first.EndUTC != "2025-03-15T06:30:00Z" {
+		t.Errorf("Expected the first slot at 09:00 Tehran time, got %+v", first)
+	}
+	if second := preview.Timeslots[1]; second.StartUTC != "2025-03-15T07:00:00Z" {
+		t.Errorf("Expected the second slot after the gap, got %+v", second)
+	}
+	var skipped []string
+	for _, h := range preview.SkippedHolidays {
+		skipped = append(skipped, h.Date)
+	}
+	if len(skipped) != 4 || skipped[0] != "2025-03-19" || skipped[1] != "2025-03-22" {
+		t.Errorf("Expected 29 Esfand and Nowruz to be skipped, got %v", skipped)
+	}
+
+	// Holidays are only skipped on request
+	keepHolidays := pattern
+	keepHolidays.SkipHolidays = false
+	preview2, err := services.GenerateTimeslots(context.Background(), keepHolidays)
+	if err != nil || len(preview2.Timeslots) != 16 {
+		t.Errorf("Expected 16 timeslots with holidays, got %v %v", preview2, err)
+	}
+
+	// Ashura 1447 follows the lunar calendar
+	ashura := models.TimeslotPattern{StartDate: "2025-07-05", EndDate: "2025-07-07", DayStart: "10:00", DayEnd: "11:00", SlotMinutes: 60, SkipHolidays: true}
+	preview3, err := services.GenerateTimeslots(context.Background(), ashura)
+	if err != nil || len(preview3.Timeslots) != 1 || len(preview3.SkippedHolidays) != 2 || preview3.SkippedHolidays[1].Name != "عاشورا" {
+		t.Errorf("Expected Tasua and Ashura to be skipped, got %+v %v", preview3, err)
+	}
+
+	for _, tc := range []struct {
+		mutate func(*models.TimeslotPattern)
+		err    string
+	}{
+		{func(p *models.TimeslotPattern) { p.EndDate = "2025-03-01" }, "invalid_date_range"},
+		{func(p *models.TimeslotPattern) { p.EndDate = "2026-06-01" }, "invalid_date_range"},
+		{func(p *models.TimeslotPattern) { p.Weekdays = []int{7} }, "invalid_weekday"},
+		{func(p *models.TimeslotPattern) { p.DayEnd = "08:00" }, "invalid_daily_window"},
+		{func(p *models.TimeslotPattern) { p.SlotMinutes = 240 }, "invalid_slot_duration"},
+		{func(p *models.TimeslotPattern) { p.SlotMinutes = 1 }, "invalid_slot_duration"},
+		{func(p *models.TimeslotPattern) { p.GapMinutes = -5 }, "invalid_gap"},
+		{func(p *models.TimeslotPattern) { p.Timezone = "Mars/Olympus" }, "invalid_timezone"},
+		{func(p *models.TimeslotPattern) { p.Weekdays = []int{5} }, "no_timeslots"},
+		{func(p *models.TimeslotPattern) {
+			p.EndDate, p.Weekdays, p.DayStart, p.DayEnd, p.SlotMinutes = "2025-12-31", nil, "00:00", "23:00", 30
+		}, "too_many_timeslots"},
+	} {
+		bad := pattern
+		tc.mutate(&bad)
+		if status, body := post("/timeslots/preview", bad); status != 400 || !bytes.Contains(body, []byte(tc.err)) {
+			t.Errorf("Expected 400 %s, got %d %s", tc.err, status, body)
+		}
+	}
+
+	// Creating a session with the pattern saves the same timeslots
+	status, body = post("/sessions", models.CreateSessionRequest{
+		Title:           "Pattern Test",
+		CreatorName:     "Tester",
+		Type:            "fixed",
+		TimeslotPattern: &pattern,
+	})
+	if status != 201 {
+		t.Fatalf("Expected 201, got %d %s", status, body)
+	}
+	var created models.CreateSessionResponse
+	json.Unmarshal(body, &created)
+	session, err := services.GetSession(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if len(session.Timeslots) != 8 {
+		t.Errorf("Expected 8 saved timeslots, got %d", len(session.Timeslots))
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupTimeslotUpdateApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_timeslot_update.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Put("/sessions/:id/timeslots/:ts_id", api.UpdateTimeslotHandler)
+
+	return app
+}
+
+func TestUpdateTimeslot(t *testing.T) {
+	app := setupTimeslotUpdateApp()
+	defer os.Remove("test_timeslot_update.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:         "Update Test",
+		CreatorName:   "Tester",
+		Type:          "dynamic",
+		DynamicConfig: &models.DynamicConfig{DateUTC: "2023-01-01T00:00:00Z", MinTime: "08:00", MaxTime: "18:00"},
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T08:00:00Z", EndUTC: "2023-01-01T09:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	session, _ := services.GetSession(ctx, created.ID)
+	ownerSlot := session.Timeslots[0].ID
+
+	// Ali proposes a slot, which also votes for it; Sara votes for it too
+	ts, err := services.AddTimeslot(ctx, created.ID, models.TimeslotRequest{
+		StartUTC: "2023-01-01T10:00:00Z", EndUTC: "2023-01-01T11:00:00Z", CreatedBy: "Ali", Password: "1234",
+	})
+	if err != nil {
+		t.Fatalf("Failed to add timeslot: %v", err)
+	}
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Sara", Password: "5678", Votes: []models.VoteItem{{TimeslotID: ts.ID}}}); err != nil {
+		t.Fatalf("Vote failed: %v", err)
+	}
+
+	put := func(tsID string, req models.UpdateTimeslotRequest, ownerToken string) (int, map[string]interface{}) {
+		body, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest("PUT", "/api/v1/sessions/"+created.ID+"/timeslots/"+tsID, bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		if ownerToken != "" {
+			httpReq.Header.Set("X-Owner-Token", ownerToken)
+		}
+		resp, err := app.Test(httpReq)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	// Inside the 08:00 to 18:00 window, Tehran time
+	move := models.UpdateTimeslotRequest{StartUTC: "2023-01-01T13:00:00Z", EndUTC: "2023-01-01T14:00:00Z"}
+
+	for _, tc := range []struct {
+		name   string
+		tsID   string
+		req    models.UpdateTimeslotRequest
+		owner  string
+		status int
+		err    string
+	}{
+		{"no password", ts.ID, move, "", 401, "password_required"},
+		{"wrong password", ts.ID, withPassword(move, "nope"), "", 401, "invalid_password"},
+		{"wrong owner token", ts.ID, move, "nope", 401, "invalid_owner_token"},
+		{"unprotected timeslot", ownerSlot, move, "", 403, "timeslot_not_protected"},
+		{"end before start", ts.ID, models.UpdateTimeslotRequest{StartUTC: move.EndUTC, EndUTC: move.StartUTC, Password: "1234"}, "", 400, "invalid_timeslot"},
+		{"clash with another slot", ts.ID, models.UpdateTimeslotRequest{StartUTC: "2023-01-01T08:00:00Z", EndUTC: "2023-01-01T09:00:00Z", Password: "1234"}, "", 409, "timeslot_exists"},
+		{"unknown timeslot", "missing", move, created.OwnerToken, 404, "timeslot not found"},
+	} {
+		if status, out := put(tc.tsID, tc.req, tc.owner); status != tc.status || out["error"] != tc.err {
+			t.Errorf("%s: expected %d %s, got %d %v", tc.name, tc.status, tc.err, status, out)
+		}
+	}
+
+	// The proposer moves the slot and the votes stay
+	status, out := put(ts.ID, withPassword(move, "1234"), "")
+	if status != 200 || out["votes_kept"] != float64(2) || out["start_utc"] != move.StartUTC {
+		t.Fatalf("Expected the move to keep 2 votes, got %d %v", status, out)
+	}
+	sara, err := services.GetSessionForViewer(ctx, created.ID, models.SessionViewer{Name: "Sara"})
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	moved := findTimeslot(sara, ts.ID)
+	if moved == nil || moved.StartUTC != move.StartUTC || moved.UpdatedAtUTC == "" || len(moved.Votes) != 2 {
+		t.Fatalf("Expected the moved slot with its votes, got %+v", moved)
+	}
+	if sara.TimeslotsChangedAtUTC == "" {
+		t.Errorf("Expected Sara to be told the slot moved")
+	}
+
+	// Voting again confirms the new time
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Sara", Password: "5678", Votes: []models.VoteItem{{TimeslotID: ts.ID}}}); err != nil {
+		t.Fatalf("Vote failed: %v", err)
+	}
+	sara, _ = services.GetSessionForViewer(ctx, created.ID, models.SessionViewer{Name: "Sara"})
+	if sara.TimeslotsChangedAtUTC != "" {
+		t.Errorf("Expected the notice to clear after voting again, got %q", sara.TimeslotsChangedAtUTC)
+	}
+
+	// The owner moves it again and drops the votes
+	status, out = put(ts.ID, models.UpdateTimeslotRequest{StartUTC: "2023-01-01T11:30:00Z", EndUTC: "2023-01-01T12:30:00Z", InvalidateVotes: true}, created.OwnerToken)
+	if status != 200 || out["votes_invalidated"] != float64(2) {
+		t.Fatalf("Expected the move to invalidate 2 votes, got %d %v", status, out)
+	}
+	ali, _ := services.GetSessionForViewer(ctx, created.ID, models.SessionViewer{Name: "Ali"})
+	if moved := findTimeslot(ali, ts.ID); moved == nil || len(moved.Votes) != 0 {
+		t.Errorf("Expected no votes left on the slot, got %+v", moved)
+	}
+	if ali.TimeslotsChangedAtUTC == "" {
+		t.Errorf("Expected Ali to be told the slot moved")
+	}
+	history, err := services.GetVoteHistory(ctx, created.ID, models.ParticipantCredentials{VoterName: "Ali", Password: "1234"})
+	if err != nil {
+		t.Fatalf("Failed to get vote history: %v", err)
+	}
+	if latest := history.Versions[0]; latest.Cause != models.VersionCauseTimeslotChanged || len(latest.Votes) != 0 {
+		t.Errorf("Expected a timeslot_changed version without votes, got %+v", latest)
+	}
+
+	events, err := services.GetSessionHistory(ctx, created.ID, models.SessionHistoryQuery{Type: services.EventTimeslotUpdated})
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if events.Total != 2 {
+		t.Errorf("Expected 2 timeslot_updated events, got %d", events.Total)
+	}
+}
+
+func withPassword(req models.UpdateTimeslotRequest, password string) models.UpdateTimeslotRequest {
+	req.Password = password
+	return req
+}
+
+func findTimeslot(session *models.Session, id string) *models.Timeslot {
+	for i := range session.Timeslots {
+		if session.Timeslots[i].ID == id {
+			return &session.Timeslots[i]
+		}
+	}
+	return nil
+}
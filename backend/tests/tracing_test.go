@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"biameet.ir/tracing"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	_ "modernc.org/sqlite"
+)
+
+func TestRequestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	testDB := "test_tracing.db"
+	os.Remove(testDB)
+	if err := db.InitDB(testDB); err != nil {
+		t.Fatalf("Failed to init db: %v", err)
+	}
+	defer os.Remove(testDB)
+
+	app := fiber.New()
+	app.Use(tracing.Middleware())
+	app.Get("/api/v1/sessions/:id", api.GetSessionHandler)
+
+	created, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:       "Tracing Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+
+	httpReq := httptest.NewRequest("GET", "/api/v1/sessions/"+created.ID, nil)
+	httpReq.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if _, err := app.Test(httpReq); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	var server, service sdktrace.ReadOnlySpan
+	sqlSpans := 0
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "GET /api/v1/sessions/:id":
+			server = span
+		case "services.get_session":
+			service = span
+		}
+		for _, attr := range span.Attributes() {
+			if attr.Key == "db.statement" && span.Parent().TraceID().String() == "4bf92f3577b34da6a3ce929d0e0e4736" {
+				sqlSpans++
+			}
+		}
+	}
+
+	if server == nil || service == nil {
+		t.Fatalf("Expected server and service spans, got %d spans", len(recorder.Ended()))
+	}
+	if server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected server span to continue the incoming trace, parent %s", server.Parent().SpanID())
+	}
+	if service.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("Expected service span to be a child of the server span")
+	}
+	if sqlSpans < 3 {
+		t.Errorf("Expected SQL statement spans for the session, timeslot and vote queries, got %d", sqlSpans)
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/config"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupVisibilityApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_visibility.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Get("/sessions/:id", api.GetSessionHandler)
+
+	return app
+}
+
+func TestResultsVisibility(t *testing.T) {
+	app := setupVisibilityApp()
+	defer os.Remove("test_visibility.db")
+	ctx := context.Background()
+
+	type seeded struct {
+		id, ownerToken, aliToken, saraToken string
+	}
+	seed := func(visibility string) seeded {
+		created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+			Title:             "Visibility " + visibility,
+			CreatorName:       "Tester",
+			ResultsVisibility: visibility,
+			Timeslots: []models.TimeslotRequest{
+				{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to seed session: %v", err)
+		}
+		session, _ := services.GetSession(ctx, created.ID)
+		votes := []models.VoteItem{{TimeslotID: session.Timeslots[0].ID}}
+		ali, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Ali", Votes: votes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sara, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Sara", Votes: []models.VoteItem{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return seeded{created.ID, created.OwnerToken, ali.EditToken, sara.EditToken}
+	}
+
+	get := func(id string, headers map[string]string) models.Session {
+		req := httptest.NewRequest("GET", "/api/v1/sessions/"+id, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		if err != nil || resp.StatusCode != 200 {
+			t.Fatalf("Request failed: %v %v", resp, err)
+		}
+		var s models.Session
+		json.NewDecoder(resp.Body).Decode(&s)
+		return s
+	}
+	check := func(name string, s models.Session, wantVotes int, wantCount bool) {
+		t.Helper()
+		ts := s.Timeslots[0]
+		if len(ts.Votes) != wantVotes {
+			t.Errorf("%s: expected %d visible votes, got %d", name, wantVotes, len(ts.Votes))
+		}
+		if (ts.VoteCount != nil) != wantCount {
+			t.Errorf("%s: expected count visible=%v, got %v", name, wantCount, ts.VoteCount)
+		}
+		if wantCount && *ts.VoteCount != 1 {
+			t.Errorf("%s: expected count 1, got %d", name, *ts.VoteCount)
+		}
+	}
+
+	public := seed(models.VisibilityPublic)
+	check("public stranger", get(public.id, nil), 1, true)
+
+	anon := seed(models.VisibilityAnonymous)
+	s := get(anon.id, nil)
+	check("anonymous stranger", s, 0, true)
+	if !s.ResultsHidden {
+		t.Error("Expected results_hidden for anonymous session")
+	}
+	check("anonymous owner", get(anon.id, map[string]string{"X-Owner-Token": anon.ownerToken}), 0, true)
+	check("anonymous voter", get(anon.id, map[string]string{"X-Edit-Token": anon.aliToken}), 1, true)
+
+	after := seed(models.VisibilityAfterVote)
+	check("after_vote stranger", get(after.id, nil), 0, false)
+	check("after_vote forged token", get(after.id, map[string]string{"X-Edit-Token": after.aliToken + "x"}), 0, false)
+	check("after_vote voter", get(after.id, map[string]string{"X-Edit-Token": after.aliToken}), 1, true)
+	check("after_vote non-voter", get(after.id, map[string]string{"X-Edit-Token": after.saraToken}), 0, false)
+
+	owner := seed(models.VisibilityOwnerOnly)
+	check("owner_only voter", get(owner.id, map[string]string{"X-Edit-Token": owner.aliToken}), 1, false)
+	check("owner_only owner", get(owner.id, map[string]string{"X-Owner-Token": owner.ownerToken}), 1, true)
+	check("owner_only other token", get(owner.id, map[string]string{"X-Owner-Token": anon.ownerToken}), 0, false)
+}
+
+func TestAfterVoteNeedsEditLinks(t *testing.T) {
+	setupVisibilityApp()
+	defer os.Remove("test_visibility.db")
+
+	// Without edit links a voter could never prove they voted
+	withConfig(t, func(c *config.Config) {
+		c.Features.EditLinks = false
+	})
+	_, err := services.CreateSession(context.Background(), models.CreateSessionRequest{
+		Title:             "After Vote Test",
+		CreatorName:       "Tester",
+		ResultsVisibility: models.VisibilityAfterVote,
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err == nil || err.Error() != "after_vote_requires_edit_links" {
+		t.Errorf("Expected after_vote_requires_edit_links, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http/httptest"
 	"os"
@@ -42,7 +43,7 @@ func TestVote(t *testing.T) {
 			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
 		},
 	}
-	created, err := services.CreateSession(req)
+	created, err := services.CreateSession(context.Background(), req)
 	if err != nil {
 		t.Fatalf("Failed to seed session: %v", err)
 	}
@@ -50,7 +51,7 @@ func TestVote(t *testing.T) {
 	// We need to get the timeslot ID.
 	// Since CreateSession doesn't return timeslot IDs in response (only session ID),
 	// we need to fetch the session to get timeslots.
-	session, err := services.GetSession(created.ID)
+	session, err := services.GetSession(context.Background(), created.ID)
 	if err != nil {
 		t.Fatalf("Failed to get session: %v", err)
 	}
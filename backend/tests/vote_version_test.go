@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"biameet.ir/api"
+	"biameet.ir/db"
+	"biameet.ir/models"
+	"biameet.ir/services"
+	"github.com/gofiber/fiber/v2"
+	_ "modernc.org/sqlite"
+)
+
+func setupVoteVersionApp() *fiber.App {
+	app := fiber.New()
+	testDB := "test_vote_version.db"
+	os.Remove(testDB)
+
+	if err := db.InitDB(testDB); err != nil {
+		panic(err)
+	}
+
+	apiGroup := app.Group("/api/v1")
+	apiGroup.Post("/sessions/:id/votes/history", api.VoteHistoryHandler)
+	apiGroup.Post("/sessions/:id/votes/revert", api.RevertVotesHandler)
+
+	return app
+}
+
+func TestVoteHistoryAndRevert(t *testing.T) {
+	app := setupVoteVersionApp()
+	defer os.Remove("test_vote_version.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Versions Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+			{StartUTC: "2023-01-02T12:00:00Z", EndUTC: "2023-01-02T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	session, _ := services.GetSession(ctx, created.ID)
+	first, second := session.Timeslots[0].ID, session.Timeslots[1].ID
+
+	vote := func(votes []models.VoteItem) {
+		_, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Ali", Password: "1234", Votes: votes})
+		if err != nil {
+			t.Fatalf("Vote failed: %v", err)
+		}
+	}
+	vote([]models.VoteItem{{TimeslotID: first, Note: "maybe"}, {TimeslotID: second}})
+	vote([]models.VoteItem{{TimeslotID: second}})
+
+	post := func(path string, body interface{}, ownerToken string) (int, []byte) {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/v1/sessions/"+created.ID+"/votes/"+path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		if ownerToken != "" {
+			req.Header.Set("X-Owner-Token", ownerToken)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.Bytes()
+	}
+	history := func(creds models.ParticipantCredentials, ownerToken string) models.VoteHistory {
+		status, body := post("history", creds, ownerToken)
+		if status != 200 {
+			t.Fatalf("Expected 200 for history, got %d %s", status, body)
+		}
+		var out models.VoteHistory
+		json.Unmarshal(body, &out)
+		return out
+	}
+
+	if status, _ := post("history", models.ParticipantCredentials{VoterName: "Ali", Password: "wrong"}, ""); status != 401 {
+		t.Errorf("Expected 401 with a wrong password, got %d", status)
+	}
+
+	h := history(models.ParticipantCredentials{VoterName: "Ali", Password: "1234"}, "")
+	if len(h.Versions) != 2 || h.Versions[0].Version != 2 || len(h.Versions[1].Votes) != 2 {
+		t.Fatalf("Expected two versions, newest first, got %+v", h)
+	}
+
+	// Going back to version 1 brings back the first slot and its note
+	status, body := post("revert", models.RevertVotesRequest{
+		ParticipantCredentials: models.ParticipantCredentials{VoterName: "Ali", Password: "1234"},
+		Version:                1,
+	}, "")
+	var reverted models.RevertVotesResponse
+	json.Unmarshal(body, &reverted)
+	if status != 200 || reverted.Version != 3 || reverted.Restored != 2 {
+		t.Fatalf("Unexpected revert result %d %s", status, body)
+	}
+	session, _ = services.GetSession(ctx, created.ID)
+	for _, ts := range session.Timeslots {
+		if len(ts.Votes) != 1 || ts.Votes[0].VoterName != "Ali" {
+			t.Errorf("Expected Ali's vote on %s after the revert, got %+v", ts.ID, ts.Votes)
+		}
+		if ts.ID == first && ts.Votes[0].Note != "maybe" {
+			t.Errorf("Expected the note to come back, got %q", ts.Votes[0].Note)
+		}
+	}
+
+	// The owner can read anyone's history, versions they do not have 404
+	h = history(models.ParticipantCredentials{VoterName: "Ali"}, created.OwnerToken)
+	if len(h.Versions) != 3 || h.Versions[0].Cause != models.VersionCauseRevert {
+		t.Errorf("Expected the revert as newest version, got %+v", h.Versions)
+	}
+	status, _ = post("revert", models.RevertVotesRequest{
+		ParticipantCredentials: models.ParticipantCredentials{VoterName: "Ali"},
+		Version:                9,
+	}, created.OwnerToken)
+	if status != 404 {
+		t.Errorf("Expected 404 for an unknown version, got %d", status)
+	}
+}
+
+func TestVoteHistoryBaseline(t *testing.T) {
+	setupVoteVersionApp()
+	defer os.Remove("test_vote_version.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Baseline Test",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	session, _ := services.GetSession(ctx, created.ID)
+	votes := []models.VoteItem{{TimeslotID: session.Timeslots[0].ID}}
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Ali", Password: "1234", Votes: votes}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend the vote was cast before versions were kept
+	if _, err := db.DB.Exec("DELETE FROM vote_versions"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{VoterName: "Ali", Password: "1234", Votes: []models.VoteItem{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := services.GetVoteHistory(ctx, created.ID, models.ParticipantCredentials{VoterName: "Ali", Password: "1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Versions) != 2 || h.Versions[1].Cause != models.VersionCauseBaseline || len(h.Versions[1].Votes) != 1 {
+		t.Errorf("Expected the earlier ballot kept as a baseline, got %+v", h.Versions)
+	}
+}
+
+func TestVoteHistoryWithInviteToken(t *testing.T) {
+	setupVoteVersionApp()
+	defer os.Remove("test_vote_version.db")
+	ctx := context.Background()
+
+	created, err := services.CreateSession(ctx, models.CreateSessionRequest{
+		Title:       "Invitee History",
+		CreatorName: "Tester",
+		Timeslots: []models.TimeslotRequest{
+			{StartUTC: "2023-01-01T12:00:00Z", EndUTC: "2023-01-01T13:00:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed session: %v", err)
+	}
+	invitees, err := services.CreateInvitees(ctx, created.ID, []string{"Sara"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := models.ParticipantCredentials{InviteToken: invitees[0].Token}
+
+	// Nothing to show before the invitee has voted
+	if _, err := services.GetVoteHistory(ctx, created.ID, creds); err == nil || err.Error() != "participant not found" {
+		t.Errorf("Expected participant not found, got %v", err)
+	}
+	if _, err := services.SubmitVote(ctx, created.ID, models.VoteRequest{InviteToken: invitees[0].Token}); err != nil {
+		t.Fatal(err)
+	}
+	history, err := services.GetVoteHistory(ctx, created.ID, creds)
+	if err != nil || history.VoterName != "Sara" {
+		t.Fatalf("Expected Sara's history, got %+v (%v)", history, err)
+	}
+	_, err = services.GetVoteHistory(ctx, created.ID, models.ParticipantCredentials{InviteToken: "nope"})
+	if err == nil || err.Error() != "invalid_invite_token" {
+		t.Errorf("Expected invalid_invite_token, got %v", err)
+	}
+
+	resp, err := services.EraseParticipant(ctx, created.ID, models.EraseParticipantRequest{InviteToken: invitees[0].Token})
+	if err != nil || resp.Status != "ok" {
+		t.Errorf("Expected the invitee to erase their data, got %+v (%v)", resp, err)
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import "time"
+
+// Years in which the leap cycle of the Jalali calendar changes, see
+// https://github.com/jalaali/jalaali-js for the algorithm.
+var jalaliBreaks = []int{-61, 9, 38, 199, 426, 686, 756, 818, 1111, 1181, 1210, 1635, 2060, 2097, 2192, 2262, 2324, 2394, 2456, 3178}
+
+// JalaliToGregorian returns the Gregorian date of a Jalali (Persian) date,
+// at midnight UTC. Months run from 1 (Farvardin) to 12 (Esfand).
+func JalaliToGregorian(jy, jm, jd int) time.Time {
+	gy, march := jalaliNewYear(jy)
+	nowruz := time.Date(gy, time.March, march, 0, 0, 0, 0, time.UTC)
+	return nowruz.AddDate(0, 0, (jm-1)*31-jm/7*(jm-7)+jd-1)
+}
+
+// jalaliNewYear returns the Gregorian year in which Jalali year jy starts
+// and the day of March of its first day.
+func jalaliNewYear(jy int) (gy, march int) {
+	gy = jy + 621
+	leapJ := -14
+	jp := jalaliBreaks[0]
+	jump := 0
+	for _, jm := range jalaliBreaks[1:] {
+		jump = jm - jp
+		if jy < jm {
+			break
+		}
+		leapJ += jump/33*8 + jump%33/4
+		jp = jm
+	}
+	n := jy - jp
+	leapJ += n/33*8 + (n%33+3)/4
+	if jump%33 == 4 && jump-n == 4 {
+		leapJ++
+	}
+	leapG := gy/4 - (gy/100+1)*3/4 - 150
+	return gy, 20 + leapJ - leapG
+}
+
+// HijriToGregorian returns the Gregorian date of a date in the tabular
+// Islamic calendar, at midnight UTC. Calendars based on moon sighting, such
+// as Iran's official one, can differ from it by a day.
+func HijriToGregorian(hy, hm, hd int) time.Time {
+	jdn := hd + (59*(hm-1)+1)/2 + (hy-1)*354 + (3+11*hy)/30 + 1948439
+	return time.Unix(int64(jdn-2440588)*86400, 0).UTC()
+}
+
+// GregorianToHijriYear returns the tabular Islamic year that t falls in.
+func GregorianToHijriYear(t time.Time) int {
+	jdn := int(t.Unix()/86400) + 2440588
+	return (30*(jdn-1948439) + 10646) / 10631
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdCode   = regexp.MustCompile("`([^`]+)`")
+	mdLink   = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^\s)]+)\)`)
+	mdBold   = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdItalic = regexp.MustCompile(`(^|[^\w*])[*_]([^*_]+)[*_]([^\w*]|$)`)
+	mdItem   = regexp.MustCompile(`^\s*[-*]\s+`)
+	mdBlocks = regexp.MustCompile(`\n\s*\n`)
+)
+
+// RenderMarkdown turns the small Markdown subset used in session
+// descriptions into HTML: paragraphs, line breaks, "- " lists, **bold**,
+// *italic*, `code` and [text](https://...) links. The source is escaped
+// before any tag is added, so raw HTML in it is never passed through.
+func RenderMarkdown(src string) string {
+	// NUL marks links while rendering, see renderEmphasis
+	src = strings.ReplaceAll(strings.TrimSpace(src), "\x00", "")
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	if src == "" {
+		return ""
+	}
+
+	var out strings.Builder
+	for _, block := range mdBlocks.Split(src, -1) {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		if isMarkdownList(lines) {
+			out.WriteString("<ul>")
+			for _, line := range lines {
+				out.WriteString("<li>" + renderInline(mdItem.ReplaceAllString(line, "")) + "</li>")
+			}
+			out.WriteString("</ul>")
+			continue
+		}
+		rendered := make([]string, len(lines))
+		for i, line := range lines {
+			rendered[i] = renderInline(strings.TrimSpace(line))
+		}
+		out.WriteString("<p>" + strings.Join(rendered, "<br>") + "</p>")
+	}
+	return out.String()
+}
+
+func isMarkdownList(lines []string) bool {
+	for _, line := range lines {
+		if !mdItem.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// renderInline escapes text and applies inline markup outside of code spans.
+func renderInline(text string) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range mdCode.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(renderEmphasis(text[last:m[0]]))
+		out.WriteString("<code>" + html.EscapeString(text[m[2]:m[3]]) + "</code>")
+		last = m[1]
+	}
+	out.WriteString(renderEmphasis(text[last:]))
+	return out.String()
+}
+
+// renderEmphasis escapes text and adds links, bold and italics. Link URLs
+// are set aside first so emphasis markers in them stay as they are.
+func renderEmphasis(text string) string {
+	text = html.EscapeString(text)
+	var urls []string
+	text = mdLink.ReplaceAllStringFunc(text, func(link string) string {
+		m := mdLink.FindStringSubmatch(link)
+		urls = append(urls, m[2])
+		return "[" + m[1] + "]\x00"
+	})
+	text = mdBold.ReplaceAllString(text, "<strong>$1</strong>")
+	text = mdItalic.ReplaceAllString(text, "$1<em>$2</em>$3")
+	for _, url := range urls {
+		i := strings.Index(text, "]\x00")
+		j := strings.LastIndex(text[:i], "[")
+		text = text[:j] + `<a href="` + url + `" target="_blank" rel="nofollow noopener noreferrer">` + text[j+1:i] + "</a>" + text[i+2:]
+	}
+	return text
+}
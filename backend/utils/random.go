@@ -1,20 +1,23 @@
 package utils
 
 import (
-	"math/rand"
-	"time"
+	"crypto/rand"
+	"math/big"
 )
 
 const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
-func GenerateShortID(length int) string {
+// GenerateShortID returns a random ID of length characters from charset,
+// drawn from crypto/rand so IDs cannot be predicted from earlier ones.
+func GenerateShortID(length int) (string, error) {
 	b := make([]byte, length)
+	max := big.NewInt(int64(len(charset)))
 	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = charset[n.Int64()]
 	}
-	return string(b)
+	return string(b), nil
 }
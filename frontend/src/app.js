@@ -5,6 +5,15 @@ let sessionData = null;
 let selectedTimeslots = new Set();
 let voterName = '';
 let voterPassword = '';
+// Personal invite link token (?invite=...) issued by the session owner
+const inviteToken = new URLSearchParams(window.location.search).get('invite') || '';
+// Magic edit link token (?edit=...) returned after voting
+const editToken = new URLSearchParams(window.location.search).get('edit') || '';
+// Secret link key (?key=...) of a private session
+const linkKey = new URLSearchParams(window.location.search).get('key') || '';
+let lastEditLink = '';
+// Timeslot pattern previewed on the create form, sent along when creating
+let previewedPattern = null;
 
 // DOM Elements
 const app = document.getElementById('app');
@@ -82,7 +91,7 @@ function getSessionIDFromURL() {
     const path = window.location.pathname;
     if (path === '/admin') return 'admin';
     const id = path.substring(1);
-    if (id && /^[a-zA-Z0-9]{5}$/.test(id)) {
+    if (id && /^[a-zA-Z0-9-]{4,40}$/.test(id)) {
         return id;
     }
     return null;
@@ -120,7 +129,7 @@ function formatJalaliDate(utcDateString) {
     return `${dayName} ${j.jy}/${j.jm}/${j.jd}`;
 }
 
-function renderTimePicker(prefix, defaultHour = 9, defaultMinute = 0, minHour = 0, maxHour = 23) {
+function renderTimePicker(prefix, defaultHour = 9, defaultMinute = 0, minHour = 0, maxHour = 23, minuteStep = 15) {
     let hourOpts = '';
     for (let i = minHour; i <= maxHour; i++) {
         const val = String(i).padStart(2, '0');
@@ -128,7 +137,7 @@ function renderTimePicker(prefix, defaultHour = 9, defaultMinute = 0, minHour =
     }
 
     let minOpts = '';
-    for (let i = 0; i < 60; i += 15) {
+    for (let i = 0; i < 60; i += minuteStep) {
         const val = String(i).padStart(2, '0');
         minOpts += `<option value="${val}" ${i === defaultMinute ? 'selected' : ''}>${val}</option>`;
     }
@@ -142,6 +151,54 @@ function renderTimePicker(prefix, defaultHour = 9, defaultMinute = 0, minHour =
     `;
 }
 
+// Start/end pickers for proposing a timeslot that follow the session's slot
+// rules: minutes step by the granularity and a fixed length needs no end.
+function renderSlotPickers(config) {
+    const granularity = config.granularity_minutes || 0;
+    const step = granularity >= 60 ? 60 : (granularity && 60 % granularity === 0 ? granularity : 15);
+    const start = `
+        <div class="flex flex-col items-center">
+            <span class="text-xs text-gray-500 dark:text-gray-400 mb-1">شروع</span>
+            ${renderTimePicker('dynamic_start', 9, 0, 0, 23, step)}
+        </div>`;
+    if (config.slot_minutes) {
+        return `<div class="flex gap-4 items-center justify-center mb-4">${start}</div>`;
+    }
+    return `
+        <div class="flex gap-4 items-center justify-center mb-4">
+            ${start}
+            <div class="text-gray-400 mt-4">←</div>
+            <div class="flex flex-col items-center">
+                <span class="text-xs text-gray-500 dark:text-gray-400 mb-1">پایان</span>
+                ${renderTimePicker('dynamic_end', 10, 0, 0, 23, step)}
+            </div>
+        </div>`;
+}
+
+function slotRulesText(config) {
+    const rules = [];
+    if (config.slot_minutes) rules.push(`مدت هر زمان ${config.slot_minutes} دقیقه`);
+    if (config.min_slot_minutes) rules.push(`حداقل ${config.min_slot_minutes} دقیقه`);
+    if (config.max_slot_minutes) rules.push(`حداکثر ${config.max_slot_minutes} دقیقه`);
+    if (config.granularity_minutes) rules.push(`شروع در مضرب‌های ${config.granularity_minutes} دقیقه`);
+    if (config.buffer_minutes) rules.push(`${config.buffer_minutes} دقیقه فاصله با زمان‌های دیگر`);
+    return rules.length ? `<br>قوانین: ${rules.join('، ')}` : '';
+}
+
+function addMinutes(time, minutes) {
+    const [h, m] = time.split(':').map(Number);
+    const total = Math.min(h * 60 + m + minutes, 23 * 60 + 59);
+    return `${String(Math.floor(total / 60)).padStart(2, '0')}:${String(total % 60).padStart(2, '0')}`;
+}
+
+const slotErrorMessages = {
+    timeslot_exists: 'این زمان قبلاً ثبت شده است',
+    slot_outside_window: 'این زمان خارج از بازه مجاز جلسه است',
+    slot_duration_not_allowed: 'مدت این زمان با قوانین جلسه سازگار نیست',
+    slot_start_not_aligned: 'زمان شروع با قوانین جلسه سازگار نیست',
+    slot_too_close: 'این زمان با زمان‌های دیگر تداخل دارد یا فاصله کافی ندارد'
+};
+
 function renderJalaliDatePicker(prefix, defaultDate = new Date()) {
     const j = jalaali.toJalaali(defaultDate);
     const months = getJalaliMonths();
@@ -189,10 +246,75 @@ function jalaliToISO(jDate, timeStr) {
     return date.toISOString();
 }
 
+// Tokens that prove who is looking, so the server can reveal results that
+// the session's visibility mode hides from strangers
+function viewerHeaders(id) {
+    const headers = {};
+    const owner = localStorage.getItem(`owner_${id}`);
+    const edit = editToken || localStorage.getItem(`edit_${id}`);
+    if (owner) headers['X-Owner-Token'] = owner;
+    if (edit) headers['X-Edit-Token'] = edit;
+    if (inviteToken) headers['X-Invite-Token'] = inviteToken;
+    const key = accessKey(id);
+    if (key) headers['X-Access-Key'] = key;
+    const access = sessionStorage.getItem(`access_${id}`);
+    if (access) headers['X-Access-Token'] = access;
+    return headers;
+}
+
+// Link key of a private session, from the URL or remembered by its owner
+function accessKey(id) {
+    return linkKey || localStorage.getItem(`key_${id}`) || '';
+}
+
+function renderUnlockForm(id, failed) {
+    app.innerHTML = `
+        <div class="max-w-sm mx-auto mt-10 bg-white dark:bg-gray-800 p-6 rounded-lg shadow-md space-y-4">
+            <h2 class="text-lg font-bold dark:text-white">این جلسه خصوصی است</h2>
+            <p class="text-sm text-gray-600 dark:text-gray-400">برای مشاهده، رمز ورود جلسه را وارد کنید.</p>
+            ${failed ? '<p class="text-sm text-red-500">رمز ورود نادرست است</p>' : ''}
+            <input type="password" id="accessPassword" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white" placeholder="رمز ورود">
+            <button onclick="unlockSession('${id}')" class="w-full bg-blue-600 text-white py-2 rounded hover:bg-blue-700">ورود</button>
+        </div>
+    `;
+}
+
+window.unlockSession = async function (id) {
+    const password = document.getElementById('accessPassword').value;
+    if (!password) return;
+    try {
+        const res = await fetch(`${API_BASE}/sessions/${id}/access`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ password })
+        });
+        if (res.status === 429) {
+            const err = await res.json();
+            showToast(err.error || 'تلاش‌های ناموفق زیاد بود، بعداً دوباره امتحان کنید', 'error');
+            return;
+        }
+        if (!res.ok) {
+            renderUnlockForm(id, true);
+            return;
+        }
+        const data = await res.json();
+        sessionStorage.setItem(`access_${id}`, data.access_token);
+        fetchSession(id);
+    } catch (err) {
+        showToast(err.message, 'error');
+    }
+};
+
 // API Calls
 async function fetchSession(id) {
     try {
-        const res = await fetch(`${API_BASE}/sessions/${id}`);
+        const res = await fetch(`${API_BASE}/sessions/${id}`, { headers: viewerHeaders(id) });
+        if (res.status === 401 || res.status === 403) {
+            // No or stale credentials for a private session
+            sessionStorage.removeItem(`access_${id}`);
+            renderUnlockForm(id, false);
+            return;
+        }
         if (!res.ok) throw new Error('جلسه مورد نظر یافت نشد');
         sessionData = await res.json();
 
@@ -214,7 +336,17 @@ async function fetchSession(id) {
 
 async function fetchAdminStats() {
     try {
-        const res = await fetch(`${API_BASE}/admin/stats`);
+        const adminToken = localStorage.getItem('admin_token') || '';
+        const res = await fetch(`${API_BASE}/admin/stats`, {
+            headers: adminToken ? { 'Authorization': `Bearer ${adminToken}` } : {}
+        });
+        if (res.status === 401) {
+            const token = prompt('توکن مدیریت را وارد کنید');
+            if (token) {
+                localStorage.setItem('admin_token', token);
+                return fetchAdminStats();
+            }
+        }
         if (!res.ok) throw new Error('Failed to fetch stats');
         const stats = await res.json();
         renderAdminDashboard(stats);
@@ -226,9 +358,10 @@ async function fetchAdminStats() {
 function renderSession() {
     const { title, creator_name, type, timeslots: _timeslots, dynamic_config } = sessionData;
     const timeslots = _timeslots || [];
+    const isOwner = !!localStorage.getItem(`owner_${sessionData.id}`);
     const voteCounts = {};
     timeslots.forEach(ts => {
-        voteCounts[ts.id] = (ts.votes || []).length;
+        voteCounts[ts.id] = ts.vote_count ?? (ts.votes || []).length;
     });
 
     let dynamicHeader = '';
@@ -241,23 +374,14 @@ function renderSession() {
                 <p class="text-sm text-blue-700 dark:text-blue-400">
                     تاریخ: ${formatJalaliDate(dynamic_config.date_utc)}<br>
                     بازه مجاز: ${dynamic_config.min_time} تا ${dynamic_config.max_time}
+                    ${slotRulesText(dynamic_config)}
                 </p>
             </div>
         `;
         dynamicInput = `
             <div class="mt-6 border-t pt-6">
                 <h3 class="font-bold text-gray-800 dark:text-white mb-4">افزودن زمان پیشنهادی جدید:</h3>
-                <div class="flex gap-4 items-center justify-center mb-4">
-                    <div class="flex flex-col items-center">
-                        <span class="text-xs text-gray-500 dark:text-gray-400 mb-1">شروع</span>
-                        ${renderTimePicker('dynamic_start', 9, 0)}
-                    </div>
-                    <div class="text-gray-400 mt-4">←</div>
-                    <div class="flex flex-col items-center">
-                        <span class="text-xs text-gray-500 dark:text-gray-400 mb-1">پایان</span>
-                        ${renderTimePicker('dynamic_end', 10, 0)}
-                    </div>
-                </div>
+                ${renderSlotPickers(dynamic_config)}
                 <button onclick="submitDynamicTimeslot()" class="w-full bg-blue-600 text-white py-2 rounded hover:bg-blue-700 transition-colors">
                     + افزودن زمان
                 </button>
@@ -274,6 +398,7 @@ function renderSession() {
                 <h3 class="font-bold text-purple-800 dark:text-purple-300 mb-2">الگوی هفتگی:</h3>
                 <p class="text-sm text-purple-700 dark:text-purple-400">
                     بازه مجاز: ${dynamic_config.min_time} تا ${dynamic_config.max_time}
+                    ${slotRulesText(dynamic_config)}
                 </p>
             </div>
         `;
@@ -286,17 +411,7 @@ function renderSession() {
                         ${days}
                     </select>
                 </div>
-                <div class="flex gap-4 items-center justify-center mb-4">
-                    <div class="flex flex-col items-center">
-                        <span class="text-xs text-gray-500 dark:text-gray-400 mb-1">شروع</span>
-                        ${renderTimePicker('dynamic_start', 9, 0)}
-                    </div>
-                    <div class="text-gray-400 mt-4">←</div>
-                    <div class="flex flex-col items-center">
-                        <span class="text-xs text-gray-500 dark:text-gray-400 mb-1">پایان</span>
-                        ${renderTimePicker('dynamic_end', 10, 0)}
-                    </div>
-                </div>
+                ${renderSlotPickers(dynamic_config)}
                 <button onclick="submitDynamicTimeslot()" class="w-full bg-purple-600 text-white py-2 rounded hover:bg-purple-700 transition-colors">
                     + افزودن زمان
                 </button>
@@ -304,6 +419,9 @@ function renderSession() {
         `;
     }
 
+    // Re-rendering must not lose a comment being written
+    const commentDraft = document.getElementById('commentBody')?.value || '';
+
     app.innerHTML = `
         <div class="max-w-2xl mx-auto bg-white dark:bg-gray-800 p-6 rounded-lg shadow">
             <div class="flex justify-between items-start mb-4">
@@ -315,6 +433,7 @@ function renderSession() {
                 </div>
             </div>
             <p class="text-gray-600 dark:text-gray-400 mb-6 text-center">ایجاد شده توسط: ${creator_name}</p>
+            ${renderSessionDetails()}
 
             ${type === 'fixed' ? `
                 <div class="bg-gray-50 dark:bg-gray-700 p-3 rounded mb-6 text-sm text-gray-600 dark:text-gray-300 border dark:border-gray-600">
@@ -360,6 +479,14 @@ function renderSession() {
 
             <div class="space-y-3">
                 <h3 class="font-semibold text-gray-700">زمان‌های موجود:</h3>
+                ${sessionData.timeslots_changed_at_utc ? `<p class="p-2 rounded bg-amber-50 border border-amber-200 text-sm text-amber-800">
+                    برخی از زمان‌هایی که به آن‌ها رای داده بودید تغییر کرده‌اند. لطفاً انتخاب خود را بازبینی و دوباره ثبت کنید.
+                </p>` : ''}
+                ${sessionData.results_hidden ? `<p class="text-xs text-gray-500 text-center">${{
+                    anonymous: 'رای‌گیری ناشناس است؛ فقط تعداد رای‌ها نمایش داده می‌شود.',
+                    after_vote: 'نتایج پس از ثبت رای شما نمایش داده می‌شود.',
+                    owner_only: 'نتایج فقط برای برگزارکننده قابل مشاهده است.'
+                }[sessionData.results_visibility] || ''}</p>` : ''}
                 ${timeslots.length === 0 ? '<p class="text-gray-400 text-sm text-center italic">هنوز زمانی ثبت نشده است</p>' : ''}
                 ${timeslots.map(ts => {
         const isSelected = selectedTimeslots.has(ts.id);
@@ -367,6 +494,8 @@ function renderSession() {
         const voters = (ts.votes || []).map(v => v.voter_name);
 
         const canDelete = (type === 'dynamic' || type === 'weekly') && (ts.votes || []).length === 0;
+        // The owner can move any timeslot, proposers theirs with its password
+        const canEdit = isOwner || (type !== 'fixed' && ts.created_by);
 
         return `
                     <div class="timeslot-card border rounded p-3 cursor-pointer transition-colors ${isSelected ? 'bg-blue-50 border-blue-500' : 'hover:bg-gray-50'} relative group"
@@ -376,6 +505,11 @@ function renderSession() {
                             🗑️
                         </button>
                         ` : ''}
+                        ${canEdit ? `
+                        <button onclick="editTimeslot(event, '${ts.id}')" class="absolute top-2 ${canDelete ? 'left-9' : 'left-2'} text-gray-400 hover:text-blue-600 opacity-0 group-hover:opacity-100 transition-opacity p-1 z-10" title="تغییر زمان">
+                            ✏️
+                        </button>
+                        ` : ''}
                         <div class="flex flex-col sm:flex-row justify-between items-center gap-2">
                             <div>
                                 <div class="text-sm text-gray-500 dark:text-gray-400 mb-1 text-right">
@@ -383,6 +517,7 @@ function renderSession() {
                                 </div>
                                 <div class="font-bold text-gray-800 dark:text-white">${formatTime(ts.start_utc)} - ${formatTime(ts.end_utc)}</div>
                                 ${ts.created_by ? `<div class="text-xs text-gray-400 mt-1">پیشنهاد دهنده: ${ts.created_by}</div>` : ''}
+                                ${ts.updated_at_utc ? '<div class="text-xs text-amber-600 mt-1">این زمان تغییر کرده است</div>' : ''}
                             </div>
                             <div class="flex items-center space-x-2 space-x-reverse">
                                 <span class="bg-gray-200 text-gray-700 px-2 py-1 rounded text-xs">
@@ -396,6 +531,21 @@ function renderSession() {
                             <span class="font-semibold">رای‌دهندگان:</span> ${voters.join('، ')}
                         </div>
                         ` : ''}
+                        ${canEdit ? `
+                        <div id="ts-edit-${ts.id}" class="hidden mt-3 pt-3 border-t space-y-2 cursor-default" onclick="event.stopPropagation()">
+                            ${renderJalaliDatePicker(`edit_${ts.id}`, new Date(ts.start_utc))}
+                            <div class="flex gap-2 items-center justify-center">
+                                ${renderTimePicker(`edit_${ts.id}_start`, new Date(ts.start_utc).getHours(), new Date(ts.start_utc).getMinutes())}
+                                <span class="text-gray-500">تا</span>
+                                ${renderTimePicker(`edit_${ts.id}_end`, new Date(ts.end_utc).getHours(), new Date(ts.end_utc).getMinutes())}
+                            </div>
+                            <label class="flex items-center gap-2 text-xs text-gray-600 dark:text-gray-300">
+                                <input type="checkbox" id="edit_${ts.id}_invalidate">
+                                رای‌های ثبت‌شده برای این زمان حذف شوند
+                            </label>
+                            <button onclick="saveTimeslot('${ts.id}')" class="w-full bg-blue-600 text-white py-1 rounded text-sm hover:bg-blue-700">ذخیره زمان جدید</button>
+                        </div>
+                        ` : ''}
                     </div>
                     `;
     }).join('')}
@@ -406,9 +556,26 @@ function renderSession() {
             <button onclick="submitVote()" class="mt-8 w-full bg-green-600 text-white py-3 rounded-lg hover:bg-green-700 font-bold shadow-lg transition-transform transform hover:scale-105">
                 ثبت / ویرایش رای
             </button>
+
+            ${lastEditLink ? `
+            <div class="mt-4 p-3 border border-green-300 bg-green-50 rounded text-sm break-all">
+                <div class="font-bold mb-1">لینک ویرایش رای (فقط یک بار نمایش داده می‌شود):</div>
+                <a href="${lastEditLink}" class="text-blue-600 underline" dir="ltr">${lastEditLink}</a>
+            </div>` : ''}
+
+            <button onclick="showVoteHistory()" class="mt-4 w-full text-sm text-blue-600 dark:text-blue-400 hover:underline">
+                تاریخچه رای‌های من
+            </button>
+            <div id="voteHistory" class="mt-2 space-y-2"></div>
+            <button onclick="eraseMyData()" class="mt-4 w-full text-sm text-red-600 hover:underline">
+                حذف کامل اطلاعات من از این جلسه
+            </button>
+
+            ${renderComments()}
         </div>
     `;
 
+    document.getElementById('commentBody').value = commentDraft;
     document.getElementById('voterNameInput').value = voterName;
     document.getElementById('voterNameInput').addEventListener('input', (e) => {
         voterName = e.target.value;
@@ -439,6 +606,137 @@ function renderSession() {
     });
 }
 
+// description_html comes rendered and sanitized from the server
+function renderSessionDetails() {
+    const { description_html, location, meeting_url, duration_minutes } = sessionData;
+    if (!description_html && !location && !meeting_url && !duration_minutes) return '';
+    return `
+        <div class="mb-6 space-y-2 text-sm text-gray-700 dark:text-gray-300">
+            ${description_html ? `<div class="session-description space-y-2">${description_html}</div>` : ''}
+            ${location ? `<div>📍 ${escapeHTML(location)}</div>` : ''}
+            ${meeting_url ? `<div>🔗 <a href="${escapeHTML(meeting_url)}" target="_blank" rel="noopener noreferrer" class="text-blue-600 dark:text-blue-400 underline break-all" dir="ltr">${escapeHTML(meeting_url)}</a></div>` : ''}
+            ${duration_minutes ? `<div>⏱ مدت جلسه: ${duration_minutes} دقیقه</div>` : ''}
+        </div>
+    `;
+}
+
+function escapeHTML(text) {
+    const div = document.createElement('div');
+    div.textContent = text;
+    return div.innerHTML;
+}
+
+function renderComments() {
+    const comments = sessionData.comments || [];
+    const isOwner = !!localStorage.getItem(`owner_${sessionData.id}`);
+    return `
+        <div class="mt-8 border-t dark:border-gray-700 pt-6">
+            <h3 class="font-bold text-gray-800 dark:text-white mb-4">گفتگو</h3>
+            <div class="space-y-3 mb-4">
+                ${comments.length === 0 ? '<p class="text-gray-400 text-sm text-center italic">هنوز نظری ثبت نشده است</p>' : ''}
+                ${comments.map(c => {
+        const mine = voterName && c.author_name === voterName;
+        return `
+                <div class="bg-gray-50 dark:bg-gray-700 p-3 rounded" id="comment-${c.id}">
+                    <div class="flex justify-between items-center mb-1">
+                        <span class="text-sm font-semibold text-gray-700 dark:text-gray-200">${escapeHTML(c.author_name)}</span>
+                        <span class="text-xs text-gray-400">${formatJalali(c.created_at_utc)}${c.updated_at_utc ? ' · ویرایش شده' : ''}</span>
+                    </div>
+                    <p class="text-sm text-gray-700 dark:text-gray-300 whitespace-pre-line comment-body">${escapeHTML(c.body)}</p>
+                    ${mine || isOwner ? `
+                    <div class="flex gap-3 mt-2 text-xs">
+                        ${mine ? `<button onclick="editComment('${c.id}')" class="text-blue-600 dark:text-blue-400 hover:underline">ویرایش</button>` : ''}
+                        <button onclick="deleteComment('${c.id}')" class="text-red-600 hover:underline">حذف</button>
+                    </div>` : ''}
+                </div>`;
+    }).join('')}
+            </div>
+            <textarea id="commentBody" rows="3" maxlength="2000" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white" placeholder="نظر خود را بنویسید..."></textarea>
+            <button onclick="postComment()" class="mt-2 w-full bg-blue-600 text-white py-2 rounded hover:bg-blue-700">ارسال نظر</button>
+        </div>
+    `;
+}
+
+async function commentRequest(method, path, body) {
+    const res = await fetch(`${API_BASE}/sessions/${sessionData.id}/comments${path}`, {
+        method,
+        headers: { 'Content-Type': 'application/json', ...viewerHeaders(sessionData.id) },
+        body: JSON.stringify({
+            author_name: voterName,
+            password: voterPassword,
+            edit_token: editToken,
+            invite_token: inviteToken,
+            ...body
+        })
+    });
+    if (!res.ok) {
+        const err = await res.json();
+        throw new Error({
+            comment_empty: 'متن نظر خالی است',
+            comment_too_long: 'متن نظر بیش از حد طولانی است',
+            author_required: 'لطفاً نام خود را وارد کنید',
+            password_required: 'رمز عبور اشتباه است یا وارد نشده',
+            invalid_password: 'رمز عبور اشتباه است یا وارد نشده',
+            invalid_edit_token: 'لینک ویرایش نامعتبر یا منقضی شده است',
+            edit_token_expired: 'لینک ویرایش نامعتبر یا منقضی شده است',
+            invalid_invite_token: 'لینک دعوت نامعتبر است',
+            name_taken_no_password: 'این نام قبلاً ثبت شده و بدون رمز عبور است',
+            name_reserved_for_invitee: 'این نام برای یک مهمان دعوت‌شده رزرو شده است',
+            comment_not_protected: 'این نظر بدون رمز ثبت شده و قابل تغییر نیست',
+            not_comment_author: 'فقط نویسنده می‌تواند این نظر را تغییر دهد',
+            session_archived: 'این جلسه بایگانی شده است',
+            too_many_attempts: `تلاش‌های ناموفق زیاد بود. ${err.retry_after} ثانیه دیگر دوباره امتحان کنید`
+        }[err.error] || err.error || 'خطا در ثبت نظر');
+    }
+    return res.json();
+}
+
+window.postComment = async function () {
+    const body = document.getElementById('commentBody').value.trim();
+    if (!body) return;
+    if (!voterName && !editToken && !inviteToken) {
+        showToast('لطفاً نام خود را وارد کنید', 'warning');
+        document.getElementById('voterNameInput').focus();
+        return;
+    }
+    try {
+        await commentRequest('POST', '', { body });
+        fetchSession(sessionData.id);
+    } catch (err) {
+        showToast(err.message, 'error');
+    }
+};
+
+window.editComment = function (id) {
+    const container = document.querySelector(`#comment-${id} .comment-body`);
+    const current = sessionData.comments.find(c => c.id === id).body;
+    container.outerHTML = `
+        <textarea id="commentEdit-${id}" rows="3" maxlength="2000" class="w-full p-2 border rounded dark:bg-gray-600 dark:border-gray-500 dark:text-white">${escapeHTML(current)}</textarea>
+        <button onclick="saveComment('${id}')" class="mt-1 text-xs bg-blue-600 text-white px-3 py-1 rounded hover:bg-blue-700">ذخیره</button>
+    `;
+};
+
+window.saveComment = async function (id) {
+    const body = document.getElementById(`commentEdit-${id}`).value.trim();
+    try {
+        await commentRequest('PUT', `/${id}`, { body });
+        fetchSession(sessionData.id);
+    } catch (err) {
+        showToast(err.message, 'error');
+    }
+};
+
+window.deleteComment = function (id) {
+    showConfirmToast('این نظر حذف شود؟', async () => {
+        try {
+            await commentRequest('DELETE', `/${id}`, {});
+            fetchSession(sessionData.id);
+        } catch (err) {
+            showToast(err.message, 'error');
+        }
+    });
+};
+
 // Global handlers
 window.toggleTimeslot = function (id) {
     if (selectedTimeslots.has(id)) {
@@ -450,7 +748,9 @@ window.toggleTimeslot = function (id) {
 };
 
 window.copyLink = function () {
-    const url = window.location.href;
+    // Private sessions are shared with their link key, never with personal tokens
+    const key = accessKey(sessionData.id);
+    const url = key ? `${window.location.origin}/${sessionData.id}?key=${key}` : window.location.href;
     navigator.clipboard.writeText(url).then(() => {
         showToast('لینک کپی شد', 'success');
     }).catch(() => {
@@ -473,7 +773,8 @@ window.shareSession = function () {
 
 window.submitDynamicTimeslot = async function () {
     const start = getTimeFromPicker('dynamic_start');
-    const end = getTimeFromPicker('dynamic_end');
+    const slotMinutes = sessionData.dynamic_config.slot_minutes;
+    const end = slotMinutes ? addMinutes(start, slotMinutes) : getTimeFromPicker('dynamic_end');
 
     // Construct dates
     let dateStr;
@@ -542,7 +843,7 @@ window.submitDynamicTimeslot = async function () {
 
         const res = await fetch(`${API_BASE}/sessions/${sessionData.id}/timeslots`, {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
+            headers: { 'Content-Type': 'application/json', ...viewerHeaders(sessionData.id) },
             body: JSON.stringify({
                 start_utc: startUTC,
                 end_utc: endUTC,
@@ -553,7 +854,7 @@ window.submitDynamicTimeslot = async function () {
 
         if (!res.ok) {
             const err = await res.json();
-            throw new Error(err.error || 'خطا در افزودن زمان');
+            throw new Error(slotErrorMessages[err.error] || err.error || 'خطا در افزودن زمان');
         }
 
         showToast('زمان جدید با موفقیت اضافه شد', 'success');
@@ -589,7 +890,7 @@ window.deleteTimeslot = async function (e, id) {
         try {
             const res = await fetch(`${API_BASE}/sessions/${sessionData.id}/timeslots/${id}`, {
                 method: 'DELETE',
-                headers: { 'Content-Type': 'application/json' },
+                headers: { 'Content-Type': 'application/json', ...viewerHeaders(sessionData.id) },
                 body: JSON.stringify({ password: voterPassword })
             });
             if (!res.ok) {
@@ -602,6 +903,9 @@ window.deleteTimeslot = async function (e, id) {
                     showToast('رمز عبور اشتباه است', 'error');
                     document.getElementById('voterPasswordInput').focus();
                     return;
+                } else if (err.error === 'too_many_attempts') {
+                    showToast(`تلاش‌های ناموفق زیاد بود. ${err.retry_after} ثانیه دیگر دوباره امتحان کنید`, 'error');
+                    return;
                 }
                 throw new Error(err.error || 'خطا در حذف زمان');
             }
@@ -617,8 +921,46 @@ window.deleteTimeslot = async function (e, id) {
     });
 };
 
+window.editTimeslot = function (e, id) {
+    e.stopPropagation();
+    document.getElementById(`ts-edit-${id}`).classList.toggle('hidden');
+};
+
+window.saveTimeslot = async function (id) {
+    const date = getJalaliDateFromPicker(`edit_${id}`);
+    const payload = {
+        start_utc: jalaliToISO(date, getTimeFromPicker(`edit_${id}_start`)),
+        end_utc: jalaliToISO(date, getTimeFromPicker(`edit_${id}_end`)),
+        password: voterPassword,
+        invalidate_votes: document.getElementById(`edit_${id}_invalidate`).checked
+    };
+    try {
+        const res = await fetch(`${API_BASE}/sessions/${sessionData.id}/timeslots/${id}`, {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json', ...viewerHeaders(sessionData.id) },
+            body: JSON.stringify(payload)
+        });
+        const data = await res.json();
+        if (!res.ok) {
+            const messages = {
+                password_required: 'برای تغییر این زمان، رمز عبور پیشنهاددهنده را در بالا وارد کنید',
+                invalid_password: 'رمز عبور اشتباه است',
+                timeslot_not_protected: 'فقط برگزارکننده می‌تواند این زمان را تغییر دهد',
+                invalid_timeslot: 'زمان پایان باید بعد از زمان شروع باشد',
+                ...slotErrorMessages,
+                too_many_attempts: `تلاش‌های ناموفق زیاد بود. ${data.retry_after} ثانیه دیگر دوباره امتحان کنید`
+            };
+            throw new Error(messages[data.error] || data.error || 'خطا در تغییر زمان');
+        }
+        showToast(data.votes_invalidated ? `زمان تغییر کرد و ${data.votes_invalidated} رای حذف شد` : 'زمان تغییر کرد', 'success');
+        fetchSession(sessionData.id);
+    } catch (err) {
+        showToast(err.message, 'error');
+    }
+};
+
 window.submitVote = async function () {
-    if (!voterName) {
+    if (!voterName && !inviteToken && !editToken) {
         showToast('لطفاً نام خود را وارد کنید', 'warning');
         return;
     }
@@ -643,10 +985,12 @@ async function sendVoteRequest(votes) {
     try {
         const res = await fetch(`${API_BASE}/sessions/${sessionData.id}/vote`, {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
+            headers: { 'Content-Type': 'application/json', ...viewerHeaders(sessionData.id) },
             body: JSON.stringify({
                 voter_name: voterName,
                 password: voterPassword,
+                invite_token: inviteToken,
+                edit_token: editToken,
                 votes: votes
             })
         });
@@ -659,6 +1003,14 @@ async function sendVoteRequest(votes) {
             } else if (err.error === 'invalid_password') {
                 showToast('رمز عبور اشتباه است', 'error');
                 document.getElementById('voterPasswordInput').focus();
+            } else if (err.error === 'invalid_invite_token') {
+                showToast('لینک دعوت معتبر نیست', 'error');
+            } else if (err.error === 'too_many_attempts') {
+                showToast(`تلاش‌های ناموفق زیاد بود. ${err.retry_after} ثانیه دیگر دوباره امتحان کنید`, 'error');
+            } else if (err.error === 'invalid_edit_token' || err.error === 'edit_token_expired') {
+                showToast('لینک ویرایش نامعتبر یا منقضی شده است', 'error');
+            } else if (err.error === 'name_reserved_for_invitee') {
+                showToast('این نام برای یک مهمان دعوت‌شده رزرو شده است', 'error');
             } else if (err.error === 'name_taken_no_password') {
                 showToast('این نام قبلاً ثبت شده و بدون رمز عبور است. امکان ویرایش وجود ندارد.', 'error');
             } else {
@@ -672,6 +1024,12 @@ async function sendVoteRequest(votes) {
             localStorage.setItem(`pwd_${sessionData.id}_${voterName}`, voterPassword);
         }
 
+        const data = await res.json();
+        if (data.edit_link) {
+            lastEditLink = window.location.origin + data.edit_link;
+            localStorage.setItem(`edit_${sessionData.id}`, data.edit_token);
+        }
+
         showToast('رای شما با موفقیت ثبت شد', 'success');
         fetchSession(sessionData.id);
     } catch (err) {
@@ -679,6 +1037,142 @@ async function sendVoteRequest(votes) {
     }
 }
 
+// Self-service erasure: removes the voter's name, votes and proposed
+// timeslots. Authenticated like voting, by password or edit link.
+window.eraseMyData = function () {
+    if (!voterName && !editToken && !inviteToken) {
+        showToast('لطفاً نام خود را وارد کنید', 'warning');
+        return;
+    }
+    showConfirmToast('همه رای‌ها و زمان‌های پیشنهادی شما برای همیشه حذف می‌شوند. ادامه می‌دهید؟', async () => {
+        try {
+            const res = await fetch(`${API_BASE}/sessions/${sessionData.id}/participants/erase`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json', ...viewerHeaders(sessionData.id) },
+                body: JSON.stringify({
+                    voter_name: voterName,
+                    password: voterPassword,
+                    edit_token: editToken,
+                    invite_token: inviteToken
+                })
+            });
+            if (!res.ok) {
+                const err = await res.json();
+                if (err.error === 'password_required' || err.error === 'invalid_password') {
+                    showToast('رمز عبور اشتباه است یا وارد نشده', 'error');
+                } else if (err.error === 'invalid_edit_token' || err.error === 'edit_token_expired') {
+                    showToast('لینک ویرایش نامعتبر یا منقضی شده است', 'error');
+                } else if (err.error === 'name_taken_no_password') {
+                    showToast('برای حذف اطلاعات، رمز عبور یا لینک ویرایش لازم است', 'error');
+                } else if (err.error === 'too_many_attempts') {
+                    showToast(`تلاش‌های ناموفق زیاد بود. ${err.retry_after} ثانیه دیگر دوباره امتحان کنید`, 'error');
+                } else if (err.error === 'participant not found') {
+                    showToast('شرکت‌کننده‌ای با این نام پیدا نشد', 'error');
+                } else {
+                    throw new Error(err.error || 'خطا در حذف اطلاعات');
+                }
+                return;
+            }
+            localStorage.removeItem(`pwd_${sessionData.id}_${voterName}`);
+            localStorage.removeItem(`edit_${sessionData.id}`);
+            voterName = '';
+            voterPassword = '';
+            lastEditLink = '';
+            showToast('اطلاعات شما حذف شد', 'success');
+            fetchSession(sessionData.id);
+        } catch (err) {
+            showToast(err.message, 'error');
+        }
+    });
+};
+
+// Earlier ballots of the current voter, each of which can be restored
+async function voteVersionRequest(path, extra) {
+    const res = await fetch(`${API_BASE}/sessions/${sessionData.id}/votes/${path}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json', ...viewerHeaders(sessionData.id) },
+        body: JSON.stringify({
+            voter_name: voterName,
+            password: voterPassword,
+            edit_token: editToken,
+            invite_token: inviteToken,
+            ...extra
+        })
+    });
+    const data = await res.json();
+    if (!res.ok) {
+        throw new Error({
+            password_required: 'رمز عبور اشتباه است یا وارد نشده',
+            invalid_password: 'رمز عبور اشتباه است یا وارد نشده',
+            invalid_edit_token: 'لینک ویرایش نامعتبر یا منقضی شده است',
+            edit_token_expired: 'لینک ویرایش نامعتبر یا منقضی شده است',
+            name_taken_no_password: 'برای دیدن تاریخچه، رمز عبور یا لینک ویرایش لازم است',
+            'participant not found': 'شرکت‌کننده‌ای با این نام پیدا نشد',
+            session_archived: 'این جلسه بایگانی شده است'
+        }[data.error] || data.error || 'خطا در دریافت تاریخچه');
+    }
+    return data;
+}
+
+window.showVoteHistory = async function () {
+    if (!voterName && !editToken && !inviteToken) {
+        showToast('لطفاً نام خود را وارد کنید', 'warning');
+        return;
+    }
+    try {
+        const history = await voteVersionRequest('history', {});
+        const container = document.getElementById('voteHistory');
+        if (history.versions.length === 0) {
+            container.innerHTML = '<p class="text-sm text-gray-500 dark:text-gray-400 text-center">تاریخچه‌ای ثبت نشده است</p>';
+            return;
+        }
+        container.innerHTML = history.versions.map((v, i) => `
+            <div class="flex items-center justify-between text-sm bg-gray-50 dark:bg-gray-700 p-2 rounded">
+                <span class="dark:text-gray-200">نسخه ${v.version} · ${formatJalali(v.created_at_utc)} · ${v.votes.length} زمان</span>
+                ${i === 0 ? '<span class="text-xs text-gray-500 dark:text-gray-400">فعلی</span>' :
+                `<button onclick="revertVotes(${v.version})" class="text-xs text-blue-600 dark:text-blue-400 hover:underline">بازگردانی</button>`}
+            </div>
+        `).join('');
+    } catch (err) {
+        showToast(err.message, 'error');
+    }
+};
+
+window.revertVotes = function (version) {
+    showConfirmToast(`رای‌های شما به نسخه ${version} بازگردانده شود؟`, async () => {
+        try {
+            const result = await voteVersionRequest('revert', { version });
+            showToast(result.skipped ? 'رای‌ها بازگردانده شد؛ برخی زمان‌ها دیگر وجود ندارند' : 'رای‌ها بازگردانده شد', 'success');
+            fetchSession(sessionData.id);
+        } catch (err) {
+            showToast(err.message, 'error');
+        }
+    });
+};
+
+// Proof-of-work for session creation. The server asks for a nonce such that
+// sha256(challenge + ":" + nonce) starts with `difficulty` zero bits.
+async function solveChallenge() {
+    const res = await fetch(`${API_BASE}/challenges`);
+    if (!res.ok) return {};
+    const { challenge, difficulty } = await res.json();
+    if (!difficulty) return {};
+
+    const encoder = new TextEncoder();
+    for (let nonce = 0; ; nonce++) {
+        const digest = new Uint8Array(await crypto.subtle.digest('SHA-256', encoder.encode(`${challenge}:${nonce}`)));
+        let zeros = 0;
+        for (const byte of digest) {
+            if (byte === 0) { zeros += 8; continue; }
+            zeros += Math.clz32(byte) - 24;
+            break;
+        }
+        if (zeros >= difficulty) {
+            return { pow_challenge: challenge, pow_nonce: String(nonce) };
+        }
+    }
+}
+
 function renderCreateSessionForm() {
     app.innerHTML = `
         <div class="max-w-2xl mx-auto bg-white p-6 rounded-lg shadow">
@@ -695,6 +1189,50 @@ function renderCreateSessionForm() {
                     <input type="text" id="creatorName" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white" placeholder="نام شما">
                 </div>
 
+                <div>
+                    <label class="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">توضیحات (اختیاری)</label>
+                    <textarea id="sessionDescription" rows="3" maxlength="2000" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white" placeholder="دستور جلسه، **متن پررنگ**، [لینک](https://...)"></textarea>
+                </div>
+
+                <div class="grid grid-cols-1 sm:grid-cols-2 gap-4">
+                    <div>
+                        <label class="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">مکان (اختیاری)</label>
+                        <input type="text" id="sessionLocation" maxlength="200" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white" placeholder="مثلاً: دفتر مرکزی، اتاق ۲">
+                    </div>
+                    <div>
+                        <label class="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">مدت جلسه به دقیقه (اختیاری)</label>
+                        <input type="number" id="sessionDuration" min="0" max="1440" step="5" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white" placeholder="60">
+                    </div>
+                </div>
+
+                <div>
+                    <label class="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">لینک جلسه آنلاین (اختیاری)</label>
+                    <input type="url" id="sessionMeetingURL" dir="ltr" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white" placeholder="https://meet.example.com/...">
+                </div>
+
+                <div>
+                    <label class="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">آدرس دلخواه (اختیاری)</label>
+                    <input type="text" id="sessionSlug" dir="ltr" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white" placeholder="team-sync">
+                </div>
+
+                <div>
+                    <label class="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">نمایش نتایج</label>
+                    <select id="resultsVisibility" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white">
+                        <option value="public">برای همه</option>
+                        <option value="anonymous">ناشناس (فقط تعداد رای‌ها)</option>
+                        <option value="after_vote">پس از ثبت رای</option>
+                        <option value="owner_only">فقط برای من</option>
+                    </select>
+                </div>
+
+                <div class="space-y-2">
+                    <label class="flex items-center gap-2 cursor-pointer">
+                        <input type="checkbox" id="privateLink">
+                        <span class="text-sm font-medium text-gray-700 dark:text-gray-300">جلسه خصوصی (فقط با لینک مخفی)</span>
+                    </label>
+                    <input type="password" id="accessPassword" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white" placeholder="رمز ورود جلسه (اختیاری)">
+                </div>
+
                     <label class="flex items-center gap-2 cursor-pointer flex-1 justify-center bg-white dark:bg-gray-700 p-2 rounded border dark:border-gray-600 hover:bg-gray-50 dark:hover:bg-gray-600 transition-colors">
                         <input type="radio" name="sessionType" value="fixed" checked onchange="toggleSessionType('fixed')">
                         <span class="text-sm font-medium dark:text-white">زمان‌های مشخص</span>
@@ -723,6 +1261,47 @@ function renderCreateSessionForm() {
                     <button onclick="addTimeslotInput()" class="mt-4 w-full border-2 border-dashed border-blue-300 text-blue-600 dark:border-blue-500 dark:text-blue-400 py-2 rounded hover:bg-blue-50 dark:hover:bg-gray-700 transition-colors">
                         + افزودن زمان جدید
                     </button>
+
+                    <details class="mt-4 border rounded p-3 dark:border-gray-600">
+                        <summary class="cursor-pointer text-sm font-medium text-gray-700 dark:text-gray-300">ساخت خودکار زمان‌ها از یک الگو</summary>
+                        <div class="space-y-3 mt-3">
+                            <div>
+                                <span class="text-xs text-gray-500 dark:text-gray-400">از تاریخ</span>
+                                ${renderJalaliDatePicker('pattern_from')}
+                            </div>
+                            <div>
+                                <span class="text-xs text-gray-500 dark:text-gray-400">تا تاریخ</span>
+                                ${renderJalaliDatePicker('pattern_to', new Date(Date.now() + 6 * 86400000))}
+                            </div>
+                            <div class="grid grid-cols-4 gap-1">
+                                ${['شنبه', 'یکشنبه', 'دوشنبه', 'سه‌شنبه', 'چهارشنبه', 'پنج‌شنبه', 'جمعه'].map((day, idx) => `
+                                    <label class="flex items-center gap-1 text-xs dark:text-white">
+                                        <input type="checkbox" name="patternDay" value="${(idx + 6) % 7}" ${idx < 5 ? 'checked' : ''}>
+                                        ${day}
+                                    </label>
+                                `).join('')}
+                            </div>
+                            <div class="flex gap-4 items-center justify-center">
+                                ${renderTimePicker('pattern_start', 9, 0)}
+                                <div class="text-gray-400">←</div>
+                                ${renderTimePicker('pattern_end', 12, 0)}
+                            </div>
+                            <div class="flex gap-2">
+                                <label class="flex-1 text-xs text-gray-500 dark:text-gray-400">مدت هر زمان (دقیقه)
+                                    <input type="number" id="patternSlotMinutes" value="60" min="5" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white">
+                                </label>
+                                <label class="flex-1 text-xs text-gray-500 dark:text-gray-400">فاصله بین زمان‌ها (دقیقه)
+                                    <input type="number" id="patternGapMinutes" value="0" min="0" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white">
+                                </label>
+                            </div>
+                            <label class="flex items-center gap-2 text-sm dark:text-white">
+                                <input type="checkbox" id="patternSkipHolidays" checked>
+                                تعطیلات رسمی حذف شوند
+                            </label>
+                            <button onclick="previewPattern()" class="w-full bg-blue-600 text-white py-2 rounded hover:bg-blue-700 text-sm">پیش‌نمایش</button>
+                            <div id="patternPreview" class="text-sm"></div>
+                        </div>
+                    </details>
                 </div>
 
                 <!--Weekly Section-->
@@ -777,6 +1356,34 @@ function renderCreateSessionForm() {
                     </div>
                 </div>
 
+                <!--Slot Rules for weekly and dynamic sessions-->
+                <div id="slotRulesSection" class="hidden mt-4 border p-4 rounded dark:border-gray-600 space-y-3">
+                    <label class="block text-sm font-medium text-gray-700 dark:text-gray-300">قوانین زمان‌های پیشنهادی (اختیاری)</label>
+                    <div class="grid grid-cols-2 gap-2">
+                        <label class="text-xs text-gray-500 dark:text-gray-400">مدت ثابت (دقیقه)
+                            <input type="number" id="ruleSlotMinutes" min="0" placeholder="آزاد" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white">
+                        </label>
+                        <label class="text-xs text-gray-500 dark:text-gray-400">فاصله بین زمان‌ها (دقیقه)
+                            <input type="number" id="ruleBufferMinutes" min="0" placeholder="0" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white">
+                        </label>
+                        <label class="text-xs text-gray-500 dark:text-gray-400">حداقل مدت (دقیقه)
+                            <input type="number" id="ruleMinSlotMinutes" min="0" placeholder="آزاد" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white">
+                        </label>
+                        <label class="text-xs text-gray-500 dark:text-gray-400">حداکثر مدت (دقیقه)
+                            <input type="number" id="ruleMaxSlotMinutes" min="0" placeholder="آزاد" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white">
+                        </label>
+                    </div>
+                    <label class="block text-xs text-gray-500 dark:text-gray-400">شروع زمان‌ها
+                        <select id="ruleGranularity" class="w-full p-2 border rounded dark:bg-gray-700 dark:border-gray-600 dark:text-white">
+                            <option value="0">هر دقیقه</option>
+                            <option value="5">هر ۵ دقیقه</option>
+                            <option value="15">هر ۱۵ دقیقه</option>
+                            <option value="30">هر نیم ساعت</option>
+                            <option value="60">سر ساعت</option>
+                        </select>
+                    </label>
+                </div>
+
                 <button onclick="submitCreateSession()" class="w-full bg-green-600 text-white py-3 rounded-lg hover:bg-green-700 font-bold shadow-lg mt-6">
                     ایجاد جلسه
                 </button>
@@ -848,6 +1455,64 @@ window.addTimeslotInput = function () {
     container.appendChild(div);
 };
 
+function gregorianDateFromPicker(prefix) {
+    const j = getJalaliDateFromPicker(prefix);
+    const g = jalaali.toGregorian(j.y, j.m, j.d);
+    return `${g.gy}-${String(g.gm).padStart(2, '0')}-${String(g.gd).padStart(2, '0')}`;
+}
+
+window.previewPattern = async function () {
+    const pattern = {
+        start_date: gregorianDateFromPicker('pattern_from'),
+        end_date: gregorianDateFromPicker('pattern_to'),
+        weekdays: Array.from(document.querySelectorAll('input[name="patternDay"]:checked')).map(cb => parseInt(cb.value)),
+        day_start: getTimeFromPicker('pattern_start'),
+        day_end: getTimeFromPicker('pattern_end'),
+        slot_minutes: parseInt(document.getElementById('patternSlotMinutes').value, 10) || 0,
+        gap_minutes: parseInt(document.getElementById('patternGapMinutes').value, 10) || 0,
+        timezone: Intl.DateTimeFormat().resolvedOptions().timeZone,
+        skip_holidays: document.getElementById('patternSkipHolidays').checked
+    };
+    const container = document.getElementById('patternPreview');
+    previewedPattern = null;
+    try {
+        const res = await fetch(`${API_BASE}/timeslots/preview`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(pattern)
+        });
+        const data = await res.json();
+        if (!res.ok) {
+            const messages = {
+                invalid_date_range: 'بازه تاریخ نامعتبر است (حداکثر یک سال)',
+                invalid_daily_window: 'ساعت پایان باید بعد از ساعت شروع باشد',
+                invalid_slot_duration: 'مدت هر زمان باید حداقل ۵ دقیقه و کوتاه‌تر از بازه روزانه باشد',
+                invalid_gap: 'فاصله بین زمان‌ها نامعتبر است',
+                too_many_timeslots: 'تعداد زمان‌ها بیش از حد مجاز (۲۰۰) است',
+                no_timeslots: 'این الگو هیچ زمانی تولید نمی‌کند'
+            };
+            throw new Error(messages[data.error] || data.error || 'خطا در ساخت زمان‌ها');
+        }
+        previewedPattern = pattern;
+        container.innerHTML = `
+            <p class="font-medium dark:text-white mb-1">${data.timeslots.length} زمان ساخته می‌شود و هنگام ایجاد جلسه افزوده خواهد شد:</p>
+            <ul class="max-h-40 overflow-y-auto text-xs text-gray-600 dark:text-gray-300 space-y-1">
+                ${data.timeslots.map(ts => `<li>${formatJalaliDate(ts.start_utc)} ${formatTime(ts.start_utc)} - ${formatTime(ts.end_utc)}</li>`).join('')}
+            </ul>
+            ${(data.skipped_holidays || []).length ? `<p class="text-xs text-amber-600 mt-1">روزهای تعطیل حذف‌شده: ${data.skipped_holidays.map(h => `${formatJalaliDate(h.date)} (${escapeHTML(h.name)})`).join('، ')}</p>` : ''}
+            <button onclick="clearPattern()" class="text-xs text-red-600 hover:underline mt-1">لغو این زمان‌ها</button>
+        `;
+    } catch (err) {
+        container.innerHTML = '';
+        showToast(err.message, 'error');
+    }
+};
+
+window.clearPattern = function () {
+    previewedPattern = null;
+    document.getElementById('patternPreview').innerHTML = '';
+};
+
 window.toggleSessionType = function (type) {
     const fixedSection = document.getElementById('fixedTimeSection');
     const weeklySection = document.getElementById('weeklyTimeSection');
@@ -857,6 +1522,7 @@ window.toggleSessionType = function (type) {
     fixedSection.classList.add('hidden');
     weeklySection.classList.add('hidden');
     dynamicSection.classList.add('hidden');
+    document.getElementById('slotRulesSection').classList.toggle('hidden', type === 'fixed');
 
     if (type === 'fixed') {
         fixedSection.classList.remove('hidden');
@@ -884,6 +1550,14 @@ window.submitCreateSession = async function () {
         title,
         creator_name: creatorName,
         type,
+        slug: document.getElementById('sessionSlug').value.trim().toLowerCase(),
+        description: document.getElementById('sessionDescription').value,
+        location: document.getElementById('sessionLocation').value,
+        meeting_url: document.getElementById('sessionMeetingURL').value,
+        duration_minutes: parseInt(document.getElementById('sessionDuration').value, 10) || 0,
+        results_visibility: document.getElementById('resultsVisibility').value,
+        private_link: document.getElementById('privateLink').checked,
+        access_password: document.getElementById('accessPassword').value,
         timeslots: []
     };
 
@@ -904,7 +1578,11 @@ window.submitCreateSession = async function () {
             });
         }
 
-        if (payload.timeslots.length < 2) {
+        if (previewedPattern) {
+            payload.timeslot_pattern = previewedPattern;
+        }
+
+        if (payload.timeslots.length < 2 && !previewedPattern) {
             showToast('لطفاً حداقل دو زمان را مشخص کنید تا کاربران حق انتخاب داشته باشند', 'warning');
             return;
         }
@@ -951,7 +1629,21 @@ window.submitCreateSession = async function () {
         };
     }
 
+    if (payload.dynamic_config) {
+        const minutes = id => parseInt(document.getElementById(id).value, 10) || 0;
+        Object.assign(payload.dynamic_config, {
+            slot_minutes: minutes('ruleSlotMinutes'),
+            min_slot_minutes: minutes('ruleMinSlotMinutes'),
+            max_slot_minutes: minutes('ruleMaxSlotMinutes'),
+            granularity_minutes: minutes('ruleGranularity'),
+            buffer_minutes: minutes('ruleBufferMinutes'),
+            timezone: Intl.DateTimeFormat().resolvedOptions().timeZone
+        });
+    }
+
     try {
+        Object.assign(payload, await solveChallenge());
+
         const res = await fetch(`${API_BASE}/sessions`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
@@ -960,10 +1652,26 @@ window.submitCreateSession = async function () {
 
         if (!res.ok) {
             const err = await res.json();
-            throw new Error(err.error || 'خطا در ایجاد جلسه');
+            throw new Error({
+                invalid_slug: 'آدرس دلخواه باید ۴ تا ۴۰ حرف کوچک انگلیسی، عدد یا خط تیره باشد',
+                slug_reserved: 'این آدرس رزرو شده است',
+                slug_taken: 'این آدرس قبلاً استفاده شده است',
+                description_too_long: 'توضیحات بیش از حد طولانی است',
+                location_too_long: 'مکان بیش از حد طولانی است',
+                invalid_meeting_url: 'لینک جلسه آنلاین باید با http یا https شروع شود',
+                invalid_duration: 'مدت جلسه نامعتبر است',
+                invalid_slot_rules: 'قوانین زمان‌های پیشنهادی نامعتبر است؛ مدت ثابت را با حداقل و حداکثر ترکیب نکنید',
+                after_vote_requires_edit_links: 'نمایش نتایج پس از رای در این سرور در دسترس نیست'
+            }[err.error] || err.error || 'خطا در ایجاد جلسه');
         }
 
         const data = await res.json();
+        localStorage.setItem(`owner_${data.id}`, data.owner_token);
+        if (data.access_key) {
+            localStorage.setItem(`key_${data.id}`, data.access_key);
+            window.location.href = `/${data.id}?key=${data.access_key}`;
+            return;
+        }
         window.location.href = `/${data.id}`;
     } catch (err) {
         showToast(err.message, 'error');